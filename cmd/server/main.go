@@ -1,16 +1,30 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/diegopontes87/api/configs"
 	_ "github.com/diegopontes87/api/docs"
 	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
 	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/internal/infra/logging"
+	"github.com/diegopontes87/api/internal/infra/mail"
+	"github.com/diegopontes87/api/internal/infra/telemetry"
 	"github.com/diegopontes87/api/internal/infra/webserver/handlers"
+	"github.com/diegopontes87/api/internal/infra/webserver/middlewares"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/ratelimit"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/jwtauth"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -33,49 +47,339 @@ import (
 // @in                          header
 // @name                        Authorization
 
+// @securityDefinitions.apikey  APIKeyHeader
+// @in                          header
+// @name                        X-API-Key
+
+// @securityDefinitions.oauth2.password  OAuth2Password
+// @tokenUrl                             /oauth/token
+// @scope.products:read                  Read products
+// @scope.products:write                 Create, update and delete products
+// @scope.users:manage                   Manage user roles and permissions
+
+// version is the version of the build, set with
+// -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
 const (
 	dbName     string = "test.db"
 	configPath string = "../../configs"
+	serverAddr string = ":8000"
+
+	revocationCleanupInterval    = 10 * time.Minute
+	deletedUsersPurgeInterval    = time.Hour
+	loginThrottleCleanupInterval = time.Minute
+	rateLimitCleanupInterval     = time.Minute
+
+	// shutdownTimeout is how long the requests in progress have to
+	// complete once the server is asked to stop.
+	shutdownTimeout = 30 * time.Second
+
+	// docsContentSecurityPolicy lets the Swagger UI run its inline scripts
+	// and styles, which the policy of the API refuses.
+	docsContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"
 )
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the API until it receives SIGINT or SIGTERM, or fails to.
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	cfg, err := configs.LoadConfig(configPath)
 	if err != nil {
 		panic(err)
 	}
-	db, err := gorm.Open(sqlite.Open(dbName), &gorm.Config{})
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		panic(err)
+	}
+	slog.SetDefault(logger)
+	tracerProvider, err := telemetry.NewTracerProvider(context.Background(), cfg.TraceExporter, cfg.TraceOTLPEndpoint, version)
+	if err != nil {
+		panic(err)
+	}
+	// Flushes the spans not exported yet once the servers are shut down.
+	defer tracerProvider.Shutdown(context.Background())
+	if err := entity.SetPasswordHasher(cfg.PasswordHasher); err != nil {
+		panic(err)
+	}
+	db, err := gorm.Open(sqlite.Open(dbName), &gorm.Config{Logger: logging.NewGormLogger(logger)})
+	if err != nil {
+		panic(err)
+	}
+	metrics := telemetry.NewMetrics(version)
+	if err := metrics.InstrumentDB(db); err != nil {
+		panic(err)
+	}
+	if err := telemetry.TraceDB(db); err != nil {
+		panic(err)
+	}
+	if err := database.Migrate(db, database.Migrations); err != nil {
+		panic(err)
+	}
+	err = db.AutoMigrate(&entity.Product{}, &entity.User{}, &entity.RefreshToken{}, &entity.RevokedToken{}, &entity.UserTokenRevocation{}, &entity.UserToken{}, &entity.APIKey{}, &entity.OAuthClient{}, &entity.Organization{}, &entity.Membership{}, &entity.Invitation{}, &entity.AuditEvent{})
 	if err != nil {
 		panic(err)
 	}
-	db.AutoMigrate(&entity.Product{}, &entity.User{})
 	productDB := database.NewProductDB(db)
 	productHandler := handlers.NewProductHandler(productDB)
 
 	userDB := database.NewUserDB(db)
-	userHandler := handlers.NewUserHandler(userDB)
+	if err := promoteAdmin(userDB, cfg.AdminEmail); err != nil {
+		panic(err)
+	}
+	organizationDB := database.NewOrganizationDB(db)
+	refreshTokenDB := database.NewRefreshTokenDB(db)
+	revocationStore, err := auth.NewRevocationStore(database.NewRevocationDB(db), time.Second*time.Duration(cfg.JWTExpiresIn), cfg.TokenOptions.Leeway)
+	if err != nil {
+		panic(err)
+	}
+	go revocationStore.StartCleanup(ctx, revocationCleanupInterval)
+	go purgeDeletedUsers(ctx, userDB, deletedUsersPurgeInterval)
+	mailer, err := newMailer(cfg.Mailer, cfg.MailFrom, cfg.MailOutboxDir, cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword)
+	if err != nil {
+		panic(err)
+	}
+	loginThrottle := auth.NewLoginThrottle(
+		auth.LoginThrottleOptions{
+			MaxAttempts: cfg.LoginMaxAttemptsPerAccount,
+			BaseLockout: time.Second * time.Duration(cfg.LoginLockout),
+			MaxLockout:  time.Second * time.Duration(cfg.LoginMaxLockout),
+			Window:      time.Second * time.Duration(cfg.LoginFailureWindow),
+		},
+		auth.LoginThrottleOptions{
+			MaxAttempts: cfg.LoginMaxAttemptsPerIP,
+			BaseLockout: time.Second * time.Duration(cfg.LoginLockout),
+			MaxLockout:  time.Second * time.Duration(cfg.LoginMaxLockout),
+			Window:      time.Second * time.Duration(cfg.LoginFailureWindow),
+		},
+	)
+	go loginThrottle.StartCleanup(ctx, loginThrottleCleanupInterval)
+	apiKeyDB := database.NewAPIKeyDB(db)
+	oauthClientDB := database.NewOAuthClientDB(db)
+	userHandler := handlers.NewUserHandler(userDB, refreshTokenDB, revocationStore, cfg.PasswordPolicy, database.NewUserTokenDB(db), mailer, loginThrottle, organizationDB, apiKeyDB, oauthClientDB, metrics)
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyDB)
+	apiKeyAuthenticator := auth.NewAPIKeyAuthenticator(apiKeyDB, userDB, cfg.UnverifiedUserAccess)
+
+	oauthHandler := handlers.NewOAuthHandler(userHandler, oauthClientDB)
+	organizationHandler := handlers.NewOrganizationHandler(userHandler, organizationDB, database.NewAuditLogDB(db))
+
+	jwksHandler := handlers.NewJWKSHandler(cfg.TokenAuth)
+
+	rateLimits := ratelimit.NewMemoryStore()
+	go rateLimits.StartCleanup(ctx, rateLimitCleanupInterval)
+	authRateLimit := middlewares.RateLimit(rateLimits, "auth", cfg.AuthRateLimit)
+	apiRateLimit := middlewares.RateLimit(rateLimits, "api", cfg.APIRateLimit)
+
+	cors, err := middlewares.CORS(cfg.CORSOptions)
+	if err != nil {
+		panic(err)
+	}
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	r.NotFound(problem.NotFound)
+	r.MethodNotAllowed(problem.MethodNotAllowed)
+	r.Use(middlewares.RealIP(cfg.TrustedProxyRanges))
+	r.Use(middlewares.RequestID)
+	r.Use(middlewares.Tracing)
+	r.Use(middlewares.Logger(logger))
+	r.Use(middlewares.Metrics(metrics))
+	r.Use(middlewares.Recoverer)
+	r.Use(cors)
+	r.Use(middlewares.SecurityHeaders(cfg.SecurityHeaders))
 	r.Use(middleware.WithValue("jwt", cfg.TokenAuth))
 	r.Use(middleware.WithValue("JwtExpiresIn", cfg.JWTExpiresIn))
+	r.Use(middleware.WithValue("JwtOptions", cfg.TokenOptions))
+	r.Use(middleware.WithValue("RefreshTokenExpiresIn", cfg.RefreshTokenExpiresIn))
+	r.Use(middleware.WithValue("AccountDeletionGracePeriod", cfg.AccountDeletionGracePeriod))
+	r.Use(middleware.WithValue("AccountOptions", handlers.AccountOptions{
+		BaseURL:                     cfg.AppBaseURL,
+		VerificationExpiresIn:       time.Second * time.Duration(cfg.EmailVerificationExpiresIn),
+		PasswordResetExpiresIn:      time.Second * time.Duration(cfg.PasswordResetExpiresIn),
+		UnverifiedAccess:            cfg.UnverifiedUserAccess,
+		TwoFactorIssuer:             cfg.TwoFactorIssuer,
+		TwoFactorChallengeExpiresIn: time.Second * time.Duration(cfg.TwoFactorChallengeExpiresIn),
+		InvitationExpiresIn:         time.Second * time.Duration(cfg.OrgInvitationExpiresIn),
+	}))
 
 	r.Route("/products", func(r chi.Router) {
 		r.Use(middleware.WithValue("jwt", cfg.TokenAuth))
-		r.Use(jwtauth.Verifier(cfg.TokenAuth))
-		r.Use(jwtauth.Authenticator)
-		r.Post("/", productHandler.CreateProduct)
-		r.Get("/", productHandler.GetProducts)
-		r.Get("/{id}", productHandler.GetProduct)
-		r.Put("/{id}", productHandler.UpdateProduct)
-		r.Delete("/{id}", productHandler.DeleteProduct)
+		r.Use(middlewares.Verifier(cfg.TokenAuth, cfg.TokenOptions))
+		r.Use(middlewares.Revocation(revocationStore))
+		r.Use(middlewares.APIKey(apiKeyAuthenticator))
+		r.Use(middlewares.Authenticator)
+		r.Use(apiRateLimit)
+		r.Use(middlewares.RequireOrganization(organizationDB))
+		r.With(middlewares.RequirePermission(entity.PermissionProductsWrite)).Post("/", productHandler.CreateProduct)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/", productHandler.GetProducts)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsRead)).Get("/{id}", productHandler.GetProduct)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsWrite)).Put("/{id}", productHandler.UpdateProduct)
+		r.With(middlewares.RequirePermission(entity.PermissionProductsWrite)).Delete("/{id}", productHandler.DeleteProduct)
 	})
 
 	r.Route("/users", func(r chi.Router) {
-		r.Post("/", userHandler.CreateUser)
-		r.Post("/generate_token", userHandler.GetJWT)
+		r.Group(func(r chi.Router) {
+			r.Use(authRateLimit)
+			r.Post("/", userHandler.CreateUser)
+			r.Post("/generate_token", userHandler.GetJWT)
+			r.Post("/refresh", userHandler.RefreshToken)
+			r.Post("/logout", userHandler.Logout)
+			r.Post("/verify_email/request", userHandler.RequestEmailVerification)
+			r.Post("/verify_email/confirm", userHandler.ConfirmEmailVerification)
+			r.Post("/password_reset/request", userHandler.RequestPasswordReset)
+			r.Post("/password_reset/confirm", userHandler.ConfirmPasswordReset)
+			r.Post("/2fa/verify", userHandler.VerifyTwoFactor)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.Verifier(cfg.TokenAuth, cfg.TokenOptions))
+			r.Use(middlewares.Revocation(revocationStore))
+			r.Use(middlewares.Authenticator)
+			r.Use(apiRateLimit)
+			r.Post("/revoke_token", userHandler.RevokeToken)
+			r.Get("/me", userHandler.GetMe)
+			r.Patch("/me", userHandler.UpdateMe)
+			r.Delete("/me", userHandler.DeleteMe)
+			r.Post("/me/password", userHandler.ChangePassword)
+			r.Post("/2fa/enroll", userHandler.EnrollTwoFactor)
+			r.Post("/2fa/enable", userHandler.EnableTwoFactor)
+			r.Post("/2fa/disable", userHandler.DisableTwoFactor)
+			r.Post("/me/api_keys", apiKeyHandler.CreateAPIKey)
+			r.Get("/me/api_keys", apiKeyHandler.GetAPIKeys)
+			r.Delete("/me/api_keys/{id}", apiKeyHandler.RevokeAPIKey)
+			r.Post("/me/oauth_clients", oauthHandler.CreateOAuthClient)
+			r.Get("/me/oauth_clients", oauthHandler.GetOAuthClients)
+			r.Delete("/me/oauth_clients/{id}", oauthHandler.RevokeOAuthClient)
+			r.Group(func(r chi.Router) {
+				r.Use(middlewares.RequirePermission(entity.PermissionUsersManage))
+				r.Get("/", userHandler.GetUsers)
+				r.Get("/{id}", userHandler.GetUser)
+				r.Put("/{id}/role", userHandler.UpdateUserRole)
+				r.Post("/{id}/revoke_tokens", userHandler.RevokeUserTokens)
+				r.Post("/{id}/disable", userHandler.DisableUser)
+				r.Post("/{id}/enable", userHandler.EnableUser)
+				r.Post("/{id}/force_password_reset", userHandler.ForcePasswordReset)
+				r.Post("/{id}/unlock", userHandler.UnlockUser)
+			})
+		})
 	})
-	r.Get("/docs/*", httpSwagger.Handler(httpSwagger.URL("http://localhost:8000/docs/doc.json")))
-	http.ListenAndServe(":8000", r)
+	r.Route("/orgs", func(r chi.Router) {
+		r.With(authRateLimit).Post("/invitations/register", organizationHandler.RegisterWithInvitation)
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.Verifier(cfg.TokenAuth, cfg.TokenOptions))
+			r.Use(middlewares.Revocation(revocationStore))
+			r.Use(middlewares.Authenticator)
+			r.Use(apiRateLimit)
+			r.Post("/", organizationHandler.CreateOrganization)
+			r.Get("/", organizationHandler.GetOrganizations)
+			r.Post("/invitations/accept", organizationHandler.AcceptInvitation)
+			r.Post("/{id}/switch", organizationHandler.SwitchOrganization)
+			r.Get("/{id}/members", organizationHandler.GetMembers)
+			r.Put("/{id}/members/{userID}", organizationHandler.UpdateMember)
+			r.Delete("/{id}/members/{userID}", organizationHandler.RemoveMember)
+			r.Post("/{id}/transfer_ownership", organizationHandler.TransferOwnership)
+			r.Post("/{id}/invitations", organizationHandler.CreateInvitation)
+			r.Get("/{id}/invitations", organizationHandler.GetInvitations)
+			r.Delete("/{id}/invitations/{invitationID}", organizationHandler.RevokeInvitation)
+			r.Get("/{id}/audit_log", organizationHandler.GetAuditLog)
+		})
+	})
+	r.With(authRateLimit).Post("/oauth/token", oauthHandler.Token)
+	r.Get("/.well-known/jwks.json", jwksHandler.GetJWKS)
+	r.With(middlewares.SecurityHeaders(middlewares.SecurityHeadersOptions{
+		ContentSecurityPolicy: docsContentSecurityPolicy,
+	})).Get("/docs/*", httpSwagger.Handler(httpSwagger.URL("http://localhost:8000/docs/doc.json")))
+	servers := []*http.Server{{Addr: serverAddr, Handler: r}}
+	if cfg.MetricsAddr != "" {
+		// The metrics are served apart from the API, on an address that
+		// is only reachable by the scrapers.
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Registry)
+		servers = append(servers, &http.Server{Addr: cfg.MetricsAddr, Handler: metricsMux})
+	}
+	return serve(ctx, logger, servers)
+}
+
+// serve runs servers until ctx is done or one of them fails, then shuts them
+// all down, giving the requests in progress shutdownTimeout to complete.
+func serve(ctx context.Context, logger *slog.Logger, servers []*http.Server) error {
+	failed := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			logger.Info("server started", "addr", server.Addr)
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				failed <- err
+			}
+		}()
+	}
+	var err error
+	select {
+	case <-ctx.Done():
+		logger.Info("shutting down")
+	case err = <-failed:
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	}
+	return err
+}
+
+// promoteAdmin grants the admin role to the user registered with the
+// configured ADMIN_EMAIL, if that user exists.
+func promoteAdmin(userDB *database.UserDB, email string) error {
+	if email == "" {
+		return nil
+	}
+	user, err := userDB.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.IsAdmin() {
+		return nil
+	}
+	user.Role = entity.RoleAdmin
+	return userDB.Update(user)
+}
+
+func newMailer(kind, from, outboxDir, smtpHost, smtpPort, smtpUsername, smtpPassword string) (mail.Mailer, error) {
+	switch kind {
+	case "smtp":
+		return mail.NewSMTPMailer(smtpHost, smtpPort, smtpUsername, smtpPassword, from), nil
+	case "file", "":
+		return mail.NewFileMailer(outboxDir, from), nil
+	case "memory":
+		return mail.NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q", kind)
+}
+
+// purgeDeletedUsers removes the accounts whose deletion grace period is over,
+// every interval until ctx is done.
+func purgeDeletedUsers(ctx context.Context, userDB *database.UserDB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := userDB.DeleteScheduled(now); err != nil {
+				slog.Error("purging deleted users failed", "error", err)
+			}
+		}
+	}
 }
@@ -1,23 +1,97 @@
 package configs
 
 import (
-	"github.com/go-chi/jwtauth"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/diegopontes87/api/internal/infra/webserver/middlewares"
+	"github.com/diegopontes87/api/pkg/password"
+	"github.com/diegopontes87/api/pkg/ratelimit"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var cfg *config
 
 type config struct {
-	DBDriver      string `mapstructure:"DB_DRIVER"`
-	DBHost        string `mapstructure:"DB_HOST"`
-	DBPort        string `mapstructure:"DB_PORT"`
-	DBUser        string `mapstructure:"DB_USER"`
-	DBUPassword   string `mapstructure:"DB_PASSWORD"`
-	DBUName       string `mapstructure:"DB_NAME"`
-	WebServerPort string `mapstructure:"WEB_SERVER_PORT"`
-	JWTSecret     string `mapstructure:"JWT_SECRET"`
-	JWTExpiresIn  int    `mapstructure:"JWT_EXPIRES_IN"`
-	TokenAuth     *jwtauth.JWTAuth
+	DBDriver                    string `mapstructure:"DB_DRIVER"`
+	DBHost                      string `mapstructure:"DB_HOST"`
+	DBPort                      string `mapstructure:"DB_PORT"`
+	DBUser                      string `mapstructure:"DB_USER"`
+	DBUPassword                 string `mapstructure:"DB_PASSWORD"`
+	DBUName                     string `mapstructure:"DB_NAME"`
+	WebServerPort               string `mapstructure:"WEB_SERVER_PORT"`
+	LogLevel                    string `mapstructure:"LOG_LEVEL"`
+	LogFormat                   string `mapstructure:"LOG_FORMAT"`
+	TraceExporter               string `mapstructure:"TRACE_EXPORTER"`
+	TraceOTLPEndpoint           string `mapstructure:"TRACE_OTLP_ENDPOINT"`
+	MetricsAddr                 string `mapstructure:"METRICS_ADDR"`
+	TrustedProxies              string `mapstructure:"TRUSTED_PROXIES"`
+	RateLimitAuth               string `mapstructure:"RATE_LIMIT_AUTH"`
+	RateLimitAPI                string `mapstructure:"RATE_LIMIT_API"`
+	CORSAllowedOrigins          string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods          string `mapstructure:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders          string `mapstructure:"CORS_ALLOWED_HEADERS"`
+	CORSExposedHeaders          string `mapstructure:"CORS_EXPOSED_HEADERS"`
+	CORSAllowCredentials        bool   `mapstructure:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge                  int    `mapstructure:"CORS_MAX_AGE"`
+	HSTSMaxAge                  int    `mapstructure:"HSTS_MAX_AGE"`
+	HSTSIncludeSubdomains       bool   `mapstructure:"HSTS_INCLUDE_SUBDOMAINS"`
+	FrameOptions                string `mapstructure:"FRAME_OPTIONS"`
+	ContentSecurityPolicy       string `mapstructure:"CONTENT_SECURITY_POLICY"`
+	ReferrerPolicy              string `mapstructure:"REFERRER_POLICY"`
+	JWTSecret                   string `mapstructure:"JWT_SECRET"`
+	JWTExpiresIn                int    `mapstructure:"JWT_EXPIRES_IN"`
+	JWTSigningKeyFile           string `mapstructure:"JWT_SIGNING_KEY_FILE"`
+	JWTVerificationKeyFiles     string `mapstructure:"JWT_VERIFICATION_KEY_FILES"`
+	JWTIssuer                   string `mapstructure:"JWT_ISSUER"`
+	JWTAudience                 string `mapstructure:"JWT_AUDIENCE"`
+	JWTLeeway                   int    `mapstructure:"JWT_LEEWAY"`
+	RefreshTokenExpiresIn       int    `mapstructure:"REFRESH_TOKEN_EXPIRES_IN"`
+	AdminEmail                  string `mapstructure:"ADMIN_EMAIL"`
+	AccountDeletionGracePeriod  int    `mapstructure:"ACCOUNT_DELETION_GRACE_PERIOD"`
+	PasswordMinLength           int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength           int    `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordBreachedFile        string `mapstructure:"PASSWORD_BREACHED_LIST_FILE"`
+	PasswordHashAlgorithm       string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	Argon2Memory                uint32 `mapstructure:"ARGON2_MEMORY"`
+	Argon2Iterations            uint32 `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism           uint8  `mapstructure:"ARGON2_PARALLELISM"`
+	BcryptCost                  int    `mapstructure:"BCRYPT_COST"`
+	LoginMaxAttemptsPerAccount  int    `mapstructure:"LOGIN_MAX_ATTEMPTS_PER_ACCOUNT"`
+	LoginMaxAttemptsPerIP       int    `mapstructure:"LOGIN_MAX_ATTEMPTS_PER_IP"`
+	LoginLockout                int    `mapstructure:"LOGIN_LOCKOUT"`
+	LoginMaxLockout             int    `mapstructure:"LOGIN_MAX_LOCKOUT"`
+	LoginFailureWindow          int    `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	AppBaseURL                  string `mapstructure:"APP_BASE_URL"`
+	EmailVerificationExpiresIn  int    `mapstructure:"EMAIL_VERIFICATION_EXPIRES_IN"`
+	PasswordResetExpiresIn      int    `mapstructure:"PASSWORD_RESET_EXPIRES_IN"`
+	UnverifiedUserAccess        string `mapstructure:"UNVERIFIED_USER_ACCESS"`
+	TwoFactorIssuer             string `mapstructure:"TWO_FACTOR_ISSUER"`
+	TwoFactorChallengeExpiresIn int    `mapstructure:"TWO_FACTOR_CHALLENGE_EXPIRES_IN"`
+	OrgInvitationExpiresIn      int    `mapstructure:"ORG_INVITATION_EXPIRES_IN"`
+	Mailer                      string `mapstructure:"MAILER"`
+	MailFrom                    string `mapstructure:"MAIL_FROM"`
+	MailOutboxDir               string `mapstructure:"MAIL_OUTBOX_DIR"`
+	SMTPHost                    string `mapstructure:"SMTP_HOST"`
+	SMTPPort                    string `mapstructure:"SMTP_PORT"`
+	SMTPUsername                string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword                string `mapstructure:"SMTP_PASSWORD"`
+	TokenAuth                   *auth.KeySet
+	TokenOptions                auth.TokenOptions
+	PasswordPolicy              *entity.PasswordPolicy
+	PasswordHasher              *password.Hasher
+	TrustedProxyRanges          []netip.Prefix
+	AuthRateLimit               ratelimit.Limit
+	APIRateLimit                ratelimit.Limit
+	CORSOptions                 middlewares.CORSOptions
+	SecurityHeaders             middlewares.SecurityHeadersOptions
 }
 
 func LoadConfig(path string) (*config, error) {
@@ -36,6 +110,143 @@ func LoadConfig(path string) (*config, error) {
 		panic(err)
 	}
 
-	cfg.TokenAuth = jwtauth.New("HS256", []byte(cfg.JWTSecret), nil)
+	breached, err := readLines(path, cfg.PasswordBreachedFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PasswordPolicy = entity.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordMaxLength, breached)
+	cfg.PasswordHasher, err = newPasswordHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.TrustedProxyRanges, err = parseTrustedProxies(splitList(cfg.TrustedProxies))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AuthRateLimit, err = ratelimit.ParseLimit(cfg.RateLimitAuth); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_AUTH: %w", err)
+	}
+	if cfg.APIRateLimit, err = ratelimit.ParseLimit(cfg.RateLimitAPI); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_API: %w", err)
+	}
+
+	cfg.CORSOptions = middlewares.CORSOptions{
+		AllowedOrigins:   splitList(cfg.CORSAllowedOrigins),
+		AllowedMethods:   splitList(cfg.CORSAllowedMethods),
+		AllowedHeaders:   splitList(cfg.CORSAllowedHeaders),
+		ExposedHeaders:   splitList(cfg.CORSExposedHeaders),
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           time.Second * time.Duration(cfg.CORSMaxAge),
+	}
+	cfg.SecurityHeaders = middlewares.SecurityHeadersOptions{
+		HSTSMaxAge:            time.Second * time.Duration(cfg.HSTSMaxAge),
+		HSTSIncludeSubdomains: cfg.HSTSIncludeSubdomains,
+		FrameOptions:          cfg.FrameOptions,
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+		ReferrerPolicy:        cfg.ReferrerPolicy,
+	}
+
+	cfg.TokenOptions = auth.TokenOptions{
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Leeway:   time.Second * time.Duration(cfg.JWTLeeway),
+	}
+	if cfg.JWTSigningKeyFile == "" {
+		cfg.TokenAuth = auth.NewHMACKeySet([]byte(cfg.JWTSecret))
+		return cfg, nil
+	}
+	cfg.TokenAuth, err = auth.LoadKeySet(cfg.JWTSigningKeyFile, splitList(cfg.JWTVerificationKeyFiles))
+	if err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
+
+// newPasswordHasher returns a hasher of the configured algorithm, which
+// verifies the hashes of the other algorithm as well so they can be replaced.
+func newPasswordHasher(cfg *config) (*password.Hasher, error) {
+	argon2id := password.DefaultArgon2id()
+	if cfg.Argon2Memory > 0 {
+		argon2id.Memory = cfg.Argon2Memory
+	}
+	if cfg.Argon2Iterations > 0 {
+		argon2id.Iterations = cfg.Argon2Iterations
+	}
+	if cfg.Argon2Parallelism > 0 {
+		argon2id.Parallelism = cfg.Argon2Parallelism
+	}
+	if err := argon2id.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ARGON2 settings: %w", err)
+	}
+	bcryptScheme := password.Bcrypt{Cost: cfg.BcryptCost}
+	if bcryptScheme.Cost == 0 {
+		bcryptScheme.Cost = bcrypt.DefaultCost
+	}
+	if bcryptScheme.Cost < bcrypt.MinCost || bcryptScheme.Cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid BCRYPT_COST %d", bcryptScheme.Cost)
+	}
+	switch cfg.PasswordHashAlgorithm {
+	case "argon2id", "":
+		return password.NewHasher(argon2id, bcryptScheme), nil
+	case "bcrypt":
+		return password.NewHasher(bcryptScheme, argon2id), nil
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM %q", cfg.PasswordHashAlgorithm)
+	}
+}
+
+// readLines returns the non-empty lines of a file, which is resolved from the
+// config directory when the path is relative.
+func readLines(configPath, file string) ([]string, error) {
+	if file == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(configPath, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// parseTrustedProxies parses addresses and CIDR ranges, such as "10.0.0.1"
+// or "10.0.0.0/8".
+func parseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES address %q", item)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES range %q", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
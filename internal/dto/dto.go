@@ -1,13 +1,15 @@
 package dto
 
+import "github.com/diegopontes87/api/internal/entity"
+
 type CreateProductInput struct {
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	Name  string  `json:"name" maxLength:"100"`
+	Price float64 `json:"price" minimum:"0.01" maximum:"1000000"`
 }
 
 type CreateUserInput struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
+	Name     string `json:"name" maxLength:"100"`
+	Email    string `json:"email" format:"email" maxLength:"254"`
 	Password string `json:"password"`
 }
 
@@ -17,5 +19,142 @@ type GetJWTInput struct {
 }
 
 type GetJWTOutput struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type UpdateUserRoleInput struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserDetailsOutput is a user as seen by an administrator.
+type UserDetailsOutput struct {
+	*entity.User
+	// LoginLockedFor is the number of seconds sign-ins to the account are
+	// locked out for after too many failures.
+	LoginLockedFor int                 `json:"login_locked_for"`
+	Memberships    []entity.Membership `json:"memberships"`
+}
+
+type UpdateUserInput struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type EmailInput struct {
+	Email string `json:"email"`
+}
+
+type ConfirmEmailVerificationInput struct {
+	Token string `json:"token"`
+}
+
+type ConfirmPasswordResetInput struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type TwoFactorEnrollmentOutput struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCode is a PNG image of OTPAuthURI, base64 encoded.
+	QRCode []byte `json:"qr_code_png"`
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorChallengeOutput struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+type VerifyTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+type CreateAPIKeyInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresIn is the lifetime of the key in seconds. Keys without one do
+	// not expire.
+	ExpiresIn  int      `json:"expires_in"`
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+type CreateAPIKeyOutput struct {
+	// Key is only returned when the key is created.
+	Key    string         `json:"key"`
+	APIKey *entity.APIKey `json:"api_key"`
+}
+
+type CreateOAuthClientInput struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	GrantTypes []string `json:"grant_types"`
+}
+
+type CreateOAuthClientOutput struct {
+	ClientID string `json:"client_id"`
+	// ClientSecret is only returned when the client is registered.
+	ClientSecret string              `json:"client_secret"`
+	Client       *entity.OAuthClient `json:"client"`
+}
+
+// OAuthTokenOutput is the access token response of RFC 6749, section 5.1.
+type OAuthTokenOutput struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthError is the error response of RFC 6749, section 5.2.
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type CreateOrganizationInput struct {
+	Name string `json:"name"`
+}
+
+type CreateInvitationInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type InvitationTokenInput struct {
+	Token string `json:"token"`
+}
+
+type RegisterWithInvitationInput struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type UpdateMemberInput struct {
+	Role string `json:"role"`
+}
+
+type TransferOwnershipInput struct {
+	UserID string `json:"user_id"`
 }
@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/validation"
+)
+
+// Validate returns every violation of the rules of the input as
+// validation.Errors.
+func (in CreateProductInput) Validate() error {
+	v := validation.New()
+	entity.ValidateName(v, "name", in.Name)
+	entity.ValidatePrice(v, "price", in.Price)
+	return v.Err()
+}
+
+// Validate returns every violation of the rules of the input, including the
+// password policy, as validation.Errors.
+func (in CreateUserInput) Validate(policy *entity.PasswordPolicy) error {
+	v := validation.New()
+	entity.ValidateName(v, "name", in.Name)
+	entity.ValidateEmail(v, "email", in.Email)
+	policy.Check(v, "password", in.Password)
+	return v.Err()
+}
+
+// Validate returns every violation of the rules of the fields the input
+// updates as validation.Errors.
+func (in UpdateUserInput) Validate() error {
+	v := validation.New()
+	if in.Name != nil {
+		entity.ValidateName(v, "name", *in.Name)
+	}
+	if in.Email != nil {
+		entity.ValidateEmail(v, "email", *in.Email)
+	}
+	return v.Err()
+}
+
+// Validate returns every violation of the rules of the input, including the
+// password policy, as validation.Errors. The token is checked on its own.
+func (in RegisterWithInvitationInput) Validate(policy *entity.PasswordPolicy) error {
+	v := validation.New()
+	entity.ValidateName(v, "name", in.Name)
+	policy.Check(v, "password", in.Password)
+	return v.Err()
+}
+
+// Validate returns the violations of the password policy by the new
+// password as validation.Errors. The current password is checked on its own.
+func (in ChangePasswordInput) Validate(policy *entity.PasswordPolicy) error {
+	v := validation.New()
+	policy.Check(v, "new_password", in.NewPassword)
+	return v.Err()
+}
+
+// Validate returns the violations of the password policy by the new
+// password as validation.Errors. The token is checked on its own.
+func (in ConfirmPasswordResetInput) Validate(policy *entity.PasswordPolicy) error {
+	v := validation.New()
+	policy.Check(v, "new_password", in.NewPassword)
+	return v.Err()
+}
+
+// Validate returns the violations of the rules of the input that NewAPIKey
+// does not check as validation.Errors.
+func (in CreateAPIKeyInput) Validate() error {
+	v := validation.New()
+	v.Check(in.ExpiresIn >= 0, "expires_in", validation.RuleMin, entity.ErrNegativeExpiresIn)
+	return v.Err()
+}
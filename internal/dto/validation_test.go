@@ -0,0 +1,78 @@
+package dto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateProductInputValidate(t *testing.T) {
+	assert.Nil(t, CreateProductInput{Name: "Product 1", Price: 10.5}.Validate())
+
+	var violations validation.Errors
+	err := CreateProductInput{Name: " ", Price: entity.MaxPrice + 1}.Validate()
+	assert.True(t, errors.As(err, &violations))
+	assert.Equal(t, validation.Errors{
+		{Field: "name", Rule: validation.RuleRequired, Err: entity.ErrNameIsRequired},
+		{Field: "price", Rule: validation.RuleMax, Err: entity.ErrPriceTooHigh},
+	}, violations)
+}
+
+func TestCreateUserInputValidate(t *testing.T) {
+	policy := entity.NewPasswordPolicy(8, 64, []string{"password1"})
+	assert.Nil(t, CreateUserInput{Name: "Diego", Email: "diego@gmail.com", Password: "correct horse"}.Validate(policy))
+
+	var violations validation.Errors
+	err := CreateUserInput{Name: strings.Repeat("a", entity.MaxNameLength+1), Email: "diego@", Password: "password1"}.Validate(policy)
+	assert.True(t, errors.As(err, &violations))
+	assert.Equal(t, validation.Errors{
+		{Field: "name", Rule: validation.RuleMaxLength, Err: entity.ErrNameTooLong},
+		{Field: "email", Rule: validation.RuleFormat, Err: entity.ErrInvalidEmail},
+		{Field: "password", Rule: entity.RuleNotBreached, Err: entity.ErrPasswordBreached},
+	}, violations)
+
+	err = CreateUserInput{}.Validate(policy)
+	assert.True(t, errors.As(err, &violations))
+	assert.Len(t, violations, 3)
+	for _, violation := range violations {
+		assert.Equal(t, validation.RuleRequired, violation.Rule)
+	}
+}
+
+func TestUpdateUserInputValidate(t *testing.T) {
+	assert.Nil(t, UpdateUserInput{}.Validate())
+
+	email := "invalid"
+	var violations validation.Errors
+	assert.True(t, errors.As(UpdateUserInput{Email: &email}.Validate(), &violations))
+	assert.Equal(t, "email", violations[0].Field)
+}
+
+func TestNewPasswordInputsValidate(t *testing.T) {
+	policy := entity.NewPasswordPolicy(8, 64, []string{"password1"})
+	assert.Nil(t, ChangePasswordInput{NewPassword: "correct horse"}.Validate(policy))
+	assert.Nil(t, ConfirmPasswordResetInput{NewPassword: "correct horse"}.Validate(policy))
+
+	var violations validation.Errors
+	assert.True(t, errors.As(ChangePasswordInput{CurrentPassword: "x", NewPassword: "short"}.Validate(policy), &violations))
+	assert.Equal(t, "new_password", violations[0].Field)
+	assert.Equal(t, validation.RuleMinLength, violations[0].Rule)
+	assert.True(t, errors.As(ConfirmPasswordResetInput{Token: "x", NewPassword: "password1"}.Validate(policy), &violations))
+	assert.Equal(t, validation.Errors{
+		{Field: "new_password", Rule: entity.RuleNotBreached, Err: entity.ErrPasswordBreached},
+	}, violations)
+}
+
+func TestCreateAPIKeyInputValidate(t *testing.T) {
+	assert.Nil(t, CreateAPIKeyInput{ExpiresIn: 3600}.Validate())
+
+	var violations validation.Errors
+	assert.True(t, errors.As(CreateAPIKeyInput{ExpiresIn: -1}.Validate(), &violations))
+	assert.Equal(t, validation.Errors{
+		{Field: "expires_in", Rule: validation.RuleMin, Err: entity.ErrNegativeExpiresIn},
+	}, violations)
+}
@@ -0,0 +1,173 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+const (
+	apiKeyPrefix = "pak_"
+	// apiKeyLookupSize is the number of random bytes of the lookup prefix.
+	apiKeyLookupSize = 6
+	// lastUsedPrecision is how stale LastUsedAt may get before Touch
+	// updates it, so that every request does not write to the database.
+	lastUsedPrecision = time.Minute
+)
+
+var (
+	ErrAPIKeyNameIsRequired = errors.New("api key name is required")
+	ErrScopeIsRequired      = errors.New("at least one scope is required")
+	ErrScopeNotGranted      = errors.New("scope is not granted to the user")
+	ErrInvalidAllowedIP     = errors.New("invalid allowed ip")
+	ErrInvalidAPIKey        = errors.New("invalid api key")
+	ErrNegativeExpiresIn    = errors.New("expires_in must not be negative")
+)
+
+// APIKey lets a machine client act on behalf of a user, restricted to its
+// scopes. The key itself is only shown once: Prefix identifies it and
+// KeyHash checks it.
+type APIKey struct {
+	ID     service.ID `json:"id"`
+	UserID service.ID `json:"user_id" gorm:"index"`
+	// OrganizationID is the organization the key acts in.
+	OrganizationID service.ID `json:"organization_id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix" gorm:"uniqueIndex"`
+	// KeyHash is the SHA-256 of the whole key.
+	KeyHash string   `json:"-"`
+	Scopes  []string `json:"scopes" gorm:"serializer:json"`
+	// AllowedIPs lists the IP addresses and CIDR ranges the key may be used
+	// from. An empty list allows any address.
+	AllowedIPs []string   `json:"allowed_ips" gorm:"serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKey creates a key and returns it along with the plain key, which is
+// never stored. A zero expiresIn creates a key that does not expire.
+func NewAPIKey(userID service.ID, name string, scopes, allowedIPs []string, expiresIn time.Duration) (*APIKey, string, error) {
+	key := &APIKey{
+		ID:         service.NewID(),
+		UserID:     userID,
+		Name:       strings.TrimSpace(name),
+		Scopes:     scopes,
+		AllowedIPs: allowedIPs,
+		CreatedAt:  time.Now(),
+	}
+	if expiresIn > 0 {
+		expiresAt := key.CreatedAt.Add(expiresIn)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := key.Validate(); err != nil {
+		return nil, "", err
+	}
+	lookup := make([]byte, apiKeyLookupSize)
+	if _, err := rand.Read(lookup); err != nil {
+		return nil, "", err
+	}
+	secret, err := service.NewToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	key.Prefix = hex.EncodeToString(lookup)
+	plain := apiKeyPrefix + key.Prefix + "_" + secret
+	key.KeyHash = service.HashToken(plain)
+	return key, plain, nil
+}
+
+// ParseAPIKeyPrefix returns the lookup prefix of a plain key.
+func ParseAPIKeyPrefix(plain string) (string, error) {
+	rest, ok := strings.CutPrefix(plain, apiKeyPrefix)
+	size := hex.EncodedLen(apiKeyLookupSize)
+	if !ok || len(rest) <= size+1 || rest[size] != '_' {
+		return "", ErrInvalidAPIKey
+	}
+	return rest[:size], nil
+}
+
+func (k *APIKey) Validate() error {
+	if k.Name == "" {
+		return ErrAPIKeyNameIsRequired
+	}
+	if len(k.Scopes) == 0 {
+		return ErrScopeIsRequired
+	}
+	for _, scope := range k.Scopes {
+		if !IsValidPermission(scope) {
+			return ErrInvalidPermission
+		}
+	}
+	for _, ip := range k.AllowedIPs {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return ErrInvalidAllowedIP
+		}
+	}
+	return nil
+}
+
+// Matches reports whether plain is this key.
+func (k *APIKey) Matches(plain string) bool {
+	return subtle.ConstantTimeCompare([]byte(service.HashToken(plain)), []byte(k.KeyHash)) == 1
+}
+
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+func (k *APIKey) Revoke() {
+	now := time.Now()
+	k.RevokedAt = &now
+}
+
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// AllowsIP reports whether the key may be used from ip.
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, allowed := range k.AllowedIPs {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if net.ParseIP(allowed).Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the scopes of the key that are still granted to its
+// user, so that taking a permission away from a user also takes it away
+// from their keys.
+func (k *APIKey) Permissions(granted []string) []string {
+	return GrantedOnly(k.Scopes, granted)
+}
+
+// Touch records a use of the key. It reports whether the key changed and
+// has to be saved.
+func (k *APIKey) Touch(ip string, now time.Time) bool {
+	if k.LastUsedAt != nil && now.Sub(*k.LastUsedAt) < lastUsedPrecision && k.LastUsedIP == ip {
+		return false
+	}
+	k.LastUsedAt = &now
+	k.LastUsedIP = ip
+	return true
+}
@@ -0,0 +1,70 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	userID := service.NewID()
+	key, plain, err := NewAPIKey(userID, " ci ", []string{PermissionProductsRead}, nil, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "ci", key.Name)
+	assert.Equal(t, userID, key.UserID)
+	assert.Nil(t, key.ExpiresAt)
+	assert.NotContains(t, key.KeyHash, plain)
+	assert.True(t, key.Matches(plain))
+	assert.False(t, key.Matches(plain+"x"))
+
+	prefix, err := ParseAPIKeyPrefix(plain)
+	assert.Nil(t, err)
+	assert.Equal(t, key.Prefix, prefix)
+	for _, invalid := range []string{"", "pak_", "pak_" + key.Prefix, "xyz_" + plain[4:], "pak_short_secret"} {
+		_, err = ParseAPIKeyPrefix(invalid)
+		assert.Equal(t, ErrInvalidAPIKey, err, invalid)
+	}
+}
+
+func TestNewAPIKeyValidation(t *testing.T) {
+	_, _, err := NewAPIKey(service.NewID(), "", []string{PermissionProductsRead}, nil, 0)
+	assert.Equal(t, ErrAPIKeyNameIsRequired, err)
+	_, _, err = NewAPIKey(service.NewID(), "ci", nil, nil, 0)
+	assert.Equal(t, ErrScopeIsRequired, err)
+	_, _, err = NewAPIKey(service.NewID(), "ci", []string{"products:fly"}, nil, 0)
+	assert.Equal(t, ErrInvalidPermission, err)
+	_, _, err = NewAPIKey(service.NewID(), "ci", []string{PermissionProductsRead}, []string{"10.0.0.300"}, 0)
+	assert.Equal(t, ErrInvalidAllowedIP, err)
+}
+
+func TestAPIKeyRestrictions(t *testing.T) {
+	key, _, err := NewAPIKey(service.NewID(), "ci", []string{PermissionProductsRead, PermissionProductsWrite}, []string{"10.0.0.0/24", "192.168.1.7"}, time.Hour)
+	assert.Nil(t, err)
+
+	assert.False(t, key.IsExpired(time.Now()))
+	assert.True(t, key.IsExpired(time.Now().Add(time.Hour)))
+
+	assert.True(t, key.AllowsIP("10.0.0.42"))
+	assert.True(t, key.AllowsIP("192.168.1.7"))
+	assert.False(t, key.AllowsIP("192.168.1.8"))
+	assert.False(t, key.AllowsIP("invalid"))
+
+	assert.Equal(t, []string{PermissionProductsRead}, key.Permissions([]string{PermissionProductsRead, PermissionUsersManage}))
+
+	assert.False(t, key.IsRevoked())
+	key.Revoke()
+	assert.True(t, key.IsRevoked())
+}
+
+func TestAPIKeyTouch(t *testing.T) {
+	key, _, err := NewAPIKey(service.NewID(), "ci", []string{PermissionProductsRead}, nil, 0)
+	assert.Nil(t, err)
+	now := time.Now()
+	assert.True(t, key.Touch("10.0.0.1", now))
+	assert.False(t, key.Touch("10.0.0.1", now.Add(time.Second)))
+	assert.True(t, key.Touch("10.0.0.2", now.Add(time.Second)))
+	assert.True(t, key.Touch("10.0.0.2", now.Add(2*time.Minute)))
+	assert.Equal(t, "10.0.0.2", key.LastUsedIP)
+}
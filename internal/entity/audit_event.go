@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+// Actions recorded in the audit log of an organization.
+const (
+	AuditOrganizationCreated  = "organization.created"
+	AuditMemberInvited        = "member.invited"
+	AuditInvitationRevoked    = "invitation.revoked"
+	AuditInvitationAccepted   = "invitation.accepted"
+	AuditMemberRoleChanged    = "member.role_changed"
+	AuditMemberRemoved        = "member.removed"
+	AuditMemberLeft           = "member.left"
+	AuditOwnershipTransferred = "ownership.transferred"
+)
+
+// AuditEvent records a change made to an organization: who made it, to
+// whom, and the details of the change.
+type AuditEvent struct {
+	ID             service.ID        `json:"id"`
+	OrganizationID service.ID        `json:"organization_id" gorm:"index"`
+	ActorID        service.ID        `json:"actor_id"`
+	Action         string            `json:"action"`
+	TargetUserID   *service.ID       `json:"target_user_id,omitempty"`
+	Details        map[string]string `json:"details,omitempty" gorm:"serializer:json"`
+	CreatedAt      time.Time         `json:"created_at" gorm:"index"`
+}
+
+func NewAuditEvent(organizationID, actorID service.ID, action string, target *service.ID, details map[string]string) *AuditEvent {
+	return &AuditEvent{
+		ID:             service.NewID(),
+		OrganizationID: organizationID,
+		ActorID:        actorID,
+		Action:         action,
+		TargetUserID:   target,
+		Details:        details,
+		CreatedAt:      time.Now(),
+	}
+}
@@ -1,5 +1,31 @@
 package entity
 
+// Error is the body of every error response, a problem details object of
+// RFC 7807 served as application/problem+json.
 type Error struct {
+	// Type identifies the kind of problem. It is "urn:problem-type:"
+	// followed by Code.
+	Type string `json:"type"`
+	// Title is the reason phrase of Status.
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed.
+	Instance string `json:"instance,omitempty"`
+	// Code is a stable, machine-readable identifier of the problem.
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	// TraceID is the ID of the trace of the request, to find its spans.
+	TraceID string `json:"trace_id,omitempty"`
+	// Errors lists the fields of the request that are invalid.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a problem with one field of a request.
+type FieldError struct {
+	// Field is the path of the field, such as "name" or "address.city".
+	Field string `json:"field"`
+	// Rule is the rule the field breaks, such as "required" or "max_length".
+	Rule    string `json:"rule,omitempty"`
 	Message string `json:"message"`
 }
@@ -0,0 +1,117 @@
+package entity
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+// Grant types of the OAuth 2.0 token endpoint.
+const (
+	GrantTypePassword          = "password"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+var (
+	ErrOAuthClientNameIsRequired = errors.New("oauth client name is required")
+	ErrInvalidGrantType          = errors.New("invalid grant type")
+)
+
+var grantTypes = map[string]bool{
+	GrantTypePassword:          true,
+	GrantTypeClientCredentials: true,
+	GrantTypeRefreshToken:      true,
+}
+
+// OAuthClient is an application registered by a user to request tokens at
+// the OAuth 2.0 token endpoint. Its ID is the client_id and only the hash of
+// its secret is stored. Tokens of the client_credentials grant act on behalf
+// of the user who registered it, in the organization it was registered in.
+type OAuthClient struct {
+	ID     service.ID `json:"id"`
+	UserID service.ID `json:"user_id" gorm:"index"`
+	// OrganizationID is the organization the tokens of the client_credentials
+	// grant act in.
+	OrganizationID service.ID `json:"organization_id"`
+	Name           string     `json:"name"`
+	SecretHash     string     `json:"-"`
+	// Scopes are the permissions the client may request.
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	GrantTypes []string   `json:"grant_types" gorm:"serializer:json"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewOAuthClient registers a client and returns it along with its plain
+// secret, which is never stored.
+func NewOAuthClient(userID service.ID, name string, scopes, grantTypes []string) (*OAuthClient, string, error) {
+	client := &OAuthClient{
+		ID:         service.NewID(),
+		UserID:     userID,
+		Name:       strings.TrimSpace(name),
+		Scopes:     scopes,
+		GrantTypes: grantTypes,
+		CreatedAt:  time.Now(),
+	}
+	if err := client.Validate(); err != nil {
+		return nil, "", err
+	}
+	secret, err := service.NewToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	client.SecretHash = service.HashToken(secret)
+	return client, secret, nil
+}
+
+func (c *OAuthClient) Validate() error {
+	if c.Name == "" {
+		return ErrOAuthClientNameIsRequired
+	}
+	if len(c.Scopes) == 0 {
+		return ErrScopeIsRequired
+	}
+	for _, scope := range c.Scopes {
+		if !IsValidPermission(scope) {
+			return ErrInvalidPermission
+		}
+	}
+	if len(c.GrantTypes) == 0 {
+		return ErrInvalidGrantType
+	}
+	for _, grantType := range c.GrantTypes {
+		if !grantTypes[grantType] {
+			return ErrInvalidGrantType
+		}
+	}
+	return nil
+}
+
+// Authenticate reports whether secret is the client's secret and the client
+// was not revoked.
+func (c *OAuthClient) Authenticate(secret string) bool {
+	match := subtle.ConstantTimeCompare([]byte(service.HashToken(secret)), []byte(c.SecretHash)) == 1
+	return match && !c.IsRevoked()
+}
+
+func (c *OAuthClient) AllowsGrantType(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OAuthClient) Revoke() {
+	now := time.Now()
+	c.RevokedAt = &now
+}
+
+func (c *OAuthClient) IsRevoked() bool {
+	return c.RevokedAt != nil
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOAuthClient(t *testing.T) {
+	userID := service.NewID()
+	client, secret, err := NewOAuthClient(userID, " svc ", []string{PermissionProductsRead}, []string{GrantTypeClientCredentials})
+	assert.Nil(t, err)
+	assert.Equal(t, "svc", client.Name)
+	assert.Equal(t, userID, client.UserID)
+	assert.NotEqual(t, secret, client.SecretHash)
+	assert.True(t, client.Authenticate(secret))
+	assert.False(t, client.Authenticate(secret+"x"))
+	assert.True(t, client.AllowsGrantType(GrantTypeClientCredentials))
+	assert.False(t, client.AllowsGrantType(GrantTypePassword))
+
+	client.Revoke()
+	assert.True(t, client.IsRevoked())
+	assert.False(t, client.Authenticate(secret))
+}
+
+func TestNewOAuthClientValidation(t *testing.T) {
+	_, _, err := NewOAuthClient(service.NewID(), "", []string{PermissionProductsRead}, []string{GrantTypePassword})
+	assert.Equal(t, ErrOAuthClientNameIsRequired, err)
+	_, _, err = NewOAuthClient(service.NewID(), "svc", nil, []string{GrantTypePassword})
+	assert.Equal(t, ErrScopeIsRequired, err)
+	_, _, err = NewOAuthClient(service.NewID(), "svc", []string{PermissionProductsRead}, nil)
+	assert.Equal(t, ErrInvalidGrantType, err)
+	_, _, err = NewOAuthClient(service.NewID(), "svc", []string{PermissionProductsRead}, []string{"implicit"})
+	assert.Equal(t, ErrInvalidGrantType, err)
+}
+
+func TestGrantedOnly(t *testing.T) {
+	granted := []string{PermissionProductsRead, PermissionProductsWrite}
+	assert.Equal(t, []string{PermissionProductsRead}, GrantedOnly([]string{PermissionProductsRead, PermissionUsersManage}, granted))
+	assert.Nil(t, GrantedOnly([]string{PermissionUsersManage}, granted))
+	assert.Equal(t, []string{PermissionProductsRead}, ReadOnlyPermissions(granted))
+}
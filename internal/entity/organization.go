@@ -0,0 +1,197 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+// Roles of a member within an organization.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+var (
+	ErrOrganizationNameIsRequired = errors.New("organization name is required")
+	ErrInvalidOrgRole             = errors.New("invalid organization role")
+	ErrNotAMember                 = errors.New("user is not a member of the organization")
+	ErrAlreadyAMember             = errors.New("user is already a member of the organization")
+	ErrOwnerRole                  = errors.New("the owner role can only be given by transferring ownership")
+	ErrOwnerCannotLeave           = errors.New("the owner cannot leave or be removed, transfer ownership first")
+	ErrOwnerCannotBeDeleted       = errors.New("the owner of an organization with other members cannot be deleted, transfer ownership first")
+	ErrCannotManageMember         = errors.New("only the owner or an admin can manage members, and only the owner can manage admins")
+	ErrInvalidInvitation          = errors.New("invitation is invalid, expired or already used")
+	ErrInvitationNotPending       = errors.New("invitation was accepted or revoked in the meantime")
+	ErrInvitationEmailMismatch    = errors.New("invitation was sent to another email address")
+)
+
+var orgRoles = map[string]bool{
+	OrgRoleOwner:  true,
+	OrgRoleAdmin:  true,
+	OrgRoleMember: true,
+}
+
+// Organization is a tenant: the products of an organization are only seen
+// by its members.
+type Organization struct {
+	ID        service.ID `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func NewOrganization(name string) (*Organization, error) {
+	org := &Organization{
+		ID:        service.NewID(),
+		Name:      strings.TrimSpace(name),
+		CreatedAt: time.Now(),
+	}
+	if err := org.Validate(); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// PersonalOrganizationName is the name of the organization created for a
+// new user.
+func PersonalOrganizationName(user *User) string {
+	return user.Name + "'s organization"
+}
+
+func (o *Organization) Validate() error {
+	if o.Name == "" {
+		return ErrOrganizationNameIsRequired
+	}
+	return nil
+}
+
+// Membership makes a user a member of an organization with a role. An
+// organization has a single owner.
+type Membership struct {
+	ID             service.ID    `json:"id"`
+	OrganizationID service.ID    `json:"organization_id" gorm:"uniqueIndex:idx_membership"`
+	Organization   *Organization `json:"organization,omitempty"`
+	UserID         service.ID    `json:"user_id" gorm:"uniqueIndex:idx_membership;index"`
+	User           *User         `json:"user,omitempty"`
+	Role           string        `json:"role"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+func NewMembership(organizationID, userID service.ID, role string) (*Membership, error) {
+	membership := &Membership{
+		ID:             service.NewID(),
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           role,
+		CreatedAt:      time.Now(),
+	}
+	if err := membership.Validate(); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+func IsValidOrgRole(role string) bool {
+	return orgRoles[role]
+}
+
+func (m *Membership) Validate() error {
+	if !IsValidOrgRole(m.Role) {
+		return ErrInvalidOrgRole
+	}
+	return nil
+}
+
+func (m *Membership) IsOwner() bool {
+	return m.Role == OrgRoleOwner
+}
+
+// CanManageMembers reports whether the member may invite, change and
+// remove members.
+func (m *Membership) CanManageMembers() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleAdmin
+}
+
+// CanManage reports whether the member may change the role of target or
+// remove it. Admins only manage plain members, and the owner everyone else.
+func (m *Membership) CanManage(target *Membership) bool {
+	if m.ID == target.ID || target.IsOwner() {
+		return false
+	}
+	return m.IsOwner() || (m.Role == OrgRoleAdmin && target.Role == OrgRoleMember)
+}
+
+// CanGrant reports whether the member may give role to others, by inviting
+// them or changing their role. Nobody grants the owner role.
+func (m *Membership) CanGrant(role string) bool {
+	return role == OrgRoleMember || (role == OrgRoleAdmin && m.IsOwner())
+}
+
+// Invitation lets whoever receives it at Email join an organization with a
+// role, either with their existing account or by creating one.
+type Invitation struct {
+	ID             service.ID  `json:"id"`
+	OrganizationID service.ID  `json:"organization_id" gorm:"index"`
+	Email          string      `json:"email"`
+	Role           string      `json:"role"`
+	InvitedBy      service.ID  `json:"invited_by"`
+	ExpiresAt      time.Time   `json:"expires_at"`
+	AcceptedAt     *time.Time  `json:"accepted_at,omitempty"`
+	AcceptedBy     *service.ID `json:"accepted_by,omitempty"`
+	RevokedAt      *time.Time  `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+func NewInvitation(organizationID, invitedBy service.ID, email, role string, expiresIn time.Duration) (*Invitation, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if role == OrgRoleOwner {
+		return nil, ErrOwnerRole
+	}
+	if !IsValidOrgRole(role) {
+		return nil, ErrInvalidOrgRole
+	}
+	now := time.Now()
+	return &Invitation{
+		ID:             service.NewID(),
+		OrganizationID: organizationID,
+		Email:          email,
+		Role:           role,
+		InvitedBy:      invitedBy,
+		ExpiresAt:      now.Add(expiresIn),
+		CreatedAt:      now,
+	}, nil
+}
+
+// IsPending reports whether the invitation can still be accepted.
+func (i *Invitation) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// Accept makes user a member of the organization. The user has to be the
+// one the invitation was sent to.
+func (i *Invitation) Accept(user *User, now time.Time) (*Membership, error) {
+	if !i.IsPending(now) {
+		return nil, ErrInvalidInvitation
+	}
+	if user.Email != i.Email {
+		return nil, ErrInvitationEmailMismatch
+	}
+	membership, err := NewMembership(i.OrganizationID, user.ID, i.Role)
+	if err != nil {
+		return nil, err
+	}
+	i.AcceptedAt = &now
+	i.AcceptedBy = &user.ID
+	return membership, nil
+}
+
+func (i *Invitation) Revoke() {
+	now := time.Now()
+	i.RevokedAt = &now
+}
@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOrganization(t *testing.T) {
+	org, err := NewOrganization(" Acme ")
+	assert.Nil(t, err)
+	assert.Equal(t, "Acme", org.Name)
+	assert.NotEmpty(t, org.ID)
+
+	_, err = NewOrganization(" ")
+	assert.Equal(t, ErrOrganizationNameIsRequired, err)
+}
+
+func TestNewMembership(t *testing.T) {
+	orgID, userID := service.NewID(), service.NewID()
+	membership, err := NewMembership(orgID, userID, OrgRoleOwner)
+	assert.Nil(t, err)
+	assert.Equal(t, orgID, membership.OrganizationID)
+	assert.Equal(t, userID, membership.UserID)
+
+	_, err = NewMembership(orgID, userID, "guest")
+	assert.Equal(t, ErrInvalidOrgRole, err)
+}
+
+func TestMembershipCanManage(t *testing.T) {
+	orgID := service.NewID()
+	owner, _ := NewMembership(orgID, service.NewID(), OrgRoleOwner)
+	admin, _ := NewMembership(orgID, service.NewID(), OrgRoleAdmin)
+	otherAdmin, _ := NewMembership(orgID, service.NewID(), OrgRoleAdmin)
+	member, _ := NewMembership(orgID, service.NewID(), OrgRoleMember)
+
+	assert.True(t, owner.CanManage(admin))
+	assert.True(t, owner.CanManage(member))
+	assert.False(t, owner.CanManage(owner))
+	assert.True(t, admin.CanManage(member))
+	assert.False(t, admin.CanManage(otherAdmin))
+	assert.False(t, admin.CanManage(owner))
+	assert.False(t, member.CanManage(member))
+
+	assert.True(t, owner.CanGrant(OrgRoleAdmin))
+	assert.False(t, owner.CanGrant(OrgRoleOwner))
+	assert.True(t, admin.CanGrant(OrgRoleMember))
+	assert.False(t, admin.CanGrant(OrgRoleAdmin))
+	assert.True(t, admin.CanManageMembers())
+	assert.False(t, member.CanManageMembers())
+}
+
+func TestInvitation(t *testing.T) {
+	orgID := service.NewID()
+	_, err := NewInvitation(orgID, service.NewID(), "invitee@example.com", OrgRoleOwner, time.Hour)
+	assert.Equal(t, ErrOwnerRole, err)
+	_, err = NewInvitation(orgID, service.NewID(), "invitee@example.com", "guest", time.Hour)
+	assert.Equal(t, ErrInvalidOrgRole, err)
+	_, err = NewInvitation(orgID, service.NewID(), "not an email", OrgRoleMember, time.Hour)
+	assert.Equal(t, ErrInvalidEmail, err)
+
+	invitation, err := NewInvitation(orgID, service.NewID(), " Invitee@Example.com ", OrgRoleAdmin, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, "invitee@example.com", invitation.Email)
+	now := time.Now()
+	assert.True(t, invitation.IsPending(now))
+	assert.False(t, invitation.IsPending(now.Add(2*time.Hour)))
+
+	stranger, _ := NewUser("Stranger", "stranger@example.com", "123456")
+	_, err = invitation.Accept(stranger, now)
+	assert.Equal(t, ErrInvitationEmailMismatch, err)
+
+	invitee, _ := NewUser("Invitee", "invitee@example.com", "123456")
+	membership, err := invitation.Accept(invitee, now)
+	assert.Nil(t, err)
+	assert.Equal(t, orgID, membership.OrganizationID)
+	assert.Equal(t, invitee.ID, membership.UserID)
+	assert.Equal(t, OrgRoleAdmin, membership.Role)
+	assert.False(t, invitation.IsPending(now))
+	_, err = invitation.Accept(invitee, now)
+	assert.Equal(t, ErrInvalidInvitation, err)
+
+	revoked, _ := NewInvitation(orgID, service.NewID(), "invitee@example.com", OrgRoleMember, time.Hour)
+	revoked.Revoke()
+	_, err = revoked.Accept(invitee, now)
+	assert.Equal(t, ErrInvalidInvitation, err)
+}
@@ -0,0 +1,72 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diegopontes87/api/pkg/validation"
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordBreached = errors.New("password appears in a list of breached passwords")
+)
+
+// PasswordPolicy is the set of rules a new password must follow. Lengths are
+// counted in characters; a zero MaxLength means no limit.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]struct{}
+}
+
+// NewPasswordPolicy returns a policy that also rejects every password of the
+// breached list, compared case-insensitively.
+func NewPasswordPolicy(minLength, maxLength int, breached []string) *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		breached:  make(map[string]struct{}, len(breached)),
+	}
+	for _, password := range breached {
+		p.breached[strings.ToLower(password)] = struct{}{}
+	}
+	return p
+}
+
+func (p *PasswordPolicy) Validate(password string) error {
+	if password == "" {
+		return ErrPasswordIsRequired
+	}
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: it must have at least %d characters", ErrPasswordTooShort, p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: it must have at most %d characters", ErrPasswordTooLong, p.MaxLength)
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+// Check records the violation of the policy by the password of field in v.
+func (p *PasswordPolicy) Check(v *validation.Validator, field, password string) {
+	err := p.Validate(password)
+	rule := validation.RuleFormat
+	switch {
+	case errors.Is(err, ErrPasswordIsRequired):
+		rule = validation.RuleRequired
+	case errors.Is(err, ErrPasswordTooShort):
+		rule = validation.RuleMinLength
+	case errors.Is(err, ErrPasswordTooLong):
+		rule = validation.RuleMaxLength
+	case errors.Is(err, ErrPasswordBreached):
+		rule = RuleNotBreached
+	}
+	v.Check(err == nil, field, rule, err)
+}
@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := NewPasswordPolicy(8, 16, []string{"password1", "Qwerty123"})
+
+	assert.Nil(t, policy.Validate("correct horse"))
+	assert.Nil(t, policy.Validate("çãoçãoçã"))
+	assert.Equal(t, ErrPasswordIsRequired, policy.Validate(""))
+	assert.True(t, errors.Is(policy.Validate("a"), ErrPasswordTooShort))
+	assert.True(t, errors.Is(policy.Validate("abcdefghijklmnopq"), ErrPasswordTooLong))
+	assert.Equal(t, ErrPasswordBreached, policy.Validate("password1"))
+	assert.Equal(t, ErrPasswordBreached, policy.Validate("QWERTY123"))
+}
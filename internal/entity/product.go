@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/diegopontes87/api/pkg/service"
+	"github.com/diegopontes87/api/pkg/validation"
 )
 
 var (
@@ -12,14 +13,16 @@ var (
 	ErrInvalidId       = errors.New("invalid ID")
 	ErrNameIsRequired  = errors.New("name is required")
 	ErrPriceIsRequired = errors.New("price is required")
-	ErrInvalidPrice    = errors.New("invalid Prive")
+	ErrInvalidPrice    = errors.New("price must be positive")
 )
 
 type Product struct {
-	ID        service.ID `json:"id"`
-	Name      string     `json:"name"`
-	Price     float64    `json:"price"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID             service.ID `json:"id"`
+	Name           string     `json:"name"`
+	Price          float64    `json:"price"`
+	UserID         service.ID `json:"user_id" gorm:"index"`
+	OrganizationID service.ID `json:"organization_id" gorm:"index"`
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
 func NewProduct(name string, price float64) (*Product, error) {
@@ -37,21 +40,18 @@ func NewProduct(name string, price float64) (*Product, error) {
 	return product, nil
 }
 
+// IsOwnedBy reports whether the product was created by the given user.
+func (p *Product) IsOwnedBy(userID string) bool {
+	return userID != "" && p.UserID.String() == userID
+}
+
+// Validate returns every violation of the rules of the product as
+// validation.Errors.
 func (p *Product) Validate() error {
-	if p.ID.String() == "" {
-		return ErrIDIsRequired
-	}
-	if _, err := service.ParseID(p.ID.String()); err != nil {
-		return ErrInvalidId
-	}
-	if p.Name == "" {
-		return ErrNameIsRequired
-	}
-	if p.Price == 0 {
-		return ErrPriceIsRequired
-	}
-	if p.Price < 0 {
-		return ErrInvalidPrice
-	}
-	return nil
+	v := validation.New()
+	_, err := service.ParseID(p.ID.String())
+	v.Check(err == nil, "id", validation.RuleFormat, ErrInvalidId)
+	ValidateName(v, "name", p.Name)
+	ValidatePrice(v, "price", p.Price)
+	return v.Err()
 }
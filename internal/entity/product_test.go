@@ -1,8 +1,12 @@
 package entity
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/diegopontes87/api/pkg/validation"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,13 +22,29 @@ func TestNewProduct(t *testing.T) {
 func TestProductNameIsRequired(t *testing.T) {
 	p, err := NewProduct("", 10)
 	assert.Nil(t, p)
-	assert.Equal(t, ErrNameIsRequired, err)
+	assert.ErrorIs(t, err, ErrNameIsRequired)
 }
 
 func TestProductPriceIsRequired(t *testing.T) {
 	p, err := NewProduct("Product 1", 0)
 	assert.Nil(t, p)
-	assert.Equal(t, ErrPriceIsRequired, err)
+	assert.ErrorIs(t, err, ErrPriceIsRequired)
+}
+
+func TestProductValidateReportsEveryViolation(t *testing.T) {
+	p, err := NewProduct("Product\x00"+strings.Repeat("a", MaxNameLength), -1)
+	assert.Nil(t, p)
+	var violations validation.Errors
+	assert.True(t, errors.As(err, &violations))
+	assert.Equal(t, validation.Errors{
+		{Field: "name", Rule: validation.RuleMaxLength, Err: ErrNameTooLong},
+		{Field: "price", Rule: validation.RuleMin, Err: ErrInvalidPrice},
+	}, violations)
+
+	_, err = NewProduct("Product 1", MaxPrice+1)
+	assert.ErrorIs(t, err, ErrPriceTooHigh)
+	_, err = NewProduct("Product\n1", 10.05)
+	assert.ErrorIs(t, err, ErrInvalidName)
 }
 
 func TestProductValidate(t *testing.T) {
@@ -33,3 +53,13 @@ func TestProductValidate(t *testing.T) {
 	assert.NotNil(t, p)
 	assert.Nil(t, p.Validate())
 }
+
+func TestProductIsOwnedBy(t *testing.T) {
+	p, err := NewProduct("Product 1", 20)
+	assert.Nil(t, err)
+	assert.False(t, p.IsOwnedBy(""))
+	userID := service.NewID()
+	p.UserID = userID
+	assert.True(t, p.IsOwnedBy(userID.String()))
+	assert.False(t, p.IsOwnedBy(service.NewID().String()))
+}
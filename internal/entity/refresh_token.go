@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+var (
+	ErrRefreshTokenExpired = errors.New("refresh token is expired")
+	ErrRefreshTokenRevoked = errors.New("refresh token is revoked")
+)
+
+// RefreshToken is an opaque, long-lived token that can be exchanged for a new
+// access token. Only its hash is stored. Tokens obtained by rotating each
+// other share the same FamilyID.
+type RefreshToken struct {
+	ID        service.ID `json:"id"`
+	UserID    service.ID `json:"user_id" gorm:"index"`
+	FamilyID  service.ID `json:"family_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	// Scopes restricts the permissions of the access tokens the refresh
+	// token is exchanged for. Nil grants all of the user's permissions.
+	Scopes []string `json:"scopes,omitempty" gorm:"serializer:json"`
+	// ClientID is the OAuth client the token was issued to, if any.
+	ClientID string `json:"client_id,omitempty"`
+	// OrganizationID is the active organization of the access tokens the
+	// refresh token is exchanged for. Zero uses the user's first one.
+	OrganizationID service.ID `json:"organization_id"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// NewRefreshToken creates a refresh token in the given family and returns it
+// along with the plain token value, which is never stored.
+func NewRefreshToken(userID, familyID service.ID, expiresIn time.Duration) (*RefreshToken, string, error) {
+	token, err := service.NewToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	return &RefreshToken{
+		ID:        service.NewID(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: service.HashToken(token),
+		ExpiresAt: now.Add(expiresIn),
+		CreatedAt: now,
+	}, token, nil
+}
+
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t *RefreshToken) Revoke() {
+	now := time.Now()
+	t.RevokedAt = &now
+}
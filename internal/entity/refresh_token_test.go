@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	userID, familyID := service.NewID(), service.NewID()
+	token, plain, err := NewRefreshToken(userID, familyID, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, plain)
+	assert.Equal(t, service.HashToken(plain), token.TokenHash)
+	assert.NotEqual(t, plain, token.TokenHash)
+	assert.Equal(t, userID, token.UserID)
+	assert.Equal(t, familyID, token.FamilyID)
+	assert.False(t, token.IsExpired())
+	assert.False(t, token.IsRevoked())
+
+	token.Revoke()
+	assert.True(t, token.IsRevoked())
+}
+
+func TestRefreshTokenIsExpired(t *testing.T) {
+	token, _, err := NewRefreshToken(service.NewID(), service.NewID(), -time.Second)
+	assert.Nil(t, err)
+	assert.True(t, token.IsExpired())
+}
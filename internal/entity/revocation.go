@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+// RevokedToken is a denylist entry for a single access token, identified by
+// its jti claim. It can be removed once the token itself has expired.
+type RevokedToken struct {
+	JTI       string     `json:"jti" gorm:"primaryKey"`
+	UserID    service.ID `json:"user_id" gorm:"index"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserTokenRevocation invalidates every access token of a user issued before
+// RevokedAt. It can be removed once all of those tokens have expired.
+type UserTokenRevocation struct {
+	UserID    service.ID `json:"user_id" gorm:"primaryKey"`
+	RevokedAt time.Time  `json:"revoked_at"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+}
+
+func NewRevokedToken(jti string, userID service.ID, expiresAt time.Time) *RevokedToken {
+	return &RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewUserTokenRevocation revokes the user's tokens issued before the current
+// second. maxTokenAge is how long an access token may be accepted, leeway
+// included, after which the entry is no longer needed.
+func NewUserTokenRevocation(userID service.ID, maxTokenAge time.Duration) *UserTokenRevocation {
+	now := time.Now()
+	return &UserTokenRevocation{
+		UserID:    userID,
+		RevokedAt: now.Truncate(time.Second),
+		ExpiresAt: now.Add(maxTokenAge),
+	}
+}
+
+// Covers reports whether a token issued at issuedAt is revoked by this entry.
+// JWT timestamps have second precision, so tokens issued during the second
+// the revocation happened, such as the ones of a sign-in right after a
+// password reset, are kept.
+func (r *UserTokenRevocation) Covers(issuedAt time.Time) bool {
+	return issuedAt.Before(r.RevokedAt.Truncate(time.Second))
+}
@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserTokenRevocationCovers(t *testing.T) {
+	revocation := NewUserTokenRevocation(service.NewID(), time.Minute)
+	assert.True(t, revocation.Covers(time.Now().Add(-time.Hour)))
+	assert.True(t, revocation.Covers(time.Time{}))
+	assert.Equal(t, revocation.RevokedAt.Truncate(time.Second), revocation.RevokedAt)
+	assert.True(t, revocation.Covers(revocation.RevokedAt.Add(-time.Second)))
+	assert.False(t, revocation.Covers(revocation.RevokedAt))
+	assert.False(t, revocation.Covers(revocation.RevokedAt.Add(time.Second)))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), revocation.ExpiresAt, time.Second)
+}
@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+const (
+	PermissionProductsRead  = "products:read"
+	PermissionProductsWrite = "products:write"
+	PermissionUsersManage   = "users:manage"
+)
+
+var (
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrInvalidPermission = errors.New("invalid permission")
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin:  {PermissionProductsRead, PermissionProductsWrite, PermissionUsersManage},
+	RoleEditor: {PermissionProductsRead, PermissionProductsWrite},
+	RoleViewer: {PermissionProductsRead},
+}
+
+var permissions = map[string]bool{
+	PermissionProductsRead:  true,
+	PermissionProductsWrite: true,
+	PermissionUsersManage:   true,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func IsValidPermission(permission string) bool {
+	return permissions[permission]
+}
+
+// RolePermissions returns the permissions granted by a role.
+func RolePermissions(role string) []string {
+	return rolePermissions[role]
+}
+
+// ReadOnlyPermissions keeps the read permissions of a list.
+func ReadOnlyPermissions(permissions []string) []string {
+	var readOnly []string
+	for _, p := range permissions {
+		if strings.HasSuffix(p, ":read") {
+			readOnly = append(readOnly, p)
+		}
+	}
+	return readOnly
+}
+
+// GrantedOnly keeps the permissions of a list that are in granted.
+func GrantedOnly(permissions, granted []string) []string {
+	var kept []string
+	for _, p := range permissions {
+		for _, g := range granted {
+			if p == g {
+				kept = append(kept, p)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// mergePermissions returns the sorted union of the given permission lists.
+func mergePermissions(lists ...[]string) []string {
+	set := map[string]bool{}
+	for _, list := range lists {
+		for _, p := range list {
+			set[p] = true
+		}
+	}
+	merged := make([]string, 0, len(set))
+	for p := range set {
+		merged = append(merged, p)
+	}
+	sort.Strings(merged)
+	return merged
+}
@@ -1,31 +1,416 @@
 package entity
 
 import (
+	"crypto/rand"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/password"
 	"github.com/diegopontes87/api/pkg/service"
+	"github.com/diegopontes87/api/pkg/totp"
+	"github.com/diegopontes87/api/pkg/validation"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const maxEmailLength = 254
+
+var (
+	// passwordHasher hashes new passwords with argon2id and still verifies
+	// the bcrypt hashes of passwords set before it was introduced.
+	passwordHasher = password.NewHasher(password.DefaultArgon2id(), password.Bcrypt{Cost: bcrypt.DefaultCost})
+	// unknownUserPasswordHash is compared against when a password is checked
+	// for an email that is not registered, so the check takes as long as for
+	// a registered one.
+	unknownUserPasswordHash, _ = passwordHasher.Hash("unknown user password")
+)
+
+// SetPasswordHasher replaces the hasher of user passwords. Hashes made by
+// other schemes or parameters are replaced on the next successful sign-in.
+func SetPasswordHasher(hasher *password.Hasher) error {
+	hash, err := hasher.Hash("unknown user password")
+	if err != nil {
+		return err
+	}
+	passwordHasher = hasher
+	unknownUserPasswordHash = hash
+	return nil
+}
+
+var (
+	ErrEmailIsRequired    = errors.New("email is required")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrPasswordIsRequired = errors.New("password is required")
+	ErrEmailAlreadyExists = errors.New("email is already registered")
+	ErrUserDisabled       = errors.New("user account is disabled")
+	ErrEmailNotVerified   = errors.New("email address is not verified")
+	// ErrPasswordResetRequired is returned when an administrator forced a
+	// password reset, which the user completes with the emailed token.
+	ErrPasswordResetRequired = errors.New("password has to be reset, use the token sent by email or request a new one")
+
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enrolled")
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor authentication code")
+)
+
+// Access granted to users who did not verify their email address yet.
+const (
+	UnverifiedAccessFull     = "full"
+	UnverifiedAccessReadOnly = "read_only"
+	UnverifiedAccessBlocked  = "blocked"
+)
+
+const (
+	recoveryCodeCount = 10
+	// totpSkew is the number of time steps a code is still accepted before
+	// or after its own, to bear with clock drift.
+	totpSkew = 1
+)
+
 type User struct {
-	ID       service.ID `json:"id"`
-	Name     string     `json:"name"`
-	Password string     `json:"-"`
-	Email    string     `json:"email"`
+	ID              service.ID `json:"id"`
+	Name            string     `json:"name"`
+	Password        string     `json:"-"`
+	Email           string     `json:"email" gorm:"uniqueIndex"`
+	Role            string     `json:"role"`
+	Permissions     []string   `json:"permissions" gorm:"serializer:json"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// TOTPSecret is set when the user enrolls in two-factor authentication,
+	// which is enforced once TwoFactorEnabledAt is set as well.
+	TOTPSecret         string     `json:"-"`
+	TOTPLastCounter    int64      `json:"-"`
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at,omitempty"`
+	// RecoveryCodes holds the hashes of the unused recovery codes.
+	RecoveryCodes []string `json:"-" gorm:"serializer:json"`
+	// DeletionScheduledAt is set when the user asked to delete the account.
+	// The account is removed once that moment has passed.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" gorm:"index"`
+	// DisabledAt is set when an administrator disabled the account, which
+	// can then neither sign in nor use its tokens and API keys.
+	DisabledAt *time.Time `json:"disabled_at,omitempty" gorm:"index"`
+	// PasswordResetRequiredAt is set when an administrator forced a password
+	// reset. The current password is refused until a new one is set.
+	PasswordResetRequiredAt *time.Time `json:"password_reset_required_at,omitempty"`
+
+	passwordRehashed bool
 }
 
 func NewUser(name, email, password string) (*User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	email, err := NormalizeEmail(email)
 	if err != nil {
 		return nil, err
 	}
-	return &User{
-		ID:       service.NewID(),
-		Name:     name,
-		Password: string(hash),
-		Email:    email,
-	}, nil
+	user := &User{
+		ID:    service.NewID(),
+		Name:  strings.TrimSpace(name),
+		Email: email,
+		Role:  RoleEditor,
+	}
+	if err := user.SetPassword(password); err != nil {
+		return nil, err
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// NormalizeEmail parses a bare RFC 5322 address, such as "john@example.com",
+// and returns it lowercased.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", ErrEmailIsRequired
+	}
+	if len(email) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+// Validate returns every violation of the rules of the user as
+// validation.Errors.
+func (u *User) Validate() error {
+	v := validation.New()
+	_, err := service.ParseID(u.ID.String())
+	v.Check(err == nil, "id", validation.RuleFormat, ErrInvalidId)
+	ValidateName(v, "name", u.Name)
+	ValidateEmail(v, "email", u.Email)
+	// The email is stored normalized.
+	email, _ := NormalizeEmail(u.Email)
+	v.Check(email == u.Email, "email", validation.RuleFormat, ErrInvalidEmail)
+	v.Check(u.Password != "", "password", validation.RuleRequired, ErrPasswordIsRequired)
+	return v.Err()
+}
+
+// SetPassword replaces the password hash with the hash of password.
+func (u *User) SetPassword(password string) error {
+	if password == "" {
+		return ErrPasswordIsRequired
+	}
+	hash, err := passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
+	u.PasswordResetRequiredAt = nil
+	return nil
+}
+
+// SetEmail replaces the email with its normalized form. A new address has
+// to be verified again.
+func (u *User) SetEmail(email string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if email != u.Email {
+		u.Email = email
+		u.EmailVerifiedAt = nil
+	}
+	return nil
+}
+
+func (u *User) VerifyEmail() {
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) ScheduleDeletion(gracePeriod time.Duration) {
+	deleteAt := time.Now().Add(gracePeriod)
+	u.DeletionScheduledAt = &deleteAt
+}
+
+func (u *User) CancelDeletion() {
+	u.DeletionScheduledAt = nil
+}
+
+func (u *User) IsDeletionScheduled() bool {
+	return u.DeletionScheduledAt != nil
+}
+
+func (u *User) Disable() {
+	if u.DisabledAt == nil {
+		now := time.Now()
+		u.DisabledAt = &now
+	}
+}
+
+func (u *User) Enable() {
+	u.DisabledAt = nil
+}
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// RequirePasswordReset refuses the current password until SetPassword
+// replaces it.
+func (u *User) RequirePasswordReset() {
+	now := time.Now()
+	u.PasswordResetRequiredAt = &now
 }
 
+func (u *User) IsPasswordResetRequired() bool {
+	return u.PasswordResetRequiredAt != nil
+}
+
+// CheckAccess refuses access to the users an administrator disabled or
+// required to reset their password, and to the users who did not verify
+// their email address when unverifiedAccess blocks them.
+func (u *User) CheckAccess(unverifiedAccess string) error {
+	if u.IsDisabled() {
+		return ErrUserDisabled
+	}
+	if u.IsPasswordResetRequired() {
+		return ErrPasswordResetRequired
+	}
+	if !u.IsEmailVerified() && unverifiedAccess == UnverifiedAccessBlocked {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// ValidatePassword reports whether password is the user's. When the stored
+// hash is outdated it is replaced by a hash of the current hasher, and
+// PasswordRehashed reports that the user has to be saved.
 func (u *User) ValidatePassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	ok, rehash, err := passwordHasher.Verify(u.Password, password)
+	if err != nil || !ok {
+		return false
+	}
+	if rehash {
+		if hash, err := passwordHasher.Hash(password); err == nil {
+			u.Password = hash
+			u.passwordRehashed = true
+		}
+	}
+	return true
+}
+
+// PasswordRehashed reports whether ValidatePassword replaced the password
+// hash.
+func (u *User) PasswordRehashed() bool {
+	return u.passwordRehashed
+}
+
+// ValidateUnknownUserPassword spends the time ValidatePassword takes and
+// always fails. It is used when no user matches the given email.
+func ValidateUnknownUserPassword(password string) bool {
+	passwordHasher.Verify(unknownUserPasswordHash, password)
+	return false
+}
+
+// EnrollTwoFactor generates a new TOTP secret. Two-factor authentication is
+// enforced only after EnableTwoFactor confirms the user set it up.
+func (u *User) EnrollTwoFactor() error {
+	if u.IsTwoFactorEnabled() {
+		return ErrTwoFactorEnabled
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return err
+	}
+	u.TOTPSecret = secret
+	u.TOTPLastCounter = 0
+	return nil
+}
+
+// EnableTwoFactor checks a code of the enrolled secret and returns the
+// recovery codes, which are only stored hashed.
+func (u *User) EnableTwoFactor(code string, now time.Time) ([]string, error) {
+	if u.IsTwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+	if u.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if !u.validateTOTP(code, now) {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = service.HashToken(code)
+	}
+	u.RecoveryCodes = hashes
+	u.TwoFactorEnabledAt = &now
+	return codes, nil
+}
+
+// VerifyTwoFactor checks a TOTP code or a recovery code. A TOTP code can only
+// be used once and a recovery code is consumed.
+func (u *User) VerifyTwoFactor(code string, now time.Time) error {
+	if !u.IsTwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+	if u.validateTOTP(code, now) {
+		return nil
+	}
+	hash := service.HashToken(normalizeRecoveryCode(code))
+	for i, h := range u.RecoveryCodes {
+		if h == hash {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+func (u *User) DisableTwoFactor() {
+	u.TOTPSecret = ""
+	u.TOTPLastCounter = 0
+	u.TwoFactorEnabledAt = nil
+	u.RecoveryCodes = nil
+}
+
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil
+}
+
+// validateTOTP refuses the codes of time steps that were already used.
+func (u *User) validateTOTP(code string, now time.Time) bool {
+	counter, ok := totp.Validate(u.TOTPSecret, code, now, totpSkew)
+	if !ok || counter <= u.TOTPLastCounter {
+		return false
+	}
+	u.TOTPLastCounter = counter
+	return true
+}
+
+// newRecoveryCode returns a code such as "k3x9p-2mq7d".
+func newRecoveryCode() (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// SetRole assigns a role and a list of extra permissions granted on top of it.
+func (u *User) SetRole(role string, permissions []string) error {
+	if !IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	for _, p := range permissions {
+		if !IsValidPermission(p) {
+			return ErrInvalidPermission
+		}
+	}
+	u.Role = role
+	u.Permissions = permissions
+	return nil
+}
+
+// GrantedPermissions returns the permissions of the user's role together
+// with the ones granted to the user directly.
+func (u *User) GrantedPermissions() []string {
+	return mergePermissions(RolePermissions(u.Role), u.Permissions)
+}
+
+// AccessPermissions returns the permissions the user's credentials carry:
+// the granted ones, reduced to reading when the user did not verify their
+// email address and unverifiedAccess is read only.
+func (u *User) AccessPermissions(unverifiedAccess string) []string {
+	permissions := u.GrantedPermissions()
+	if !u.IsEmailVerified() && unverifiedAccess == UnverifiedAccessReadOnly {
+		permissions = ReadOnlyPermissions(permissions)
+	}
+	return permissions
+}
+
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.GrantedPermissions() {
+		if p == permission {
+			return true
+		}
+	}
+	return false
 }
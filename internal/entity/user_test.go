@@ -1,9 +1,13 @@
 package entity
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/diegopontes87/api/pkg/totp"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestNewUser(t *testing.T) {
@@ -23,3 +27,203 @@ func TestUser_ValidatePassword(t *testing.T) {
 	assert.False(t, user.ValidatePassword("1234567"))
 	assert.NotEqual(t, "123456", user.Password)
 }
+
+func TestUser_ValidatePasswordRehashesBcrypt(t *testing.T) {
+	user, err := NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(user.Password, "$argon2id$"))
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	user.Password = string(hash)
+	assert.False(t, user.ValidatePassword("1234567"))
+	assert.False(t, user.PasswordRehashed())
+	assert.True(t, user.ValidatePassword("123456"))
+	assert.True(t, user.PasswordRehashed())
+	assert.True(t, strings.HasPrefix(user.Password, "$argon2id$"))
+	assert.True(t, user.ValidatePassword("123456"))
+}
+
+// Passwords are not truncated at the 72 bytes bcrypt hashes.
+func TestUser_ValidateLongPassword(t *testing.T) {
+	password := strings.Repeat("a", 80)
+	user, err := NewUser("Diego", "diego@gmail.com", password)
+	assert.Nil(t, err)
+	assert.True(t, user.ValidatePassword(password))
+	assert.False(t, user.ValidatePassword(strings.Repeat("a", 72)))
+}
+
+func TestUser_SetRole(t *testing.T) {
+	user, err := NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, user.Role)
+	assert.False(t, user.HasPermission(PermissionUsersManage))
+
+	assert.Equal(t, ErrInvalidRole, user.SetRole("owner", nil))
+	assert.Equal(t, ErrInvalidPermission, user.SetRole(RoleViewer, []string{"products:delete"}))
+	assert.Equal(t, RoleEditor, user.Role)
+
+	assert.Nil(t, user.SetRole(RoleViewer, []string{PermissionUsersManage}))
+	assert.Equal(t, []string{PermissionProductsRead, PermissionUsersManage}, user.GrantedPermissions())
+	assert.False(t, user.HasPermission(PermissionProductsWrite))
+	assert.True(t, user.HasPermission(PermissionUsersManage))
+}
+
+func TestNewUserValidation(t *testing.T) {
+	user, err := NewUser("", "diego@gmail.com", "123456")
+	assert.Nil(t, user)
+	assert.ErrorIs(t, err, ErrNameIsRequired)
+
+	user, err = NewUser("Diego", "", "123456")
+	assert.Nil(t, user)
+	assert.Equal(t, ErrEmailIsRequired, err)
+
+	user, err = NewUser("Diego", "diego@gmail.com", "")
+	assert.Nil(t, user)
+	assert.Equal(t, ErrPasswordIsRequired, err)
+
+	for _, email := range []string{"diego", "diego@", "@gmail.com", "Diego <diego@gmail.com>", "diego@gmail.com, ana@gmail.com"} {
+		user, err = NewUser("Diego", email, "123456")
+		assert.Nil(t, user)
+		assert.Equal(t, ErrInvalidEmail, err, email)
+	}
+}
+
+func TestNewUserNormalizesEmail(t *testing.T) {
+	user, err := NewUser(" Diego ", "  Diego@Gmail.COM ", "123456")
+	assert.Nil(t, err)
+	assert.Equal(t, "Diego", user.Name)
+	assert.Equal(t, "diego@gmail.com", user.Email)
+	assert.Nil(t, user.Validate())
+
+	user.Email = "Diego@gmail.com"
+	assert.ErrorIs(t, user.Validate(), ErrInvalidEmail)
+}
+
+func TestUser_SetPassword(t *testing.T) {
+	user, err := NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, err)
+	assert.Equal(t, ErrPasswordIsRequired, user.SetPassword(""))
+	assert.True(t, user.ValidatePassword("123456"))
+
+	assert.Nil(t, user.SetPassword("654321"))
+	assert.True(t, user.ValidatePassword("654321"))
+	assert.False(t, user.ValidatePassword("123456"))
+}
+
+func TestUser_ScheduleDeletion(t *testing.T) {
+	user, err := NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, err)
+	assert.False(t, user.IsDeletionScheduled())
+
+	user.ScheduleDeletion(time.Hour)
+	assert.True(t, user.IsDeletionScheduled())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *user.DeletionScheduledAt, time.Second)
+
+	user.CancelDeletion()
+	assert.False(t, user.IsDeletionScheduled())
+}
+
+func TestUser_SetEmailResetsVerification(t *testing.T) {
+	user, err := NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, err)
+	assert.False(t, user.IsEmailVerified())
+
+	user.VerifyEmail()
+	assert.True(t, user.IsEmailVerified())
+
+	assert.Nil(t, user.SetEmail(" Diego@Gmail.com "))
+	assert.True(t, user.IsEmailVerified())
+
+	assert.Equal(t, ErrInvalidEmail, user.SetEmail("invalid"))
+	assert.Nil(t, user.SetEmail("other@gmail.com"))
+	assert.Equal(t, "other@gmail.com", user.Email)
+	assert.False(t, user.IsEmailVerified())
+}
+
+func TestValidateUnknownUserPassword(t *testing.T) {
+	assert.False(t, ValidateUnknownUserPassword("unknown user password"))
+	assert.False(t, ValidateUnknownUserPassword(""))
+}
+
+func TestUser_TwoFactor(t *testing.T) {
+	user, err := NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, err)
+	now := time.Now()
+	_, err = user.EnableTwoFactor("000000", now)
+	assert.Equal(t, ErrTwoFactorNotEnrolled, err)
+
+	assert.Nil(t, user.EnrollTwoFactor())
+	assert.NotEmpty(t, user.TOTPSecret)
+	assert.False(t, user.IsTwoFactorEnabled())
+	assert.Equal(t, ErrTwoFactorNotEnabled, user.VerifyTwoFactor("000000", now))
+
+	code, _ := totp.Code(user.TOTPSecret, totp.Counter(now))
+	codes, err := user.EnableTwoFactor(code, now)
+	assert.Nil(t, err)
+	assert.True(t, user.IsTwoFactorEnabled())
+	assert.Len(t, codes, 10)
+	assert.Len(t, user.RecoveryCodes, 10)
+	assert.NotContains(t, user.RecoveryCodes, codes[0])
+	assert.Equal(t, ErrTwoFactorEnabled, user.EnrollTwoFactor())
+
+	// A code cannot be used twice.
+	assert.Equal(t, ErrInvalidTwoFactorCode, user.VerifyTwoFactor(code, now))
+	next, _ := totp.Code(user.TOTPSecret, totp.Counter(now)+1)
+	assert.Nil(t, user.VerifyTwoFactor(next, now.Add(totp.Period)))
+
+	assert.Nil(t, user.VerifyTwoFactor(strings.ToUpper(codes[0]), now))
+	assert.Len(t, user.RecoveryCodes, 9)
+	assert.Equal(t, ErrInvalidTwoFactorCode, user.VerifyTwoFactor(codes[0], now))
+
+	user.DisableTwoFactor()
+	assert.False(t, user.IsTwoFactorEnabled())
+	assert.Empty(t, user.TOTPSecret)
+}
+
+func TestUser_Disable(t *testing.T) {
+	user, _ := NewUser("John Doe", "j@j.com", "123456")
+	assert.False(t, user.IsDisabled())
+
+	user.Disable()
+	assert.True(t, user.IsDisabled())
+	disabledAt := user.DisabledAt
+	user.Disable()
+	assert.Equal(t, disabledAt, user.DisabledAt)
+
+	user.Enable()
+	assert.False(t, user.IsDisabled())
+}
+
+func TestUser_RequirePasswordReset(t *testing.T) {
+	user, _ := NewUser("John Doe", "j@j.com", "123456")
+	assert.False(t, user.IsPasswordResetRequired())
+
+	user.RequirePasswordReset()
+	assert.True(t, user.IsPasswordResetRequired())
+	assert.Nil(t, user.SetPassword("654321"))
+	assert.False(t, user.IsPasswordResetRequired())
+}
+
+func TestUser_CheckAccess(t *testing.T) {
+	user, _ := NewUser("John Doe", "j@j.com", "123456")
+	assert.Nil(t, user.CheckAccess(UnverifiedAccessReadOnly))
+	assert.Equal(t, ErrEmailNotVerified, user.CheckAccess(UnverifiedAccessBlocked))
+
+	user.VerifyEmail()
+	assert.Nil(t, user.CheckAccess(UnverifiedAccessBlocked))
+	user.RequirePasswordReset()
+	assert.Equal(t, ErrPasswordResetRequired, user.CheckAccess(UnverifiedAccessFull))
+	user.Disable()
+	assert.Equal(t, ErrUserDisabled, user.CheckAccess(UnverifiedAccessFull))
+}
+
+func TestUser_AccessPermissions(t *testing.T) {
+	user, _ := NewUser("John Doe", "j@j.com", "123456")
+	user.SetRole(RoleEditor, nil)
+	assert.Equal(t, user.GrantedPermissions(), user.AccessPermissions(UnverifiedAccessFull))
+	assert.Equal(t, ReadOnlyPermissions(user.GrantedPermissions()), user.AccessPermissions(UnverifiedAccessReadOnly))
+	assert.NotEqual(t, user.GrantedPermissions(), user.AccessPermissions(UnverifiedAccessReadOnly))
+
+	user.VerifyEmail()
+	assert.Equal(t, user.GrantedPermissions(), user.AccessPermissions(UnverifiedAccessReadOnly))
+}
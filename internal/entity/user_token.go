@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	// TokenPurposeTwoFactorChallenge tokens are returned by the sign-in of
+	// users with two-factor authentication, instead of being emailed.
+	TokenPurposeTwoFactorChallenge = "two_factor_challenge"
+)
+
+var ErrInvalidUserToken = errors.New("token is invalid or expired")
+
+// UserToken is a single-use token, usually sent to a user's email address to
+// prove they own it. Only its hash is stored.
+type UserToken struct {
+	ID        service.ID `json:"id"`
+	UserID    service.ID `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	// Email is the address an emailed token was sent to, whose ownership
+	// it proves.
+	Email     string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewUserToken creates a token for purpose and returns it along with the
+// plain token value, which is never stored.
+func NewUserToken(userID service.ID, purpose string, expiresIn time.Duration) (*UserToken, string, error) {
+	token, err := service.NewToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	return &UserToken{
+		ID:        service.NewID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: service.HashToken(token),
+		ExpiresAt: now.Add(expiresIn),
+		CreatedAt: now,
+	}, token, nil
+}
+
+// IsUsable reports whether the token was neither used nor is expired.
+func (t *UserToken) IsUsable() bool {
+	return t.UsedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+func (t *UserToken) Use() {
+	now := time.Now()
+	t.UsedAt = &now
+}
+
+// IsSentTo reports whether the token was emailed to email, so that a token
+// sent to a previous address of a user does not prove the current one.
+func (t *UserToken) IsSentTo(email string) bool {
+	return t.Email != "" && t.Email == email
+}
@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUserToken(t *testing.T) {
+	userID := service.NewID()
+	token, plain, err := NewUserToken(userID, TokenPurposePasswordReset, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, plain)
+	assert.Equal(t, service.HashToken(plain), token.TokenHash)
+	assert.Equal(t, userID, token.UserID)
+	assert.Equal(t, TokenPurposePasswordReset, token.Purpose)
+	assert.True(t, token.IsUsable())
+
+	token.Use()
+	assert.False(t, token.IsUsable())
+}
+
+func TestUserTokenExpired(t *testing.T) {
+	token, _, err := NewUserToken(service.NewID(), TokenPurposeEmailVerification, -time.Second)
+	assert.Nil(t, err)
+	assert.False(t, token.IsUsable())
+}
+
+func TestUserTokenIsSentTo(t *testing.T) {
+	token, _, err := NewUserToken(service.NewID(), TokenPurposeEmailVerification, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, token.IsSentTo(""))
+	token.Email = "john@example.com"
+	assert.True(t, token.IsSentTo("john@example.com"))
+	assert.False(t, token.IsSentTo("jane@example.com"))
+}
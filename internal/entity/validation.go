@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/diegopontes87/api/pkg/validation"
+)
+
+// Limits of the fields shared by products and users.
+const (
+	MaxNameLength = 100
+	MaxPrice      = 1000000
+)
+
+// RuleNotBreached is broken by passwords of the breached list.
+const RuleNotBreached = "not_breached"
+
+var (
+	ErrNameTooLong  = fmt.Errorf("name must have at most %d characters", MaxNameLength)
+	ErrInvalidName  = errors.New("name must not contain control characters")
+	ErrPriceTooHigh = fmt.Errorf("price must be at most %d", MaxPrice)
+	ErrEmailTooLong = fmt.Errorf("email must have at most %d characters", maxEmailLength)
+)
+
+// ValidateName checks the name of a product or of a user.
+func ValidateName(v *validation.Validator, field, name string) {
+	v.Check(validation.NotBlank(name), field, validation.RuleRequired, ErrNameIsRequired)
+	v.Check(validation.MaxLength(name, MaxNameLength), field, validation.RuleMaxLength, ErrNameTooLong)
+	v.Check(validation.Printable(name), field, validation.RuleFormat, ErrInvalidName)
+}
+
+// ValidatePrice checks the price of a product.
+func ValidatePrice(v *validation.Validator, field string, price float64) {
+	v.Check(price != 0, field, validation.RuleRequired, ErrPriceIsRequired)
+	v.Check(price > 0, field, validation.RuleMin, ErrInvalidPrice)
+	v.Check(price <= MaxPrice, field, validation.RuleMax, ErrPriceTooHigh)
+}
+
+// ValidateEmail checks an email address as NormalizeEmail accepts it.
+func ValidateEmail(v *validation.Validator, field, email string) {
+	v.Check(validation.NotBlank(email), field, validation.RuleRequired, ErrEmailIsRequired)
+	v.Check(len(strings.TrimSpace(email)) <= maxEmailLength, field, validation.RuleMaxLength, ErrEmailTooLong)
+	_, err := NormalizeEmail(email)
+	v.Check(err == nil, field, validation.RuleFormat, ErrInvalidEmail)
+}
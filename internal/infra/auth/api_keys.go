@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+)
+
+var (
+	ErrAPIKeyExpired      = errors.New("api key is expired")
+	ErrAPIKeyIPNotAllowed = errors.New("api key is not allowed from this address")
+)
+
+// APIKeyAuthenticator checks the API keys machine clients send instead of
+// an access token.
+type APIKeyAuthenticator struct {
+	Keys  database.APIKeyDBInterface
+	Users database.UserDBInterface
+	// UnverifiedAccess is the access granted to the keys of users who did
+	// not verify their email address, one of the entity.UnverifiedAccess
+	// values, as for their tokens.
+	UnverifiedAccess string
+}
+
+func NewAPIKeyAuthenticator(keys database.APIKeyDBInterface, users database.UserDBInterface, unverifiedAccess string) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{Keys: keys, Users: users, UnverifiedAccess: unverifiedAccess}
+}
+
+// Authenticate returns the key matching plain, used from ip, and the user it
+// belongs to, provided the user may still sign in. Using a key records it as
+// its last use.
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, plain, ip string, now time.Time) (*entity.APIKey, *entity.User, error) {
+	prefix, err := entity.ParseAPIKeyPrefix(plain)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := a.Keys.WithContext(ctx).FindByPrefix(prefix)
+	if err != nil || !key.Matches(plain) || key.IsRevoked() {
+		return nil, nil, entity.ErrInvalidAPIKey
+	}
+	if key.IsExpired(now) {
+		return nil, nil, ErrAPIKeyExpired
+	}
+	if !key.AllowsIP(ip) {
+		return nil, nil, ErrAPIKeyIPNotAllowed
+	}
+	user, err := a.Users.WithContext(ctx).FindByID(key.UserID.String())
+	if err != nil || user.IsDeletionScheduled() {
+		return nil, nil, entity.ErrInvalidAPIKey
+	}
+	if err := user.CheckAccess(a.UnverifiedAccess); err != nil {
+		return nil, nil, err
+	}
+	if key.Touch(ip, now) {
+		if err := a.Keys.WithContext(ctx).Update(key); err != nil {
+			return nil, nil, err
+		}
+	}
+	return key, user, nil
+}
+
+// Permissions returns the permissions of a key of user: its scopes that the
+// user still holds.
+func (a *APIKeyAuthenticator) Permissions(key *entity.APIKey, user *entity.User) []string {
+	return key.Permissions(user.AccessPermissions(a.UnverifiedAccess))
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+var (
+	ErrInvalidToken        = errors.New("token is malformed or its signature is invalid")
+	ErrTokenExpired        = errors.New("token is expired")
+	ErrTokenNoExpiration   = errors.New("token has no expiration")
+	ErrTokenNotYetValid    = errors.New("token is not valid yet")
+	ErrTokenIssuedInFuture = errors.New("token was issued in the future")
+	ErrInvalidIssuer       = errors.New("token issuer is invalid")
+	ErrInvalidAudience     = errors.New("token audience is invalid")
+	ErrInvalidTokenType    = errors.New("token type is invalid")
+)
+
+// TokenOptions holds the registered claims every access token is issued with
+// and verified against. Leeway is the clock skew tolerated on time claims.
+type TokenOptions struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+	// Type is the typ claim of tokens other than access tokens, which have
+	// none. It is checked whether or not an audience is configured, so one
+	// kind of token is never accepted as another.
+	Type string
+}
+
+// NewClaims returns the registered claims of a token issued now for subject.
+func (o TokenOptions) NewClaims(subject string, now time.Time, expiresIn time.Duration) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": subject,
+		"jti": service.NewID().String(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(expiresIn).Unix(),
+	}
+	if o.Issuer != "" {
+		claims["iss"] = o.Issuer
+	}
+	if o.Audience != "" {
+		claims["aud"] = o.Audience
+	}
+	if o.Type != "" {
+		claims["typ"] = o.Type
+	}
+	return claims
+}
+
+// Validate checks the time claims and the type of the token and, when
+// configured, that it was issued by Issuer for Audience.
+func (o TokenOptions) Validate(token jwt.Token, now time.Time) error {
+	now = now.Truncate(time.Second)
+	exp := token.Expiration()
+	if exp.IsZero() {
+		return ErrTokenNoExpiration
+	}
+	if !now.Before(exp.Add(o.Leeway)) {
+		return ErrTokenExpired
+	}
+	if nbf := token.NotBefore(); !nbf.IsZero() && now.Before(nbf.Add(-o.Leeway)) {
+		return ErrTokenNotYetValid
+	}
+	if iat := token.IssuedAt(); !iat.IsZero() && now.Before(iat.Add(-o.Leeway)) {
+		return ErrTokenIssuedInFuture
+	}
+	if o.Issuer != "" && token.Issuer() != o.Issuer {
+		return ErrInvalidIssuer
+	}
+	if o.Audience != "" && !contains(token.Audience(), o.Audience) {
+		return ErrInvalidAudience
+	}
+	typ, ok := token.Get("typ")
+	if !ok {
+		typ = ""
+	}
+	if typ != o.Type {
+		return ErrInvalidTokenType
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func newToken(t *testing.T, claims map[string]interface{}) jwt.Token {
+	token := jwt.New()
+	for k, v := range claims {
+		assert.NoError(t, token.Set(k, v))
+	}
+	return token
+}
+
+func TestTokenOptionsNewClaims(t *testing.T) {
+	opts := TokenOptions{Issuer: "https://api.example.com", Audience: "products", Leeway: 30 * time.Second}
+	now := time.Now()
+	token := newToken(t, opts.NewClaims("user", now, time.Minute))
+
+	assert.Equal(t, "user", token.Subject())
+	assert.Equal(t, "https://api.example.com", token.Issuer())
+	assert.Equal(t, []string{"products"}, token.Audience())
+	assert.NotEmpty(t, token.JwtID())
+	assert.Equal(t, now.Unix(), token.IssuedAt().Unix())
+	assert.Equal(t, now.Unix(), token.NotBefore().Unix())
+	assert.Equal(t, now.Add(time.Minute).Unix(), token.Expiration().Unix())
+	assert.NoError(t, opts.Validate(token, now))
+}
+
+func TestTokenOptionsValidate(t *testing.T) {
+	opts := TokenOptions{Issuer: "https://api.example.com", Audience: "products", Leeway: 30 * time.Second}
+	now := time.Now()
+
+	other := TokenOptions{Issuer: "https://other.example.com", Audience: "products"}
+	assert.Equal(t, ErrInvalidIssuer, opts.Validate(newToken(t, other.NewClaims("user", now, time.Minute)), now))
+
+	other = TokenOptions{Issuer: "https://api.example.com", Audience: "billing"}
+	assert.Equal(t, ErrInvalidAudience, opts.Validate(newToken(t, other.NewClaims("user", now, time.Minute)), now))
+
+	assert.Equal(t, ErrInvalidIssuer, opts.Validate(newToken(t, TokenOptions{}.NewClaims("user", now, time.Minute)), now))
+
+	token := newToken(t, opts.NewClaims("user", now, time.Minute))
+	assert.NoError(t, opts.Validate(token, now.Add(80*time.Second)))
+	assert.Equal(t, ErrTokenExpired, opts.Validate(token, now.Add(2*time.Minute)))
+	assert.NoError(t, opts.Validate(token, now.Add(-20*time.Second)))
+	assert.Equal(t, ErrTokenNotYetValid, opts.Validate(token, now.Add(-time.Minute)))
+
+	assert.Equal(t, ErrTokenNoExpiration, opts.Validate(newToken(t, map[string]interface{}{"sub": "user"}), now))
+}
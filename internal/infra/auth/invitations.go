@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+)
+
+// InvitationAudience is the audience of invitation tokens. It differs from
+// the one of access tokens, so that an invitation is never accepted as one.
+const InvitationAudience = "org-invitation"
+
+// InvitationType is the typ claim of invitation tokens, which the verifier of
+// access tokens refuses even when no audience is configured.
+const InvitationType = "invitation"
+
+var ErrNotAnInvitation = errors.New("token is not an invitation")
+
+// NewInvitationToken signs a token standing for invitation that expires
+// with it. opts are the options of access tokens, whose issuer it shares.
+func NewInvitationToken(keys *KeySet, opts TokenOptions, invitation *entity.Invitation, now time.Time) (string, error) {
+	opts.Audience, opts.Type = InvitationAudience, InvitationType
+	claims := opts.NewClaims("", now, invitation.ExpiresAt.Sub(now))
+	delete(claims, "sub")
+	claims["jti"] = invitation.ID.String()
+	claims["org"] = invitation.OrganizationID.String()
+	claims["email"] = invitation.Email
+	_, token, err := keys.Encode(claims)
+	return token, err
+}
+
+// ParseInvitationToken verifies an invitation token and returns the ID of
+// its invitation.
+func ParseInvitationToken(keys *KeySet, opts TokenOptions, token string, now time.Time) (string, error) {
+	opts.Audience, opts.Type = InvitationAudience, InvitationType
+	t, err := keys.Decode(token)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if err := opts.Validate(t, now); err != nil {
+		return "", err
+	}
+	if t.JwtID() == "" {
+		return "", ErrNotAnInvitation
+	}
+	return t.JwtID(), nil
+}
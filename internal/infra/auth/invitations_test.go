@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvitationToken(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+	opts := TokenOptions{Issuer: "https://api.example.com", Audience: "products"}
+	now := time.Now()
+	invitation, err := entity.NewInvitation(service.NewID(), service.NewID(), "invitee@example.com", entity.OrgRoleMember, time.Hour)
+	assert.NoError(t, err)
+
+	token, err := NewInvitationToken(keys, opts, invitation, now)
+	assert.NoError(t, err)
+	id, err := ParseInvitationToken(keys, opts, token, now)
+	assert.NoError(t, err)
+	assert.Equal(t, invitation.ID.String(), id)
+
+	_, err = ParseInvitationToken(keys, opts, token, now.Add(2*time.Hour))
+	assert.Equal(t, ErrTokenExpired, err)
+	_, err = ParseInvitationToken(NewHMACKeySet([]byte("other")), opts, token, now)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	// Invitations are not access tokens, and access tokens not invitations.
+	decoded, err := keys.Decode(token)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidAudience, opts.Validate(decoded, now))
+	_, access, err := keys.Encode(opts.NewClaims(service.NewID().String(), now, time.Minute))
+	assert.NoError(t, err)
+	_, err = ParseInvitationToken(keys, opts, access, now)
+	assert.Equal(t, ErrInvalidAudience, err)
+
+	// Without an audience configured, the type tells them apart.
+	opts.Audience = ""
+	assert.Equal(t, ErrInvalidTokenType, opts.Validate(decoded, now))
+}
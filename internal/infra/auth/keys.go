@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+var (
+	ErrUnknownKeyID       = errors.New("unknown key id")
+	ErrAlgorithmMismatch  = errors.New("algorithm mismatch")
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+)
+
+type key struct {
+	id      string
+	alg     jwa.SignatureAlgorithm
+	signKey jwk.Key
+	public  jwk.Key
+	verify  interface{}
+}
+
+// KeySet signs tokens with its active key and verifies tokens signed by any
+// of its keys, selected by the "kid" header. Keeping the previous keys in the
+// set lets tokens they signed stay valid while keys are rotated.
+type KeySet struct {
+	active *key
+	keys   map[string]*key
+	// hmac is used for tokens without a "kid" header, which are the ones
+	// signed with the shared JWT_SECRET.
+	hmac *key
+}
+
+// NewHMACKeySet returns a key set that signs and verifies HS256 tokens with
+// a shared secret.
+func NewHMACKeySet(secret []byte) *KeySet {
+	k := &key{alg: jwa.HS256, verify: secret}
+	k.signKey, _ = jwk.New(secret)
+	return &KeySet{active: k, keys: map[string]*key{}, hmac: k}
+}
+
+// LoadKeySet reads the PEM encoded private key used to sign new tokens and
+// the PEM encoded keys, public or private, that are only used to verify
+// tokens signed before a rotation. RSA keys sign with RS256 and Ed25519 keys
+// with EdDSA.
+func LoadKeySet(signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	s := &KeySet{keys: map[string]*key{}}
+	active, err := loadKey(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("%s: signing key must be a private key", signingKeyFile)
+	}
+	s.active = active
+	s.keys[active.id] = active
+	for _, file := range verificationKeyFiles {
+		k, err := loadKey(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s.keys[k.id]; !ok {
+			s.keys[k.id] = k
+		}
+	}
+	return s, nil
+}
+
+func loadKey(file string) (*key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", file)
+	}
+	raw, err := parsePEMBlock(block)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	k, err := newKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return k, nil
+}
+
+func parsePEMBlock(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+func newKey(raw interface{}) (*key, error) {
+	k := &key{}
+	var public interface{}
+	private := true
+	switch v := raw.(type) {
+	case *rsa.PrivateKey:
+		k.alg, public = jwa.RS256, &v.PublicKey
+	case *rsa.PublicKey:
+		k.alg, public, private = jwa.RS256, v, false
+	case ed25519.PrivateKey:
+		k.alg, public = jwa.EdDSA, v.Public()
+	case ed25519.PublicKey:
+		k.alg, public, private = jwa.EdDSA, v, false
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+	pub, err := jwk.New(public)
+	if err != nil {
+		return nil, err
+	}
+	if err := jwk.AssignKeyID(pub); err != nil {
+		return nil, err
+	}
+	if err := pub.Set(jwk.AlgorithmKey, k.alg); err != nil {
+		return nil, err
+	}
+	if err := pub.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+		return nil, err
+	}
+	k.id = pub.KeyID()
+	k.public = pub
+	k.verify = public
+
+	if private {
+		sign, err := jwk.New(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := sign.Set(jwk.KeyIDKey, k.id); err != nil {
+			return nil, err
+		}
+		k.signKey = sign
+	}
+	return k, nil
+}
+
+// Encode signs the claims with the active key. It has the same signature as
+// jwtauth.JWTAuth.Encode.
+func (s *KeySet) Encode(claims map[string]interface{}) (jwt.Token, string, error) {
+	t := jwt.New()
+	for k, v := range claims {
+		if err := t.Set(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+	payload, err := jwt.Sign(t, s.active.alg, s.active.signKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, string(payload), nil
+}
+
+// Decode verifies the token signature with the key named by its "kid"
+// header. The token claims are not validated.
+func (s *KeySet) Decode(tokenString string) (jwt.Token, error) {
+	msg, err := jws.ParseString(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Signatures()) != 1 {
+		return nil, errors.New("token must have exactly one signature")
+	}
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	k, err := s.lookup(headers.KeyID())
+	if err != nil {
+		return nil, err
+	}
+	if headers.Algorithm() != k.alg {
+		return nil, ErrAlgorithmMismatch
+	}
+	return jwt.ParseString(tokenString, jwt.WithVerify(k.alg, k.verify))
+}
+
+func (s *KeySet) lookup(kid string) (*key, error) {
+	if kid == "" {
+		if s.hmac == nil {
+			return nil, ErrUnknownKeyID
+		}
+		return s.hmac, nil
+	}
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	return k, nil
+}
+
+// PublicKeys returns the JWK set of the public keys able to verify tokens,
+// sorted by kid so that it is the same on every request. Shared secrets are
+// never part of it.
+func (s *KeySet) PublicKeys() jwk.Set {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	set := jwk.NewSet()
+	for _, id := range ids {
+		set.Add(s.keys[id].public)
+	}
+	return set
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	file := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	assert.NoError(t, err)
+	return file
+}
+
+func newRSAKeyFile(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+}
+
+func newEd25519KeyFiles(t *testing.T) (string, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	assert.NoError(t, err)
+	return writePEM(t, "ed25519.pem", "PRIVATE KEY", privateDER), writePEM(t, "ed25519.pub.pem", "PUBLIC KEY", publicDER)
+}
+
+func TestHMACKeySet(t *testing.T) {
+	keys := NewHMACKeySet([]byte("secret"))
+	_, token, err := keys.Encode(map[string]interface{}{"sub": "user"})
+	assert.NoError(t, err)
+
+	decoded, err := keys.Decode(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", decoded.Subject())
+
+	_, err = NewHMACKeySet([]byte("other")).Decode(token)
+	assert.Error(t, err)
+	assert.Equal(t, 0, keys.PublicKeys().Len())
+}
+
+func TestKeySetRotation(t *testing.T) {
+	rsaFile := newRSAKeyFile(t)
+	edPrivateFile, edPublicFile := newEd25519KeyFiles(t)
+
+	oldKeys, err := LoadKeySet(rsaFile, nil)
+	assert.NoError(t, err)
+	_, oldToken, err := oldKeys.Encode(map[string]interface{}{"sub": "old"})
+	assert.NoError(t, err)
+
+	// Rotate to the Ed25519 key, keeping the RSA key for verification.
+	keys, err := LoadKeySet(edPrivateFile, []string{rsaFile})
+	assert.NoError(t, err)
+	_, newToken, err := keys.Encode(map[string]interface{}{"sub": "new"})
+	assert.NoError(t, err)
+
+	decoded, err := keys.Decode(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", decoded.Subject())
+	decoded, err = keys.Decode(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", decoded.Subject())
+
+	// Once the RSA key is dropped its tokens are rejected.
+	keys, err = LoadKeySet(edPrivateFile, nil)
+	assert.NoError(t, err)
+	_, err = keys.Decode(oldToken)
+	assert.Equal(t, ErrUnknownKeyID, err)
+
+	// A public key is enough to verify.
+	verifier, err := LoadKeySet(rsaFile, []string{edPublicFile})
+	assert.NoError(t, err)
+	_, err = verifier.Decode(newToken)
+	assert.NoError(t, err)
+
+	_, err = LoadKeySet(edPublicFile, nil)
+	assert.Error(t, err)
+}
+
+func TestKeySetRejectsHMACTokens(t *testing.T) {
+	keys, err := LoadKeySet(newRSAKeyFile(t), nil)
+	assert.NoError(t, err)
+	_, token, err := NewHMACKeySet([]byte("secret")).Encode(map[string]interface{}{"sub": "user"})
+	assert.NoError(t, err)
+	_, err = keys.Decode(token)
+	assert.Equal(t, ErrUnknownKeyID, err)
+}
+
+func TestKeySetPublicKeys(t *testing.T) {
+	edPrivateFile, _ := newEd25519KeyFiles(t)
+	keys, err := LoadKeySet(edPrivateFile, []string{newRSAKeyFile(t)})
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(keys.PublicKeys())
+	assert.NoError(t, err)
+	var jwks struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &jwks))
+	assert.Len(t, jwks.Keys, 2)
+	algs := map[string]interface{}{}
+	for _, k := range jwks.Keys {
+		assert.NotEmpty(t, k["kid"])
+		assert.Nil(t, k["d"])
+		algs[k["kty"].(string)] = k["alg"]
+	}
+	assert.Equal(t, map[string]interface{}{"OKP": "EdDSA", "RSA": "RS256"}, algs)
+	assert.Less(t, jwks.Keys[0]["kid"], jwks.Keys[1]["kid"])
+
+	again, err := json.Marshal(keys.PublicKeys())
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), string(again))
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LoginThrottler tracks failed sign-in attempts per account and per client
+// IP and tells when they have to wait before trying again.
+type LoginThrottler interface {
+	// Attempt returns how long signing in to account from ip has to wait
+	// when either of them is locked out. Otherwise it counts the attempt as
+	// failed, until Succeed says otherwise, and returns zero. Checking and
+	// counting at once keeps concurrent attempts from all getting through
+	// before the lockout.
+	Attempt(account, ip string) time.Duration
+	// RetryAfter returns how long signing in to account from ip has to wait,
+	// or zero when neither of them is locked out, without counting an
+	// attempt.
+	RetryAfter(account, ip string) time.Duration
+	// Succeed clears the failures of account and no longer counts the
+	// attempt from ip as failed. The other failures of the IP are kept, so
+	// signing in to an account of one's own does not reset them.
+	Succeed(account, ip string)
+	// Unlock clears the failures of account.
+	Unlock(account string)
+}
+
+type LoginThrottleOptions struct {
+	// MaxAttempts is the number of failures allowed before being locked out.
+	MaxAttempts int
+	// BaseLockout is the first lockout. Every further failure doubles it,
+	// up to MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+type loginFailures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// lockout returns BaseLockout doubled n times, capped at MaxLockout.
+func (o LoginThrottleOptions) lockout(n int) time.Duration {
+	d := o.BaseLockout
+	for i := 0; i < n && d < o.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > o.MaxLockout {
+		d = o.MaxLockout
+	}
+	return d
+}
+
+func (o LoginThrottleOptions) isForgotten(f *loginFailures, now time.Time) bool {
+	return now.After(f.lockedUntil) && now.Sub(f.lastFailure) > o.Window
+}
+
+// LoginThrottle keeps failure counters in memory. Counters that did not
+// fail during their window are forgotten.
+type LoginThrottle struct {
+	accountOpts LoginThrottleOptions
+	ipOpts      LoginThrottleOptions
+	now         func() time.Time
+
+	mu       sync.Mutex
+	accounts map[string]*loginFailures
+	ips      map[string]*loginFailures
+}
+
+func NewLoginThrottle(accountOpts, ipOpts LoginThrottleOptions) *LoginThrottle {
+	return &LoginThrottle{
+		accountOpts: accountOpts,
+		ipOpts:      ipOpts,
+		now:         time.Now,
+		accounts:    map[string]*loginFailures{},
+		ips:         map[string]*loginFailures{},
+	}
+}
+
+func (t *LoginThrottle) Attempt(account, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	if wait := t.retryAfter(account, ip, now); wait > 0 {
+		return wait
+	}
+	fail(t.accounts, account, t.accountOpts, now)
+	fail(t.ips, ip, t.ipOpts, now)
+	return 0
+}
+
+func (t *LoginThrottle) RetryAfter(account, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.retryAfter(account, ip, t.now())
+}
+
+func (t *LoginThrottle) retryAfter(account, ip string, now time.Time) time.Duration {
+	var wait time.Duration
+	if f, ok := t.accounts[account]; ok {
+		wait = f.lockedUntil.Sub(now)
+	}
+	if f, ok := t.ips[ip]; ok && f.lockedUntil.Sub(now) > wait {
+		wait = f.lockedUntil.Sub(now)
+	}
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+func (t *LoginThrottle) Succeed(account, ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.accounts, account)
+	if f, ok := t.ips[ip]; ok {
+		// The lockout the attempt caused, if any, is lifted.
+		f.count--
+		if f.count < t.ipOpts.MaxAttempts {
+			f.lockedUntil = time.Time{}
+		}
+	}
+}
+
+func (t *LoginThrottle) Unlock(account string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.accounts, account)
+}
+
+func fail(failures map[string]*loginFailures, key string, opts LoginThrottleOptions, now time.Time) {
+	f, ok := failures[key]
+	if !ok || opts.isForgotten(f, now) {
+		f = &loginFailures{}
+		failures[key] = f
+	}
+	f.count++
+	f.lastFailure = now
+	if f.count >= opts.MaxAttempts {
+		f.lockedUntil = now.Add(opts.lockout(f.count - opts.MaxAttempts))
+	}
+}
+
+// Cleanup forgets the counters that are no longer locked and did not fail
+// during their window.
+func (t *LoginThrottle) Cleanup(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, f := range t.accounts {
+		if t.accountOpts.isForgotten(f, now) {
+			delete(t.accounts, key)
+		}
+	}
+	for key, f := range t.ips {
+		if t.ipOpts.isForgotten(f, now) {
+			delete(t.ips, key)
+		}
+	}
+}
+
+// StartCleanup runs Cleanup every interval until ctx is done.
+func (t *LoginThrottle) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.Cleanup(now)
+		}
+	}
+}
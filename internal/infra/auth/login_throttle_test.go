@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLoginThrottle(now *time.Time) *LoginThrottle {
+	throttle := NewLoginThrottle(
+		LoginThrottleOptions{MaxAttempts: 3, BaseLockout: time.Second, MaxLockout: 5 * time.Second, Window: time.Minute},
+		LoginThrottleOptions{MaxAttempts: 5, BaseLockout: time.Second, MaxLockout: 5 * time.Second, Window: time.Minute},
+	)
+	throttle.now = func() time.Time { return *now }
+	return throttle
+}
+
+func TestLoginThrottleLocksAccountOutWithBackoff(t *testing.T) {
+	now := time.Now()
+	throttle := newTestLoginThrottle(&now)
+
+	assert.Zero(t, throttle.Attempt("a@x.com", "10.0.0.1"))
+	assert.Zero(t, throttle.Attempt("a@x.com", "10.0.0.2"))
+	assert.Zero(t, throttle.RetryAfter("a@x.com", "10.0.0.3"))
+
+	assert.Zero(t, throttle.Attempt("a@x.com", "10.0.0.3"))
+	assert.Equal(t, time.Second, throttle.RetryAfter("a@x.com", "10.0.0.4"))
+	assert.Equal(t, time.Second, throttle.Attempt("a@x.com", "10.0.0.4"))
+	assert.Zero(t, throttle.RetryAfter("b@x.com", "10.0.0.1"))
+
+	now = now.Add(time.Second)
+	assert.Zero(t, throttle.Attempt("a@x.com", "10.0.0.4"))
+	assert.Equal(t, 2*time.Second, throttle.RetryAfter("a@x.com", ""))
+	now = now.Add(2 * time.Second)
+	assert.Zero(t, throttle.Attempt("a@x.com", "10.0.0.5"))
+	assert.Equal(t, 4*time.Second, throttle.RetryAfter("a@x.com", ""))
+	now = now.Add(4 * time.Second)
+	assert.Zero(t, throttle.Attempt("a@x.com", "10.0.0.6"))
+	assert.Equal(t, 5*time.Second, throttle.RetryAfter("a@x.com", ""))
+
+	now = now.Add(5 * time.Second)
+	assert.Zero(t, throttle.RetryAfter("a@x.com", ""))
+}
+
+func TestLoginThrottleCountsConcurrentAttempts(t *testing.T) {
+	now := time.Now()
+	throttle := newTestLoginThrottle(&now)
+
+	// Attempts whose passwords are still being checked count as failed.
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, throttle.Attempt("a@x.com", "10.0.0.1"))
+	}
+	assert.Equal(t, time.Second, throttle.Attempt("a@x.com", "10.0.0.1"))
+}
+
+func TestLoginThrottleLocksIPOut(t *testing.T) {
+	now := time.Now()
+	throttle := newTestLoginThrottle(&now)
+
+	for _, account := range []string{"a@x.com", "b@x.com", "c@x.com", "d@x.com", "e@x.com"} {
+		throttle.Attempt(account, "10.0.0.1")
+	}
+	assert.Equal(t, time.Second, throttle.RetryAfter("f@x.com", "10.0.0.1"))
+	assert.Zero(t, throttle.RetryAfter("f@x.com", "10.0.0.2"))
+}
+
+func TestLoginThrottleSucceedReleasesIP(t *testing.T) {
+	now := time.Now()
+	throttle := newTestLoginThrottle(&now)
+
+	for _, account := range []string{"a@x.com", "b@x.com", "c@x.com", "d@x.com"} {
+		throttle.Attempt(account, "10.0.0.1")
+	}
+	// The fifth attempt locks the IP out until it succeeds.
+	throttle.Attempt("e@x.com", "10.0.0.1")
+	assert.Equal(t, time.Second, throttle.RetryAfter("f@x.com", "10.0.0.1"))
+	throttle.Succeed("e@x.com", "10.0.0.1")
+	assert.Zero(t, throttle.RetryAfter("f@x.com", "10.0.0.1"))
+	throttle.Attempt("f@x.com", "10.0.0.1")
+	assert.Equal(t, time.Second, throttle.RetryAfter("f@x.com", "10.0.0.1"))
+}
+
+func TestLoginThrottleSucceedClearsAccount(t *testing.T) {
+	now := time.Now()
+	throttle := newTestLoginThrottle(&now)
+
+	for i := 0; i < 4; i++ {
+		throttle.Attempt("a@x.com", "10.0.0.1")
+		if i == 1 {
+			throttle.Succeed("a@x.com", "10.0.0.1")
+		}
+	}
+	assert.Zero(t, throttle.RetryAfter("a@x.com", "10.0.0.2"))
+	throttle.Attempt("a@x.com", "10.0.0.1")
+	assert.Equal(t, time.Second, throttle.RetryAfter("a@x.com", "10.0.0.1"))
+
+	throttle.Unlock("a@x.com")
+	assert.Zero(t, throttle.RetryAfter("a@x.com", "10.0.0.2"))
+}
+
+func TestLoginThrottleForgetsOldFailures(t *testing.T) {
+	now := time.Now()
+	throttle := newTestLoginThrottle(&now)
+
+	throttle.Attempt("a@x.com", "10.0.0.1")
+	throttle.Attempt("a@x.com", "10.0.0.1")
+	now = now.Add(2 * time.Minute)
+	throttle.Attempt("a@x.com", "10.0.0.1")
+	assert.Zero(t, throttle.RetryAfter("a@x.com", "10.0.0.1"))
+
+	throttle.Cleanup(now.Add(2 * time.Minute))
+	assert.Empty(t, throttle.accounts)
+	assert.Empty(t, throttle.ips)
+}
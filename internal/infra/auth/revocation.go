@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+type TokenRevoker interface {
+	RevokeToken(jti string, userID service.ID, expiresAt time.Time) error
+	RevokeUser(userID service.ID) error
+	IsRevoked(jti, userID string, issuedAt time.Time) bool
+	// DisableUser rejects every token of a user until EnableUser is called.
+	// The user itself is saved as disabled by the caller.
+	DisableUser(userID service.ID)
+	EnableUser(userID service.ID)
+	IsDisabled(userID string) bool
+}
+
+// RevocationStore keeps the access token denylist in the database and mirrors
+// the entries that are still relevant in memory, along with the disabled
+// users, so checking a token on every request does not hit the database.
+type RevocationStore struct {
+	DB          database.RevocationDBInterface
+	maxTokenAge time.Duration
+	leeway      time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]time.Time
+	users    map[string]*entity.UserTokenRevocation
+	disabled map[string]bool
+}
+
+// NewRevocationStore loads the active denylist entries and the disabled
+// users. maxTokenAge is the lifetime of the access tokens being checked, and
+// leeway the clock skew their verifier tolerates past their expiration, so
+// the entries are kept until the tokens are rejected anyway.
+func NewRevocationStore(db database.RevocationDBInterface, maxTokenAge, leeway time.Duration) (*RevocationStore, error) {
+	s := &RevocationStore{
+		DB:          db,
+		maxTokenAge: maxTokenAge,
+		leeway:      leeway,
+		tokens:      map[string]time.Time{},
+		users:       map[string]*entity.UserTokenRevocation{},
+		disabled:    map[string]bool{},
+	}
+	tokens, users, err := db.FindActive(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		s.tokens[t.JTI] = t.ExpiresAt
+	}
+	for i := range users {
+		s.users[users[i].UserID.String()] = &users[i]
+	}
+	disabled, err := db.FindDisabledUserIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range disabled {
+		s.disabled[id.String()] = true
+	}
+	return s, nil
+}
+
+func (s *RevocationStore) RevokeToken(jti string, userID service.ID, expiresAt time.Time) error {
+	expiresAt = expiresAt.Add(s.leeway)
+	if err := s.DB.CreateRevokedToken(entity.NewRevokedToken(jti, userID, expiresAt)); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.tokens[jti] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RevocationStore) RevokeUser(userID service.ID) error {
+	revocation := entity.NewUserTokenRevocation(userID, s.maxTokenAge+s.leeway)
+	if err := s.DB.SaveUserRevocation(revocation); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.users[userID.String()] = revocation
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RevocationStore) IsRevoked(jti, userID string, issuedAt time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tokens[jti]; ok && jti != "" {
+		return true
+	}
+	if revocation, ok := s.users[userID]; ok && revocation.Covers(issuedAt) {
+		return true
+	}
+	return false
+}
+
+func (s *RevocationStore) DisableUser(userID service.ID) {
+	s.mu.Lock()
+	s.disabled[userID.String()] = true
+	s.mu.Unlock()
+}
+
+func (s *RevocationStore) EnableUser(userID service.ID) {
+	s.mu.Lock()
+	delete(s.disabled, userID.String())
+	s.mu.Unlock()
+}
+
+func (s *RevocationStore) IsDisabled(userID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.disabled[userID]
+}
+
+// Cleanup drops the entries whose tokens have all expired.
+func (s *RevocationStore) Cleanup(now time.Time) error {
+	if err := s.DB.DeleteExpired(now); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, expiresAt := range s.tokens {
+		if !expiresAt.After(now) {
+			delete(s.tokens, jti)
+		}
+	}
+	for userID, revocation := range s.users {
+		if !revocation.ExpiresAt.After(now) {
+			delete(s.users, userID)
+		}
+	}
+	return nil
+}
+
+// StartCleanup runs Cleanup every interval until ctx is done.
+func (s *RevocationStore) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Cleanup(now)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newRevocationDB(t *testing.T) *database.RevocationDB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RevokedToken{}, &entity.UserTokenRevocation{}, &entity.User{})
+	return database.NewRevocationDB(db)
+}
+
+func TestRevocationStoreRevokeToken(t *testing.T) {
+	db := newRevocationDB(t)
+	store, err := NewRevocationStore(db, time.Minute, 0)
+	assert.NoError(t, err)
+	userID := service.NewID()
+	issuedAt := time.Now().Add(-time.Minute)
+
+	assert.False(t, store.IsRevoked("jti-1", userID.String(), issuedAt))
+	assert.NoError(t, store.RevokeToken("jti-1", userID, time.Now().Add(time.Minute)))
+	assert.True(t, store.IsRevoked("jti-1", userID.String(), issuedAt))
+	assert.False(t, store.IsRevoked("jti-2", userID.String(), issuedAt))
+
+	// A new store is loaded from the database.
+	store, err = NewRevocationStore(db, time.Minute, 0)
+	assert.NoError(t, err)
+	assert.True(t, store.IsRevoked("jti-1", userID.String(), issuedAt))
+}
+
+func TestRevocationStoreRevokeUser(t *testing.T) {
+	store, err := NewRevocationStore(newRevocationDB(t), time.Minute, 0)
+	assert.NoError(t, err)
+	userID := service.NewID()
+
+	assert.NoError(t, store.RevokeUser(userID))
+	assert.True(t, store.IsRevoked("jti-1", userID.String(), time.Now().Add(-time.Minute)))
+	assert.False(t, store.IsRevoked("jti-2", userID.String(), time.Now().Add(2*time.Second)))
+	assert.False(t, store.IsRevoked("jti-1", service.NewID().String(), time.Now().Add(-time.Minute)))
+}
+
+func TestRevocationStoreDisableUser(t *testing.T) {
+	db := newRevocationDB(t)
+	user, _ := entity.NewUser("Diego", "diego@gmail.com", "123456")
+	user.Disable()
+	assert.NoError(t, db.DB.Create(user).Error)
+
+	// Disabled users are loaded from the database.
+	store, err := NewRevocationStore(db, time.Minute, 0)
+	assert.NoError(t, err)
+	assert.True(t, store.IsDisabled(user.ID.String()))
+
+	store.EnableUser(user.ID)
+	assert.False(t, store.IsDisabled(user.ID.String()))
+	other := service.NewID()
+	store.DisableUser(other)
+	assert.True(t, store.IsDisabled(other.String()))
+}
+
+func TestRevocationStoreCleanup(t *testing.T) {
+	db := newRevocationDB(t)
+	store, err := NewRevocationStore(db, time.Minute, 0)
+	assert.NoError(t, err)
+	userID := service.NewID()
+	issuedAt := time.Now().Add(-time.Minute)
+	assert.NoError(t, store.RevokeToken("expired", userID, time.Now().Add(-time.Second)))
+	assert.NoError(t, store.RevokeToken("active", userID, time.Now().Add(time.Minute)))
+
+	assert.NoError(t, store.Cleanup(time.Now()))
+	assert.False(t, store.IsRevoked("expired", userID.String(), issuedAt))
+	assert.True(t, store.IsRevoked("active", userID.String(), issuedAt))
+
+	tokens, _, err := db.FindActive(time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+}
+
+func TestRevocationStoreCleanupKeepsLeeway(t *testing.T) {
+	db := newRevocationDB(t)
+	store, err := NewRevocationStore(db, time.Minute, 30*time.Second)
+	assert.NoError(t, err)
+	userID := service.NewID()
+	issuedAt := time.Now().Add(-time.Minute)
+	expiresAt := time.Now()
+	assert.NoError(t, store.RevokeToken("jti-1", userID, expiresAt))
+	assert.NoError(t, store.RevokeUser(userID))
+
+	// The verifier still accepts the token during the leeway.
+	assert.NoError(t, store.Cleanup(expiresAt.Add(time.Second)))
+	assert.True(t, store.IsRevoked("jti-1", service.NewID().String(), issuedAt))
+	assert.True(t, store.IsRevoked("jti-2", userID.String(), issuedAt))
+	store, err = NewRevocationStore(db, time.Minute, 30*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, store.IsRevoked("jti-1", service.NewID().String(), issuedAt))
+
+	assert.NoError(t, store.Cleanup(expiresAt.Add(2*time.Minute)))
+	assert.False(t, store.IsRevoked("jti-1", service.NewID().String(), issuedAt))
+	assert.False(t, store.IsRevoked("jti-2", userID.String(), issuedAt))
+}
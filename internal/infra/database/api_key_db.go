@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type APIKeyDB struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyDB(db *gorm.DB) *APIKeyDB {
+	return &APIKeyDB{DB: db}
+}
+
+// WithContext returns an APIKeyDB whose queries join the trace of ctx.
+func (k *APIKeyDB) WithContext(ctx context.Context) APIKeyDBInterface {
+	return &APIKeyDB{DB: k.DB.WithContext(ctx)}
+}
+
+func (k *APIKeyDB) start(method string) (*APIKeyDB, trace.Span) {
+	db, span := startSpan(k.DB, "APIKeyDB."+method)
+	return &APIKeyDB{DB: db}, span
+}
+
+func (k *APIKeyDB) Create(key *entity.APIKey) error {
+	k, span := k.start("Create")
+	defer span.End()
+	return k.DB.Create(key).Error
+}
+
+func (k *APIKeyDB) FindByPrefix(prefix string) (*entity.APIKey, error) {
+	k, span := k.start("FindByPrefix")
+	defer span.End()
+	var key entity.APIKey
+	if err := k.DB.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (k *APIKeyDB) FindByID(id string) (*entity.APIKey, error) {
+	k, span := k.start("FindByID")
+	defer span.End()
+	var key entity.APIKey
+	if err := k.DB.Where("id = ?", id).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindAllByUserID returns the keys of a user, newest first.
+func (k *APIKeyDB) FindAllByUserID(userID string) ([]entity.APIKey, error) {
+	k, span := k.start("FindAllByUserID")
+	defer span.End()
+	var keys []entity.APIKey
+	err := k.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+func (k *APIKeyDB) Update(key *entity.APIKey) error {
+	k, span := k.start("Update")
+	defer span.End()
+	return k.DB.Save(key).Error
+}
+
+// RevokeByUserID revokes the keys of a user that are not revoked yet.
+func (k *APIKeyDB) RevokeByUserID(userID string) error {
+	k, span := k.start("RevokeByUserID")
+	defer span.End()
+	return k.DB.Model(&entity.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAPIKeyDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.APIKey{})
+	keyDB := NewAPIKeyDB(db)
+	userID := service.NewID()
+	key, _, err := entity.NewAPIKey(userID, "ci", []string{entity.PermissionProductsRead}, []string{"10.0.0.0/8"}, 0)
+	assert.NoError(t, err)
+	other, _, _ := entity.NewAPIKey(service.NewID(), "other", []string{entity.PermissionProductsRead}, nil, 0)
+	assert.NoError(t, keyDB.Create(key))
+	assert.NoError(t, keyDB.Create(other))
+
+	found, err := keyDB.FindByPrefix(key.Prefix)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, found.ID)
+	assert.Equal(t, []string{"10.0.0.0/8"}, found.AllowedIPs)
+
+	found.Revoke()
+	assert.NoError(t, keyDB.Update(found))
+	found, err = keyDB.FindByID(key.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.IsRevoked())
+
+	keys, err := keyDB.FindAllByUserID(userID.String())
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestRevokeAPIKeysByUserID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.APIKey{})
+	keyDB := NewAPIKeyDB(db)
+	userID := service.NewID()
+	key, _, _ := entity.NewAPIKey(userID, "ci", []string{entity.PermissionProductsRead}, nil, 0)
+	other, _, _ := entity.NewAPIKey(service.NewID(), "other", []string{entity.PermissionProductsRead}, nil, 0)
+	assert.NoError(t, keyDB.Create(key))
+	assert.NoError(t, keyDB.Create(other))
+
+	assert.NoError(t, keyDB.RevokeByUserID(userID.String()))
+	found, err := keyDB.FindByID(key.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.IsRevoked())
+	found, err = keyDB.FindByID(other.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, found.IsRevoked())
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type AuditLogDB struct {
+	DB *gorm.DB
+}
+
+func NewAuditLogDB(db *gorm.DB) *AuditLogDB {
+	return &AuditLogDB{DB: db}
+}
+
+// WithContext returns an AuditLogDB whose queries join the trace of ctx.
+func (a *AuditLogDB) WithContext(ctx context.Context) AuditLogDBInterface {
+	return &AuditLogDB{DB: a.DB.WithContext(ctx)}
+}
+
+func (a *AuditLogDB) start(method string) (*AuditLogDB, trace.Span) {
+	db, span := startSpan(a.DB, "AuditLogDB."+method)
+	return &AuditLogDB{DB: db}, span
+}
+
+func (a *AuditLogDB) Create(event *entity.AuditEvent) error {
+	a, span := a.start("Create")
+	defer span.End()
+	return a.DB.Create(event).Error
+}
+
+// FindAllByOrganizationID returns the events of an organization, newest
+// first. A zero page or limit returns them all.
+func (a *AuditLogDB) FindAllByOrganizationID(organizationID string, page, limit int) ([]entity.AuditEvent, error) {
+	a, span := a.start("FindAllByOrganizationID")
+	defer span.End()
+	var events []entity.AuditEvent
+	query := a.DB.Where("organization_id = ?", organizationID).Order("created_at desc")
+	if page != 0 && limit != 0 {
+		query = query.Limit(limit).Offset((page - 1) * limit)
+	}
+	err := query.Find(&events).Error
+	return events, err
+}
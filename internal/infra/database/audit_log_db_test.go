@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAuditLogDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.AuditEvent{})
+	auditDB := NewAuditLogDB(db)
+	orgID, actorID, targetID := service.NewID(), service.NewID(), service.NewID()
+
+	for i, action := range []string{entity.AuditMemberInvited, entity.AuditInvitationAccepted, entity.AuditMemberRoleChanged} {
+		event := entity.NewAuditEvent(orgID, actorID, action, &targetID, map[string]string{"role": entity.OrgRoleAdmin})
+		event.CreatedAt = event.CreatedAt.Add(time.Duration(i) * time.Second)
+		assert.NoError(t, auditDB.Create(event))
+	}
+	assert.NoError(t, auditDB.Create(entity.NewAuditEvent(service.NewID(), actorID, entity.AuditMemberRemoved, nil, nil)))
+
+	events, err := auditDB.FindAllByOrganizationID(orgID.String(), 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, entity.AuditMemberRoleChanged, events[0].Action)
+	assert.Equal(t, targetID, *events[0].TargetUserID)
+	assert.Equal(t, entity.OrgRoleAdmin, events[0].Details["role"])
+
+	events, err = auditDB.FindAllByOrganizationID(orgID.String(), 2, 2)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, entity.AuditMemberInvited, events[0].Action)
+}
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// isDuplicatedKey reports whether err is a unique constraint violation,
+// whether or not the connection was opened with TranslateError.
+func isDuplicatedKey(db *gorm.DB, err error) bool {
+	if err == nil {
+		return false
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
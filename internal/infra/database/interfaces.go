@@ -1,16 +1,105 @@
 package database
 
-import "github.com/diegopontes87/api/internal/entity"
+import (
+	"context"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+)
 
 type UserDBInterface interface {
+	WithContext(ctx context.Context) UserDBInterface
 	Create(user *entity.User) error
 	FindByEmail(email string) (*entity.User, error)
+	FindByID(id string) (*entity.User, error)
+	Update(user *entity.User) error
+	UseTwoFactorCode(user *entity.User, lastCounter int64, recoveryCodes []string) (bool, error)
+	Delete(id string) error
+	OwnsSharedOrganization(id string) (bool, error)
+	DeleteScheduled(now time.Time) (int64, error)
+	FindAll(filter UserFilter, page, limit int) ([]entity.User, int64, error)
 }
 
 type ProductDBInterface interface {
+	WithContext(ctx context.Context) ProductDBInterface
 	Create(product *entity.Product) error
 	FindAll(page, limit int, sort string) ([]entity.Product, error)
+	FindAllByUserID(userID string, page, limit int, sort string) ([]entity.Product, error)
 	FindByID(id string) (*entity.Product, error)
 	Update(product *entity.Product) error
 	Delete(id string) error
+	// ForTenant returns a ProductDBInterface that only sees the products of
+	// an organization.
+	ForTenant(organizationID service.ID) ProductDBInterface
+}
+
+type RefreshTokenDBInterface interface {
+	WithContext(ctx context.Context) RefreshTokenDBInterface
+	Create(token *entity.RefreshToken) error
+	FindByHash(hash string) (*entity.RefreshToken, error)
+	Update(token *entity.RefreshToken) error
+	Revoke(token *entity.RefreshToken) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeByUserID(userID string) error
+}
+
+type RevocationDBInterface interface {
+	CreateRevokedToken(token *entity.RevokedToken) error
+	SaveUserRevocation(revocation *entity.UserTokenRevocation) error
+	FindActive(now time.Time) ([]entity.RevokedToken, []entity.UserTokenRevocation, error)
+	FindDisabledUserIDs() ([]service.ID, error)
+	DeleteExpired(now time.Time) error
+}
+
+type UserTokenDBInterface interface {
+	WithContext(ctx context.Context) UserTokenDBInterface
+	Create(token *entity.UserToken) error
+	FindByHash(purpose, hash string) (*entity.UserToken, error)
+	Update(token *entity.UserToken) error
+	Use(token *entity.UserToken) (bool, error)
+	InvalidateByUserID(userID, purpose string) error
+}
+
+type APIKeyDBInterface interface {
+	WithContext(ctx context.Context) APIKeyDBInterface
+	Create(key *entity.APIKey) error
+	FindByPrefix(prefix string) (*entity.APIKey, error)
+	FindByID(id string) (*entity.APIKey, error)
+	FindAllByUserID(userID string) ([]entity.APIKey, error)
+	Update(key *entity.APIKey) error
+	RevokeByUserID(userID string) error
+}
+
+type OAuthClientDBInterface interface {
+	WithContext(ctx context.Context) OAuthClientDBInterface
+	Create(client *entity.OAuthClient) error
+	FindByID(id string) (*entity.OAuthClient, error)
+	FindAllByUserID(userID string) ([]entity.OAuthClient, error)
+	Update(client *entity.OAuthClient) error
+	RevokeByUserID(userID string) error
+}
+
+type OrganizationDBInterface interface {
+	WithContext(ctx context.Context) OrganizationDBInterface
+	Create(org *entity.Organization, owner *entity.Membership) error
+	FindByID(id string) (*entity.Organization, error)
+	FindMembership(organizationID, userID string) (*entity.Membership, error)
+	FindMembershipsByUserID(userID string) ([]entity.Membership, error)
+	FindMembershipsByOrganizationID(organizationID string) ([]entity.Membership, error)
+	UpdateMembership(membership *entity.Membership) error
+	DeleteMembership(membership *entity.Membership) error
+	TransferOwnership(from, to *entity.Membership) error
+	CreateInvitation(invitation *entity.Invitation) error
+	FindInvitation(id string) (*entity.Invitation, error)
+	FindInvitationsByOrganizationID(organizationID string) ([]entity.Invitation, error)
+	UpdateInvitation(invitation *entity.Invitation) error
+	AcceptInvitation(invitation *entity.Invitation, membership *entity.Membership) error
+	RegisterWithInvitation(user *entity.User, invitation *entity.Invitation, membership *entity.Membership) error
+}
+
+type AuditLogDBInterface interface {
+	WithContext(ctx context.Context) AuditLogDBInterface
+	Create(event *entity.AuditEvent) error
+	FindAllByOrganizationID(organizationID string, page, limit int) ([]entity.AuditEvent, error)
 }
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"gorm.io/gorm"
+)
+
+// SchemaMigration records a migration applied to the database.
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Migration changes the existing data once, before AutoMigrate brings the
+// tables up to date. The tables it changes may not exist yet, on a new
+// database, or lack columns added since.
+type Migration struct {
+	Version int
+	Name    string
+	Migrate func(tx *gorm.DB) error
+}
+
+// Migrations are the migrations of the database, in the order they are
+// applied. Versions are never reused.
+var Migrations = []Migration{
+	{Version: 1, Name: "default legacy roles to viewer", Migrate: defaultLegacyRoles},
+	{Version: 2, Name: "normalize emails", Migrate: normalizeEmails},
+	{Version: 3, Name: "assign legacy products and api keys to organizations", Migrate: assignLegacyOrganizations},
+}
+
+// Migrate applies the migrations that were not applied to db yet, in order,
+// each in a transaction of its own along with its SchemaMigration.
+func Migrate(db *gorm.DB, migrations []Migration) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+	var applied []int
+	if err := db.Model(&SchemaMigration{}).Pluck("version", &applied).Error; err != nil {
+		return err
+	}
+	done := map[int]bool{}
+	for _, version := range applied {
+		done[version] = true
+	}
+	for _, migration := range migrations {
+		if done[migration.Version] {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Migrate(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// defaultLegacyRoles gives the users created before roles existed the role
+// with the fewest permissions. Administrators grant them more.
+func defaultLegacyRoles(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&entity.User{}) {
+		return nil
+	}
+	if !tx.Migrator().HasColumn(&entity.User{}, "Role") {
+		if err := tx.Migrator().AddColumn(&entity.User{}, "Role"); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&entity.User{}).Where("role = '' OR role IS NULL").Update("role", entity.RoleViewer).Error
+}
+
+// normalizeEmails lowercases and trims the emails stored before they were
+// normalized, as FindByEmail looks them up normalized. Emails of several
+// users that become the same fail the migration, before the unique index on
+// them is created, for an administrator to merge or rename the accounts.
+func normalizeEmails(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&entity.User{}) {
+		return nil
+	}
+	var users []struct{ ID, Email string }
+	if err := tx.Model(&entity.User{}).Select("id", "email").Find(&users).Error; err != nil {
+		return err
+	}
+	owners := map[string][]string{}
+	for _, user := range users {
+		email := strings.ToLower(strings.TrimSpace(user.Email))
+		owners[email] = append(owners[email], user.ID)
+	}
+	var collisions []string
+	for email, ids := range owners {
+		if len(ids) > 1 {
+			collisions = append(collisions, fmt.Sprintf("%s (users %s)", email, strings.Join(ids, ", ")))
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return fmt.Errorf("emails shared by several users: %s", strings.Join(collisions, "; "))
+	}
+	for _, user := range users {
+		email := strings.ToLower(strings.TrimSpace(user.Email))
+		if email == user.Email {
+			continue
+		}
+		err := tx.Model(&entity.User{}).Where("id = ?", user.ID).Update("email", email).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignLegacyOrganizations moves the products and the API keys created
+// before organizations existed to an organization of their owner, creating
+// a personal one when the owner has none. Products without an owner, or
+// whose owner no longer exists, are left out of every organization, where
+// no catalog lists them.
+func assignLegacyOrganizations(tx *gorm.DB) error {
+	hasProducts, hasKeys := tx.Migrator().HasTable(&entity.Product{}), tx.Migrator().HasTable(&entity.APIKey{})
+	var models []interface{}
+	if hasProducts {
+		models = append(models, &entity.Product{})
+	}
+	if hasKeys {
+		models = append(models, &entity.APIKey{})
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	// The organizations are written, and the products and keys read, with
+	// their current columns.
+	models = append(models, &entity.Organization{}, &entity.Membership{})
+	if err := tx.AutoMigrate(models...); err != nil {
+		return err
+	}
+	organizations := map[string]service.ID{}
+	assign := func(model interface{}, unassigned string) error {
+		var userIDs []string
+		err := tx.Model(model).Where(unassigned).Where("user_id IS NOT NULL").Distinct().Pluck("user_id", &userIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			orgID, ok := organizations[userID]
+			if !ok {
+				if orgID, err = ownerOrganization(tx, userID); err != nil {
+					return err
+				}
+				organizations[userID] = orgID
+			}
+			if orgID == (service.ID{}) {
+				continue
+			}
+			err = tx.Model(model).Where(unassigned).Where("user_id = ?", userID).Update("organization_id", orgID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if hasProducts {
+		if err := assign(&entity.Product{}, "organization_id IS NULL"); err != nil {
+			return err
+		}
+	}
+	if hasKeys {
+		// Keys created in between were given a zero organization.
+		return assign(&entity.APIKey{}, fmt.Sprintf("(organization_id IS NULL OR organization_id = '%s')", service.ID{}))
+	}
+	return nil
+}
+
+// ownerOrganization returns the first organization of a user, creating a
+// personal one when the user has none, or a zero ID when the user does not
+// exist.
+func ownerOrganization(tx *gorm.DB, userID string) (service.ID, error) {
+	var user entity.User
+	err := tx.Where("id = ?", userID).Take(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return service.ID{}, nil
+	}
+	if err != nil {
+		return service.ID{}, err
+	}
+	var membership entity.Membership
+	err = tx.Where("user_id = ?", userID).Order("created_at").Take(&membership).Error
+	if err == nil {
+		return membership.OrganizationID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return service.ID{}, err
+	}
+	org, err := entity.NewOrganization(entity.PersonalOrganizationName(&user))
+	if err != nil {
+		return service.ID{}, err
+	}
+	owner, err := entity.NewMembership(org.ID, user.ID, entity.OrgRoleOwner)
+	if err == nil {
+		err = NewOrganizationDB(tx).Create(org, owner)
+	}
+	return org.ID, err
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrateAppliesMigrationsOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	migrations := []Migration{{Version: 1, Name: "count", Migrate: func(tx *gorm.DB) error {
+		runs++
+		return nil
+	}}}
+
+	assert.Nil(t, Migrate(db, migrations))
+	assert.Nil(t, Migrate(db, migrations))
+	assert.Equal(t, 1, runs)
+	var applied []SchemaMigration
+	db.Find(&applied)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "count", applied[0].Name)
+}
+
+func TestDefaultLegacyRoles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The users table of before roles existed.
+	db.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT, password TEXT, email TEXT)")
+	db.Exec("INSERT INTO users (id, name, password, email) VALUES ('1', 'Legacy', 'hash', 'legacy@example.com')")
+
+	assert.Nil(t, Migrate(db, Migrations))
+	var role string
+	db.Raw("SELECT role FROM users WHERE id = '1'").Scan(&role)
+	assert.Equal(t, entity.RoleViewer, role)
+
+	// Roles set afterwards are left alone on the next start.
+	db.Exec("UPDATE users SET role = ''")
+	assert.Nil(t, Migrate(db, Migrations))
+	db.Raw("SELECT role FROM users WHERE id = '1'").Scan(&role)
+	assert.Equal(t, "", role)
+}
+
+func TestMigrateNewDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, Migrate(db, Migrations))
+	assert.False(t, db.Migrator().HasTable(&entity.User{}))
+}
+
+func TestNormalizeEmails(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT, password TEXT, email TEXT, role TEXT)")
+	id := service.NewID()
+	db.Exec("INSERT INTO users (id, name, password, email, role) VALUES (?, 'Mixed', 'hash', ' John@Example.com', 'editor')", id.String())
+
+	assert.Nil(t, Migrate(db, Migrations))
+	assert.Nil(t, db.AutoMigrate(&entity.User{}))
+	user, err := NewUserDB(db).FindByEmail("john@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, id, user.ID)
+}
+
+func TestNormalizeEmailsReportsCollisions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT, password TEXT, email TEXT, role TEXT)")
+	db.Exec("INSERT INTO users (id, name, password, email, role) VALUES ('1', 'Upper', 'hash', 'John@Example.com', 'editor')")
+	db.Exec("INSERT INTO users (id, name, password, email, role) VALUES ('2', 'Lower', 'hash', 'john@example.com', 'editor')")
+
+	err = Migrate(db, Migrations)
+	assert.ErrorContains(t, err, "john@example.com (users 1, 2)")
+	var emails []string
+	db.Raw("SELECT email FROM users ORDER BY id").Scan(&emails)
+	assert.Equal(t, []string{"John@Example.com", "john@example.com"}, emails)
+}
+
+func TestAssignLegacyOrganizations(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tables of before organizations existed.
+	db.AutoMigrate(&entity.User{}, &entity.Product{}, &entity.APIKey{})
+	db.Migrator().DropColumn(&entity.Product{}, "OrganizationID")
+	db.Migrator().DropColumn(&entity.APIKey{}, "OrganizationID")
+	owner, _ := entity.NewUser("Owner", "owner@example.com", "123456")
+	db.Create(owner)
+	owned, _ := entity.NewProduct("Owned", 10)
+	owned.UserID = owner.ID
+	orphan, _ := entity.NewProduct("Orphan", 10)
+	orphan.UserID = service.NewID()
+	ownerless, _ := entity.NewProduct("Ownerless", 10)
+	for _, product := range []*entity.Product{owned, orphan, ownerless} {
+		db.Omit("OrganizationID").Create(product)
+	}
+	db.Exec("UPDATE products SET user_id = NULL WHERE id = ?", ownerless.ID.String())
+	key, _, _ := entity.NewAPIKey(owner.ID, "ci", []string{entity.PermissionProductsRead}, nil, 0)
+	db.Omit("OrganizationID").Create(key)
+
+	assert.Nil(t, Migrate(db, Migrations))
+	memberships, err := NewOrganizationDB(db).FindMembershipsByUserID(owner.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, memberships, 1)
+	assert.Equal(t, entity.OrgRoleOwner, memberships[0].Role)
+	orgID := memberships[0].OrganizationID
+
+	organizationOf := func(table, id string) *string {
+		var orgID *string
+		db.Raw("SELECT organization_id FROM "+table+" WHERE id = ?", id).Scan(&orgID)
+		return orgID
+	}
+	assert.Equal(t, orgID.String(), *organizationOf("products", owned.ID.String()))
+	assert.Equal(t, orgID.String(), *organizationOf("api_keys", key.ID.String()))
+	assert.Nil(t, organizationOf("products", orphan.ID.String()))
+	assert.Nil(t, organizationOf("products", ownerless.ID.String()))
+}
+
+func TestAssignLegacyOrganizationsWithoutOwners(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The schema of the first releases: products had no owner, and there were
+	// no API keys.
+	db.Exec("CREATE TABLE users (id text PRIMARY KEY, name text, password text, email text)")
+	db.Exec("CREATE TABLE products (id text PRIMARY KEY, name text, price real, created_at datetime)")
+	db.Exec("INSERT INTO products (id, name, price) VALUES ('1', 'Legacy', 10)")
+
+	assert.Nil(t, Migrate(db, Migrations))
+	var orgID *string
+	db.Raw("SELECT organization_id FROM products WHERE id = '1'").Scan(&orgID)
+	assert.Nil(t, orgID)
+	assert.False(t, db.Migrator().HasTable(&entity.APIKey{}))
+}
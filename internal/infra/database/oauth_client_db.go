@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type OAuthClientDB struct {
+	DB *gorm.DB
+}
+
+func NewOAuthClientDB(db *gorm.DB) *OAuthClientDB {
+	return &OAuthClientDB{DB: db}
+}
+
+// WithContext returns an OAuthClientDB whose queries join the trace of ctx.
+func (c *OAuthClientDB) WithContext(ctx context.Context) OAuthClientDBInterface {
+	return &OAuthClientDB{DB: c.DB.WithContext(ctx)}
+}
+
+func (c *OAuthClientDB) start(method string) (*OAuthClientDB, trace.Span) {
+	db, span := startSpan(c.DB, "OAuthClientDB."+method)
+	return &OAuthClientDB{DB: db}, span
+}
+
+func (c *OAuthClientDB) Create(client *entity.OAuthClient) error {
+	c, span := c.start("Create")
+	defer span.End()
+	return c.DB.Create(client).Error
+}
+
+func (c *OAuthClientDB) FindByID(id string) (*entity.OAuthClient, error) {
+	c, span := c.start("FindByID")
+	defer span.End()
+	var client entity.OAuthClient
+	if err := c.DB.Where("id = ?", id).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// FindAllByUserID returns the clients registered by a user, newest first.
+func (c *OAuthClientDB) FindAllByUserID(userID string) ([]entity.OAuthClient, error) {
+	c, span := c.start("FindAllByUserID")
+	defer span.End()
+	var clients []entity.OAuthClient
+	err := c.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&clients).Error
+	return clients, err
+}
+
+func (c *OAuthClientDB) Update(client *entity.OAuthClient) error {
+	c, span := c.start("Update")
+	defer span.End()
+	return c.DB.Save(client).Error
+}
+
+// RevokeByUserID revokes the clients of a user that are not revoked yet.
+func (c *OAuthClientDB) RevokeByUserID(userID string) error {
+	c, span := c.start("RevokeByUserID")
+	defer span.End()
+	return c.DB.Model(&entity.OAuthClient{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
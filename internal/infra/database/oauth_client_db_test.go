@@ -0,0 +1,58 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestOAuthClientDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.OAuthClient{})
+	clientDB := NewOAuthClientDB(db)
+	userID := service.NewID()
+	client, secret, err := entity.NewOAuthClient(userID, "svc", []string{entity.PermissionProductsRead}, []string{entity.GrantTypeClientCredentials})
+	assert.NoError(t, err)
+	assert.NoError(t, clientDB.Create(client))
+
+	found, err := clientDB.FindByID(client.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.Authenticate(secret))
+	assert.Equal(t, []string{entity.GrantTypeClientCredentials}, found.GrantTypes)
+
+	found.Revoke()
+	assert.NoError(t, clientDB.Update(found))
+	clients, err := clientDB.FindAllByUserID(userID.String())
+	assert.NoError(t, err)
+	assert.Len(t, clients, 1)
+	assert.True(t, clients[0].IsRevoked())
+}
+
+func TestRevokeOAuthClientsByUserID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.OAuthClient{})
+	clientDB := NewOAuthClientDB(db)
+	userID := service.NewID()
+	client, secret, _ := entity.NewOAuthClient(userID, "svc", []string{entity.PermissionProductsRead}, []string{entity.GrantTypeClientCredentials})
+	other, otherSecret, _ := entity.NewOAuthClient(service.NewID(), "other", []string{entity.PermissionProductsRead}, []string{entity.GrantTypeClientCredentials})
+	assert.NoError(t, clientDB.Create(client))
+	assert.NoError(t, clientDB.Create(other))
+
+	assert.NoError(t, clientDB.RevokeByUserID(userID.String()))
+	found, err := clientDB.FindByID(client.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, found.Authenticate(secret))
+	found, err = clientDB.FindByID(other.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, found.Authenticate(otherSecret))
+}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type OrganizationDB struct {
+	DB *gorm.DB
+}
+
+func NewOrganizationDB(db *gorm.DB) *OrganizationDB {
+	return &OrganizationDB{DB: db}
+}
+
+// WithContext returns an OrganizationDB whose queries join the trace of ctx.
+func (o *OrganizationDB) WithContext(ctx context.Context) OrganizationDBInterface {
+	return &OrganizationDB{DB: o.DB.WithContext(ctx)}
+}
+
+func (o *OrganizationDB) start(method string) (*OrganizationDB, trace.Span) {
+	db, span := startSpan(o.DB, "OrganizationDB."+method)
+	return &OrganizationDB{DB: db}, span
+}
+
+// Create saves a new organization along with the membership of its owner.
+func (o *OrganizationDB) Create(org *entity.Organization, owner *entity.Membership) error {
+	o, span := o.start("Create")
+	defer span.End()
+	return o.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		owner.OrganizationID = org.ID
+		return tx.Omit("Organization").Create(owner).Error
+	})
+}
+
+func (o *OrganizationDB) FindByID(id string) (*entity.Organization, error) {
+	o, span := o.start("FindByID")
+	defer span.End()
+	var org entity.Organization
+	if err := o.DB.First(&org, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// FindMembership returns the membership of a user in an organization.
+func (o *OrganizationDB) FindMembership(organizationID, userID string) (*entity.Membership, error) {
+	o, span := o.start("FindMembership")
+	defer span.End()
+	var membership entity.Membership
+	err := o.DB.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// FindMembershipsByUserID returns the memberships of a user along with
+// their organization, oldest first.
+func (o *OrganizationDB) FindMembershipsByUserID(userID string) ([]entity.Membership, error) {
+	o, span := o.start("FindMembershipsByUserID")
+	defer span.End()
+	var memberships []entity.Membership
+	err := o.DB.Preload("Organization").Where("user_id = ?", userID).Order("created_at").Find(&memberships).Error
+	return memberships, err
+}
+
+// FindMembershipsByOrganizationID returns the members of an organization
+// along with their user, oldest first.
+func (o *OrganizationDB) FindMembershipsByOrganizationID(organizationID string) ([]entity.Membership, error) {
+	o, span := o.start("FindMembershipsByOrganizationID")
+	defer span.End()
+	var memberships []entity.Membership
+	err := o.DB.Preload("User").Where("organization_id = ?", organizationID).Order("created_at").Find(&memberships).Error
+	return memberships, err
+}
+
+func (o *OrganizationDB) UpdateMembership(membership *entity.Membership) error {
+	o, span := o.start("UpdateMembership")
+	defer span.End()
+	return o.DB.Omit("Organization", "User").Save(membership).Error
+}
+
+func (o *OrganizationDB) DeleteMembership(membership *entity.Membership) error {
+	o, span := o.start("DeleteMembership")
+	defer span.End()
+	return o.DB.Delete(&entity.Membership{}, "id = ?", membership.ID).Error
+}
+
+// TransferOwnership makes to the owner of its organization and demotes
+// from, the current owner, to admin.
+func (o *OrganizationDB) TransferOwnership(from, to *entity.Membership) error {
+	o, span := o.start("TransferOwnership")
+	defer span.End()
+	return o.DB.Transaction(func(tx *gorm.DB) error {
+		from.Role = entity.OrgRoleAdmin
+		to.Role = entity.OrgRoleOwner
+		if err := tx.Omit("Organization", "User").Save(from).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Organization", "User").Save(to).Error
+	})
+}
+
+func (o *OrganizationDB) CreateInvitation(invitation *entity.Invitation) error {
+	o, span := o.start("CreateInvitation")
+	defer span.End()
+	return o.DB.Create(invitation).Error
+}
+
+func (o *OrganizationDB) FindInvitation(id string) (*entity.Invitation, error) {
+	o, span := o.start("FindInvitation")
+	defer span.End()
+	var invitation entity.Invitation
+	if err := o.DB.First(&invitation, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindInvitationsByOrganizationID returns the invitations of an
+// organization, newest first.
+func (o *OrganizationDB) FindInvitationsByOrganizationID(organizationID string) ([]entity.Invitation, error) {
+	o, span := o.start("FindInvitationsByOrganizationID")
+	defer span.End()
+	var invitations []entity.Invitation
+	err := o.DB.Where("organization_id = ?", organizationID).Order("created_at desc").Find(&invitations).Error
+	return invitations, err
+}
+
+// UpdateInvitation saves the acceptance or the revocation of a pending
+// invitation. It returns entity.ErrInvitationNotPending when the invitation
+// was accepted or revoked since it was read.
+func (o *OrganizationDB) UpdateInvitation(invitation *entity.Invitation) error {
+	o, span := o.start("UpdateInvitation")
+	defer span.End()
+	return updatePendingInvitation(o.DB, invitation)
+}
+
+// AcceptInvitation saves an accepted invitation along with the membership
+// it created. It returns entity.ErrAlreadyAMember when the user already is
+// a member of the organization, and entity.ErrInvitationNotPending when the
+// invitation was accepted or revoked since it was read.
+func (o *OrganizationDB) AcceptInvitation(invitation *entity.Invitation, membership *entity.Membership) error {
+	o, span := o.start("AcceptInvitation")
+	defer span.End()
+	return o.DB.Transaction(func(tx *gorm.DB) error {
+		return acceptInvitation(tx, invitation, membership)
+	})
+}
+
+// RegisterWithInvitation creates the user who registered with an invitation
+// and accepts it, in the same transaction so that neither happens without
+// the other. It returns entity.ErrEmailAlreadyExists when the email is
+// already registered.
+func (o *OrganizationDB) RegisterWithInvitation(user *entity.User, invitation *entity.Invitation, membership *entity.Membership) error {
+	o, span := o.start("RegisterWithInvitation")
+	defer span.End()
+	return o.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(user).Error
+		if isDuplicatedKey(tx, err) {
+			return entity.ErrEmailAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+		return acceptInvitation(tx, invitation, membership)
+	})
+}
+
+func acceptInvitation(tx *gorm.DB, invitation *entity.Invitation, membership *entity.Membership) error {
+	err := tx.Omit("Organization", "User").Create(membership).Error
+	if isDuplicatedKey(tx, err) {
+		return entity.ErrAlreadyAMember
+	}
+	if err != nil {
+		return err
+	}
+	return updatePendingInvitation(tx, invitation)
+}
+
+func updatePendingInvitation(tx *gorm.DB, invitation *entity.Invitation) error {
+	result := tx.Model(invitation).
+		Where("accepted_at IS NULL AND revoked_at IS NULL").
+		Select("AcceptedAt", "AcceptedBy", "RevokedAt").
+		Updates(invitation)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrInvitationNotPending
+	}
+	return nil
+}
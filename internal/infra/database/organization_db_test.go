@@ -0,0 +1,184 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestOrganizationDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Organization{}, &entity.Membership{})
+	orgDB := NewOrganizationDB(db)
+	userID := service.NewID()
+
+	first, _ := entity.NewOrganization("Acme")
+	owner, _ := entity.NewMembership(service.ID{}, userID, entity.OrgRoleOwner)
+	assert.NoError(t, orgDB.Create(first, owner))
+	assert.Equal(t, first.ID, owner.OrganizationID)
+	second, _ := entity.NewOrganization("Globex")
+	member, _ := entity.NewMembership(service.ID{}, userID, entity.OrgRoleMember)
+	assert.NoError(t, orgDB.Create(second, member))
+
+	found, err := orgDB.FindByID(first.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme", found.Name)
+
+	membership, err := orgDB.FindMembership(second.ID.String(), userID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, entity.OrgRoleMember, membership.Role)
+	_, err = orgDB.FindMembership(second.ID.String(), service.NewID().String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	memberships, err := orgDB.FindMembershipsByUserID(userID.String())
+	assert.NoError(t, err)
+	assert.Len(t, memberships, 2)
+	assert.Equal(t, "Acme", memberships[0].Organization.Name)
+	assert.Equal(t, entity.OrgRoleOwner, memberships[0].Role)
+
+	// A user is a member of an organization once.
+	again, _ := entity.NewMembership(first.ID, userID, entity.OrgRoleAdmin)
+	assert.Error(t, db.Create(again).Error)
+}
+
+func TestOrganizationDBMembers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.Organization{}, &entity.Membership{}, &entity.Invitation{})
+	orgDB := NewOrganizationDB(db)
+	owner, _ := entity.NewUser("Owner", "owner@example.com", "123456")
+	invitee, _ := entity.NewUser("Invitee", "invitee@example.com", "123456")
+	db.Create(owner)
+	db.Create(invitee)
+	org, _ := entity.NewOrganization("Acme")
+	ownership, _ := entity.NewMembership(org.ID, owner.ID, entity.OrgRoleOwner)
+	assert.NoError(t, orgDB.Create(org, ownership))
+
+	invitation, _ := entity.NewInvitation(org.ID, owner.ID, invitee.Email, entity.OrgRoleMember, time.Hour)
+	assert.NoError(t, orgDB.CreateInvitation(invitation))
+	found, err := orgDB.FindInvitation(invitation.ID.String())
+	assert.NoError(t, err)
+	membership, err := found.Accept(invitee, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, orgDB.AcceptInvitation(found, membership))
+	found, _ = orgDB.FindInvitation(invitation.ID.String())
+	assert.False(t, found.IsPending(time.Now()))
+
+	again, _ := entity.NewInvitation(org.ID, owner.ID, invitee.Email, entity.OrgRoleAdmin, time.Hour)
+	assert.NoError(t, orgDB.CreateInvitation(again))
+	duplicate, _ := again.Accept(invitee, time.Now())
+	assert.Equal(t, entity.ErrAlreadyAMember, orgDB.AcceptInvitation(again, duplicate))
+	again, _ = orgDB.FindInvitation(again.ID.String())
+	assert.True(t, again.IsPending(time.Now()))
+	invitations, err := orgDB.FindInvitationsByOrganizationID(org.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, invitations, 2)
+
+	members, err := orgDB.FindMembershipsByOrganizationID(org.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, "owner@example.com", members[0].User.Email)
+	assert.Equal(t, entity.OrgRoleMember, members[1].Role)
+
+	assert.NoError(t, orgDB.TransferOwnership(&members[0], &members[1]))
+	previous, _ := orgDB.FindMembership(org.ID.String(), owner.ID.String())
+	assert.Equal(t, entity.OrgRoleAdmin, previous.Role)
+	next, _ := orgDB.FindMembership(org.ID.String(), invitee.ID.String())
+	assert.Equal(t, entity.OrgRoleOwner, next.Role)
+
+	previous.Role = entity.OrgRoleMember
+	assert.NoError(t, orgDB.UpdateMembership(previous))
+	previous, _ = orgDB.FindMembership(org.ID.String(), owner.ID.String())
+	assert.Equal(t, entity.OrgRoleMember, previous.Role)
+	assert.NoError(t, orgDB.DeleteMembership(previous))
+	_, err = orgDB.FindMembership(org.ID.String(), owner.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestRegisterWithInvitation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.Organization{}, &entity.Membership{}, &entity.Invitation{})
+	orgDB, userDB := NewOrganizationDB(db), NewUserDB(db)
+	owner, _ := entity.NewUser("Owner", "owner@example.com", "123456")
+	db.Create(owner)
+	org, _ := entity.NewOrganization("Acme")
+	ownership, _ := entity.NewMembership(org.ID, owner.ID, entity.OrgRoleOwner)
+	assert.NoError(t, orgDB.Create(org, ownership))
+	invitation, _ := entity.NewInvitation(org.ID, owner.ID, "invitee@example.com", entity.OrgRoleMember, time.Hour)
+	assert.NoError(t, orgDB.CreateInvitation(invitation))
+
+	// The user is not created when the invitation cannot be accepted.
+	invitee, _ := entity.NewUser("Invitee", "invitee@example.com", "123456")
+	membership, err := invitation.Accept(invitee, time.Now())
+	assert.NoError(t, err)
+	db.Omit("Organization", "User").Create(membership)
+	assert.Equal(t, entity.ErrAlreadyAMember, orgDB.RegisterWithInvitation(invitee, invitation, membership))
+	_, err = userDB.FindByEmail("invitee@example.com")
+	assert.Error(t, err)
+	db.Delete(membership)
+
+	invitation, _ = orgDB.FindInvitation(invitation.ID.String())
+	membership, _ = invitation.Accept(invitee, time.Now())
+	assert.NoError(t, orgDB.RegisterWithInvitation(invitee, invitation, membership))
+	_, err = userDB.FindByEmail("invitee@example.com")
+	assert.NoError(t, err)
+	found, _ := orgDB.FindInvitation(invitation.ID.String())
+	assert.False(t, found.IsPending(time.Now()))
+
+	// Nor is the invitation accepted when the email is already registered.
+	again, _ := entity.NewInvitation(org.ID, owner.ID, "invitee@example.com", entity.OrgRoleAdmin, time.Hour)
+	assert.NoError(t, orgDB.CreateInvitation(again))
+	duplicate, _ := entity.NewUser("Invitee", "invitee@example.com", "123456")
+	membership, _ = again.Accept(duplicate, time.Now())
+	assert.Equal(t, entity.ErrEmailAlreadyExists, orgDB.RegisterWithInvitation(duplicate, again, membership))
+	again, _ = orgDB.FindInvitation(again.ID.String())
+	assert.True(t, again.IsPending(time.Now()))
+}
+
+func TestInvitationAcceptedOrRevokedOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.Organization{}, &entity.Membership{}, &entity.Invitation{})
+	orgDB := NewOrganizationDB(db)
+	owner, _ := entity.NewUser("Owner", "owner@example.com", "123456")
+	invitee, _ := entity.NewUser("Invitee", "invitee@example.com", "123456")
+	db.Create(owner)
+	db.Create(invitee)
+	org, _ := entity.NewOrganization("Acme")
+	ownership, _ := entity.NewMembership(org.ID, owner.ID, entity.OrgRoleOwner)
+	assert.NoError(t, orgDB.Create(org, ownership))
+	invitation, _ := entity.NewInvitation(org.ID, owner.ID, "invitee@example.com", entity.OrgRoleMember, time.Hour)
+	assert.NoError(t, orgDB.CreateInvitation(invitation))
+
+	// The invitation is revoked while it is being accepted.
+	accepted, _ := orgDB.FindInvitation(invitation.ID.String())
+	revoked, _ := orgDB.FindInvitation(invitation.ID.String())
+	revoked.Revoke()
+	assert.NoError(t, orgDB.UpdateInvitation(revoked))
+	membership, err := accepted.Accept(invitee, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, entity.ErrInvitationNotPending, orgDB.AcceptInvitation(accepted, membership))
+	_, err = orgDB.FindMembership(org.ID.String(), invitee.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	found, _ := orgDB.FindInvitation(invitation.ID.String())
+	assert.Nil(t, found.AcceptedAt)
+	assert.NotNil(t, found.RevokedAt)
+
+	revoked.Revoke()
+	assert.Equal(t, entity.ErrInvitationNotPending, orgDB.UpdateInvitation(revoked))
+}
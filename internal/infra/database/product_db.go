@@ -1,25 +1,57 @@
 package database
 
 import (
+	"context"
+
 	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+// ProductDB only sees the products of one organization: every query is
+// scoped by OrganizationID, and products are created in it.
 type ProductDB struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	OrganizationID service.ID
 }
 
 func NewProductDB(db *gorm.DB) *ProductDB {
 	return &ProductDB{DB: db}
 }
 
+// WithContext returns a ProductDB of the same tenant whose queries join the
+// trace of ctx.
+func (p *ProductDB) WithContext(ctx context.Context) ProductDBInterface {
+	return &ProductDB{DB: p.DB.WithContext(ctx), OrganizationID: p.OrganizationID}
+}
+
+func (p *ProductDB) start(method string) (*ProductDB, trace.Span) {
+	db, span := startSpan(p.DB, "ProductDB."+method)
+	return &ProductDB{DB: db, OrganizationID: p.OrganizationID}, span
+}
+
+// ForTenant returns a ProductDB scoped to the products of an organization.
+func (p *ProductDB) ForTenant(organizationID service.ID) ProductDBInterface {
+	return &ProductDB{DB: p.DB, OrganizationID: organizationID}
+}
+
+func (p *ProductDB) scoped() *gorm.DB {
+	return p.DB.Where("organization_id = ?", p.OrganizationID)
+}
+
 func (p *ProductDB) Create(product *entity.Product) error {
+	p, span := p.start("Create")
+	defer span.End()
+	product.OrganizationID = p.OrganizationID
 	return p.DB.Create(product).Error
 }
 
 func (p *ProductDB) FindByID(id string) (*entity.Product, error) {
+	p, span := p.start("FindByID")
+	defer span.End()
 	var product entity.Product
-	err := p.DB.First(&product, "id = ?", id).Error
+	err := p.scoped().First(&product, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -27,14 +59,19 @@ func (p *ProductDB) FindByID(id string) (*entity.Product, error) {
 }
 
 func (p *ProductDB) Update(product *entity.Product) error {
+	p, span := p.start("Update")
+	defer span.End()
 	_, err := p.FindByID(product.ID.String())
 	if err != nil {
 		return err
 	}
+	product.OrganizationID = p.OrganizationID
 	return p.DB.Save(product).Error
 }
 
 func (p *ProductDB) Delete(id string) error {
+	p, span := p.start("Delete")
+	defer span.End()
 	product, err := p.FindByID(id)
 	if err != nil {
 		return err
@@ -43,15 +80,27 @@ func (p *ProductDB) Delete(id string) error {
 }
 
 func (p *ProductDB) FindAll(page, limit int, sort string) ([]entity.Product, error) {
+	p, span := p.start("FindAll")
+	defer span.End()
+	return p.findAll(p.scoped(), page, limit, sort)
+}
+
+func (p *ProductDB) FindAllByUserID(userID string, page, limit int, sort string) ([]entity.Product, error) {
+	p, span := p.start("FindAllByUserID")
+	defer span.End()
+	return p.findAll(p.scoped().Where("user_id = ?", userID), page, limit, sort)
+}
+
+func (p *ProductDB) findAll(query *gorm.DB, page, limit int, sort string) ([]entity.Product, error) {
 	var products []entity.Product
 	var err error
 	if sort != "" && sort != "asc" && sort != "desc" {
 		sort = "asc"
 	}
 	if page != 0 && limit != 0 {
-		err = p.DB.Limit(limit).Offset((page - 1) * limit).Order("created_at " + sort).Find(&products).Error
+		err = query.Limit(limit).Offset((page - 1) * limit).Order("created_at " + sort).Find(&products).Error
 	} else {
-		err = p.DB.Order("created_at " + sort).Find(&products).Error
+		err = query.Order("created_at " + sort).Find(&products).Error
 	}
 	return products, err
 }
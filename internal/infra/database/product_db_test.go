@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -117,3 +118,67 @@ func TestDeleteProductByID(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, product)
 }
+
+func TestFindAllProductsByUserID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	owner := service.NewID()
+	for i := 1; i < 6; i++ {
+		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), 10)
+		assert.NoError(t, err)
+		if i%2 == 0 {
+			product.UserID = owner
+		}
+		db.Create(product)
+	}
+	productDB := NewProductDB(db)
+
+	products, err := productDB.FindAllByUserID(owner.String(), 0, 0, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "Product 2", products[0].Name)
+	assert.Equal(t, "Product 4", products[1].Name)
+	assert.Equal(t, owner, products[0].UserID)
+}
+
+func TestProductDBIsolatesTenants(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	acme := NewProductDB(db).ForTenant(service.NewID())
+	globex := NewProductDB(db).ForTenant(service.NewID())
+
+	acmeProduct, _ := entity.NewProduct("Anvil", 10)
+	assert.NoError(t, acme.Create(acmeProduct))
+	globexProduct, _ := entity.NewProduct("Hammock", 20)
+	// The tenant of the ProductDB wins over the one of the product.
+	globexProduct.OrganizationID = acmeProduct.OrganizationID
+	assert.NoError(t, globex.Create(globexProduct))
+	assert.NotEqual(t, acmeProduct.OrganizationID, globexProduct.OrganizationID)
+
+	products, err := acme.FindAll(0, 0, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "Anvil", products[0].Name)
+	products, err = globex.FindAllByUserID(acmeProduct.UserID.String(), 0, 0, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "Hammock", products[0].Name)
+
+	_, err = globex.FindByID(acmeProduct.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	acmeProduct.Name = "Stolen"
+	assert.ErrorIs(t, globex.Update(acmeProduct), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, globex.Delete(acmeProduct.ID.String()), gorm.ErrRecordNotFound)
+
+	found, err := acme.FindByID(acmeProduct.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "Anvil", found.Name)
+	_, err = NewProductDB(db).FindByID(acmeProduct.ID.String())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenDB struct {
+	DB *gorm.DB
+}
+
+func NewRefreshTokenDB(db *gorm.DB) *RefreshTokenDB {
+	return &RefreshTokenDB{DB: db}
+}
+
+// WithContext returns a RefreshTokenDB whose queries join the trace of ctx.
+func (t *RefreshTokenDB) WithContext(ctx context.Context) RefreshTokenDBInterface {
+	return &RefreshTokenDB{DB: t.DB.WithContext(ctx)}
+}
+
+func (t *RefreshTokenDB) start(method string) (*RefreshTokenDB, trace.Span) {
+	db, span := startSpan(t.DB, "RefreshTokenDB."+method)
+	return &RefreshTokenDB{DB: db}, span
+}
+
+func (t *RefreshTokenDB) Create(token *entity.RefreshToken) error {
+	t, span := t.start("Create")
+	defer span.End()
+	return t.DB.Create(token).Error
+}
+
+func (t *RefreshTokenDB) FindByHash(hash string) (*entity.RefreshToken, error) {
+	t, span := t.start("FindByHash")
+	defer span.End()
+	var token entity.RefreshToken
+	if err := t.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (t *RefreshTokenDB) Update(token *entity.RefreshToken) error {
+	t, span := t.start("Update")
+	defer span.End()
+	return t.DB.Save(token).Error
+}
+
+// Revoke revokes token, unless it was revoked already, and reports whether
+// it did. The check and the change are a single statement, so of concurrent
+// calls for the same token only one revokes it.
+func (t *RefreshTokenDB) Revoke(token *entity.RefreshToken) (bool, error) {
+	t, span := t.start("Revoke")
+	defer span.End()
+	now := time.Now()
+	result := t.DB.Model(&entity.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", token.ID).
+		Update("revoked_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	token.RevokedAt = &now
+	return true, nil
+}
+
+func (t *RefreshTokenDB) RevokeFamily(familyID string) error {
+	t, span := t.start("RevokeFamily")
+	defer span.End()
+	return t.DB.Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (t *RefreshTokenDB) RevokeByUserID(userID string) error {
+	t, span := t.start("RevokeByUserID")
+	defer span.End()
+	return t.DB.Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
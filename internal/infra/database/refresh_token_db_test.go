@@ -0,0 +1,81 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestFindRefreshTokenByHash(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RefreshToken{})
+	token, plain, err := entity.NewRefreshToken(service.NewID(), service.NewID(), time.Hour)
+	assert.NoError(t, err)
+	tokenDB := NewRefreshTokenDB(db)
+	assert.NoError(t, tokenDB.Create(token))
+
+	tokenFound, err := tokenDB.FindByHash(service.HashToken(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, tokenFound.ID)
+	assert.Equal(t, token.FamilyID, tokenFound.FamilyID)
+
+	_, err = tokenDB.FindByHash(service.HashToken("unknown"))
+	assert.Error(t, err)
+}
+
+func TestRevokeRefreshTokenFamily(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RefreshToken{})
+	tokenDB := NewRefreshTokenDB(db)
+	userID, familyID := service.NewID(), service.NewID()
+	first, firstPlain, _ := entity.NewRefreshToken(userID, familyID, time.Hour)
+	second, secondPlain, _ := entity.NewRefreshToken(userID, familyID, time.Hour)
+	other, otherPlain, _ := entity.NewRefreshToken(userID, service.NewID(), time.Hour)
+	assert.NoError(t, tokenDB.Create(first))
+	assert.NoError(t, tokenDB.Create(second))
+	assert.NoError(t, tokenDB.Create(other))
+
+	assert.NoError(t, tokenDB.RevokeFamily(familyID.String()))
+
+	found, _ := tokenDB.FindByHash(service.HashToken(firstPlain))
+	assert.True(t, found.IsRevoked())
+	found, _ = tokenDB.FindByHash(service.HashToken(secondPlain))
+	assert.True(t, found.IsRevoked())
+	found, _ = tokenDB.FindByHash(service.HashToken(otherPlain))
+	assert.False(t, found.IsRevoked())
+}
+
+func TestRevokeRefreshTokenOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RefreshToken{})
+	tokenDB := NewRefreshTokenDB(db)
+	token, plain, _ := entity.NewRefreshToken(service.NewID(), service.NewID(), time.Hour)
+	assert.NoError(t, tokenDB.Create(token))
+	// A concurrent request loaded the token before it was revoked.
+	stale, err := tokenDB.FindByHash(service.HashToken(plain))
+	assert.NoError(t, err)
+
+	revoked, err := tokenDB.Revoke(token)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	assert.True(t, token.IsRevoked())
+
+	revoked, err = tokenDB.Revoke(stale)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	assert.False(t, stale.IsRevoked())
+}
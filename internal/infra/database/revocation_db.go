@@ -0,0 +1,69 @@
+package database
+
+import (
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevocationDB struct {
+	DB *gorm.DB
+}
+
+func NewRevocationDB(db *gorm.DB) *RevocationDB {
+	return &RevocationDB{DB: db}
+}
+
+func (r *RevocationDB) start(method string) (*RevocationDB, trace.Span) {
+	db, span := startSpan(r.DB, "RevocationDB."+method)
+	return &RevocationDB{DB: db}, span
+}
+
+func (r *RevocationDB) CreateRevokedToken(token *entity.RevokedToken) error {
+	r, span := r.start("CreateRevokedToken")
+	defer span.End()
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (r *RevocationDB) SaveUserRevocation(revocation *entity.UserTokenRevocation) error {
+	r, span := r.start("SaveUserRevocation")
+	defer span.End()
+	return r.DB.Save(revocation).Error
+}
+
+func (r *RevocationDB) FindActive(now time.Time) ([]entity.RevokedToken, []entity.UserTokenRevocation, error) {
+	r, span := r.start("FindActive")
+	defer span.End()
+	var tokens []entity.RevokedToken
+	var users []entity.UserTokenRevocation
+	if err := r.DB.Where("expires_at > ?", now).Find(&tokens).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := r.DB.Where("expires_at > ?", now).Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+	return tokens, users, nil
+}
+
+// FindDisabledUserIDs returns the IDs of the disabled users, whose tokens
+// are all rejected.
+func (r *RevocationDB) FindDisabledUserIDs() ([]service.ID, error) {
+	r, span := r.start("FindDisabledUserIDs")
+	defer span.End()
+	var ids []service.ID
+	err := r.DB.Model(&entity.User{}).Where("disabled_at IS NOT NULL").Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *RevocationDB) DeleteExpired(now time.Time) error {
+	r, span := r.start("DeleteExpired")
+	defer span.End()
+	if err := r.DB.Where("expires_at <= ?", now).Delete(&entity.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return r.DB.Where("expires_at <= ?", now).Delete(&entity.UserTokenRevocation{}).Error
+}
@@ -0,0 +1,17 @@
+package database
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var tracer = otel.Tracer("github.com/diegopontes87/api/internal/infra/database")
+
+// startSpan starts the span of a repository call as a child of the context
+// of db, and returns db in the context of the span, so the statements of the
+// call are children of it.
+func startSpan(db *gorm.DB, name string) (*gorm.DB, trace.Span) {
+	ctx, span := tracer.Start(db.Statement.Context, name)
+	return db.WithContext(ctx), span
+}
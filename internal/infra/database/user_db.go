@@ -1,7 +1,14 @@
 package database
 
 import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
 	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
@@ -13,14 +20,244 @@ func NewUserDB(db *gorm.DB) *UserDB {
 	return &UserDB{DB: db}
 }
 
+// WithContext returns a UserDB whose queries join the trace of ctx.
+func (u *UserDB) WithContext(ctx context.Context) UserDBInterface {
+	return &UserDB{DB: u.DB.WithContext(ctx)}
+}
+
+func (u *UserDB) start(method string) (*UserDB, trace.Span) {
+	db, span := startSpan(u.DB, "UserDB."+method)
+	return &UserDB{DB: db}, span
+}
+
 func (u *UserDB) Create(user *entity.User) error {
-	return u.DB.Create(user).Error
+	u, span := u.start("Create")
+	defer span.End()
+	err := u.DB.Create(user).Error
+	if isDuplicatedKey(u.DB, err) {
+		return entity.ErrEmailAlreadyExists
+	}
+	return err
 }
 
 func (u *UserDB) FindByEmail(email string) (*entity.User, error) {
+	u, span := u.start("FindByEmail")
+	defer span.End()
 	var user entity.User
 	if err := u.DB.Where("email = ?", email).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (u *UserDB) FindByID(id string) (*entity.User, error) {
+	u, span := u.start("FindByID")
+	defer span.End()
+	var user entity.User
+	if err := u.DB.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u *UserDB) Update(user *entity.User) error {
+	u, span := u.start("Update")
+	defer span.End()
+	err := u.DB.Save(user).Error
+	if isDuplicatedKey(u.DB, err) {
+		return entity.ErrEmailAlreadyExists
+	}
+	return err
+}
+
+// UseTwoFactorCode saves the TOTP time step or the recovery code that
+// User.VerifyTwoFactor consumed, unless a concurrent call consumed it first,
+// and reports whether it did. lastCounter and recoveryCodes are the ones of
+// user before the code was verified.
+func (u *UserDB) UseTwoFactorCode(user *entity.User, lastCounter int64, recoveryCodes []string) (bool, error) {
+	u, span := u.start("UseTwoFactorCode")
+	defer span.End()
+	query := u.DB.Model(user).Select("TOTPLastCounter", "RecoveryCodes")
+	if user.TOTPLastCounter != lastCounter {
+		query = query.Where("totp_last_counter < ?", user.TOTPLastCounter)
+	} else {
+		// The codes are replaced as a whole, so they must be the ones the
+		// code was removed from.
+		codes, err := json.Marshal(recoveryCodes)
+		if err != nil {
+			return false, err
+		}
+		query = query.Where("recovery_codes = ?", string(codes))
+	}
+	result := query.Updates(user)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Delete removes a user along with the records of the account, as
+// deleteUsers does.
+func (u *UserDB) Delete(id string) error {
+	u, span := u.start("Delete")
+	defer span.End()
+	user, err := u.FindByID(id)
+	if err != nil {
+		return err
+	}
+	return u.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteUsers(tx, []string{user.ID.String()})
+	})
+}
+
+// OwnsSharedOrganization reports whether the user owns an organization that
+// has other members, which keeps the account from being deleted until the
+// ownership is transferred.
+func (u *UserDB) OwnsSharedOrganization(id string) (bool, error) {
+	u, span := u.start("OwnsSharedOrganization")
+	defer span.End()
+	owners, err := ownersOfSharedOrganizations(u.DB, []string{id})
+	return len(owners) > 0, err
+}
+
+// UserFilter narrows the users listed by FindAll.
+type UserFilter struct {
+	// Search matches part of the email or of the name, ignoring case.
+	Search string
+	// Disabled keeps only the disabled users when true, and only the
+	// enabled ones when false.
+	Disabled *bool
+}
+
+// FindAll returns a page of the users matching filter, ordered by email,
+// along with the number of users matching it.
+func (u *UserDB) FindAll(filter UserFilter, page, limit int) ([]entity.User, int64, error) {
+	u, span := u.start("FindAll")
+	defer span.End()
+	query := u.DB.Model(&entity.User{})
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		query = query.Where(`LOWER(email) LIKE ? ESCAPE '\' OR LOWER(name) LIKE ? ESCAPE '\'`, pattern, pattern)
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			query = query.Where("disabled_at IS NOT NULL")
+		} else {
+			query = query.Where("disabled_at IS NULL")
+		}
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	query = query.Order("email")
+	if page != 0 && limit != 0 {
+		query = query.Limit(limit).Offset((page - 1) * limit)
+	}
+	var users []entity.User
+	err := query.Find(&users).Error
+	return users, total, err
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so s matches itself.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// DeleteScheduled removes the users whose scheduled deletion is due, along
+// with the records of their accounts, as deleteUsers does. The owners of
+// organizations with other members are kept until they transfer the
+// ownership.
+func (u *UserDB) DeleteScheduled(now time.Time) (int64, error) {
+	u, span := u.start("DeleteScheduled")
+	defer span.End()
+	var deleted int64
+	err := u.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		err := tx.Model(&entity.User{}).Where("deletion_scheduled_at <= ?", now).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		owners, err := ownersOfSharedOrganizations(tx, ids)
+		if err != nil {
+			return err
+		}
+		if len(owners) > 0 {
+			err = tx.Model(&entity.User{}).Where("deletion_scheduled_at <= ?", now).Where("id NOT IN ?", owners).Pluck("id", &ids).Error
+			if err != nil || len(ids) == 0 {
+				return err
+			}
+		}
+		deleted = int64(len(ids))
+		return deleteUsers(tx, ids)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// deleteUsers removes the users of ids along with their refresh tokens,
+// emailed tokens, API keys, OAuth clients and memberships. The organizations
+// they own are removed with their products, invitations and audit log, but
+// the products they created in the organizations of others are kept. It
+// returns entity.ErrOwnerCannotBeDeleted when one of them owns an
+// organization with other members. The revocations of their access tokens
+// are kept until the tokens expire.
+func deleteUsers(tx *gorm.DB, ids []string) error {
+	owners, err := ownersOfSharedOrganizations(tx, ids)
+	if err != nil {
+		return err
+	}
+	if len(owners) > 0 {
+		return entity.ErrOwnerCannotBeDeleted
+	}
+	var organizationIDs []string
+	err = tx.Model(&entity.Membership{}).Where("user_id IN ? AND role = ?", ids, entity.OrgRoleOwner).Pluck("organization_id", &organizationIDs).Error
+	if err != nil {
+		return err
+	}
+	if len(organizationIDs) > 0 {
+		records := []interface{}{
+			&entity.Product{},
+			&entity.Invitation{},
+			&entity.AuditEvent{},
+			&entity.Membership{},
+		}
+		for _, record := range records {
+			if err := tx.Where("organization_id IN ?", organizationIDs).Delete(record).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("id IN ?", organizationIDs).Delete(&entity.Organization{}).Error; err != nil {
+			return err
+		}
+	}
+	records := []interface{}{
+		&entity.RefreshToken{},
+		&entity.UserToken{},
+		&entity.APIKey{},
+		&entity.OAuthClient{},
+		&entity.Membership{},
+	}
+	for _, record := range records {
+		if err := tx.Where("user_id IN ?", ids).Delete(record).Error; err != nil {
+			return err
+		}
+	}
+	// Products outside of any organization were only seen by their owner.
+	err = tx.Where("user_id IN ?", ids).Where("organization_id IS NULL OR organization_id = ?", service.ID{}).Delete(&entity.Product{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&entity.User{}).Error
+}
+
+// ownersOfSharedOrganizations returns the users of ids who own an
+// organization that has members other than them.
+func ownersOfSharedOrganizations(tx *gorm.DB, ids []string) ([]string, error) {
+	others := tx.Model(&entity.Membership{}).Select("organization_id").Where("user_id NOT IN ?", ids)
+	var owners []string
+	err := tx.Model(&entity.Membership{}).
+		Where("user_id IN ? AND role = ?", ids, entity.OrgRoleOwner).
+		Where("organization_id IN (?)", others).
+		Distinct().Pluck("user_id", &owners).Error
+	return owners, err
+}
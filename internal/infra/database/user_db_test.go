@@ -1,12 +1,17 @@
 package database
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/diegopontes87/api/pkg/totp"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func TestCreateUserDB(t *testing.T) {
@@ -50,3 +55,252 @@ func TestFindByEmail(t *testing.T) {
 	assert.Equal(t, user.Email, userFound.Email)
 	assert.NotNil(t, userFound.Password)
 }
+
+func TestUpdateUserRole(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	user, _ := entity.NewUser("Diego", "diego@gmail.com", "123456")
+	userDB := NewUserDB(db)
+	if err := userDB.Create(user); err != nil {
+		t.Error(err)
+	}
+	assert.Nil(t, user.SetRole(entity.RoleViewer, []string{entity.PermissionUsersManage}))
+	assert.Nil(t, userDB.Update(user))
+
+	userFound, err := userDB.FindByID(user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, entity.RoleViewer, userFound.Role)
+	assert.Equal(t, []string{entity.PermissionUsersManage}, userFound.Permissions)
+}
+
+func TestCreateUserWithDuplicatedEmail(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDB := NewUserDB(db)
+	user, _ := entity.NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, userDB.Create(user))
+
+	duplicated, _ := entity.NewUser("Other Diego", "Diego@Gmail.com", "654321")
+	assert.Equal(t, entity.ErrEmailAlreadyExists, userDB.Create(duplicated))
+
+	var count int64
+	db.Model(&entity.User{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDeleteUser(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.UserToken{}, &entity.APIKey{}, &entity.OAuthClient{}, &entity.Organization{}, &entity.Membership{}, &entity.Invitation{}, &entity.AuditEvent{}, &entity.Product{})
+	user, _ := entity.NewUser("Diego", "diego@gmail.com", "123456")
+	userDB := NewUserDB(db)
+	assert.Nil(t, userDB.Create(user))
+
+	assert.Nil(t, userDB.Delete(user.ID.String()))
+	_, err = userDB.FindByID(user.ID.String())
+	assert.Error(t, err)
+	assert.Error(t, userDB.Delete(user.ID.String()))
+}
+
+func TestDeleteScheduledUsers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.UserToken{}, &entity.APIKey{}, &entity.OAuthClient{}, &entity.Organization{}, &entity.Membership{}, &entity.Invitation{}, &entity.AuditEvent{}, &entity.Product{})
+	userDB := NewUserDB(db)
+	due, _ := entity.NewUser("Due", "due@gmail.com", "123456")
+	due.ScheduleDeletion(-time.Minute)
+	pending, _ := entity.NewUser("Pending", "pending@gmail.com", "123456")
+	pending.ScheduleDeletion(time.Hour)
+	active, _ := entity.NewUser("Active", "active@gmail.com", "123456")
+	for _, user := range []*entity.User{due, pending, active} {
+		assert.Nil(t, userDB.Create(user))
+	}
+
+	for _, user := range []*entity.User{due, active} {
+		refreshToken, _, _ := entity.NewRefreshToken(user.ID, service.NewID(), time.Hour)
+		apiKey, _, _ := entity.NewAPIKey(user.ID, "key", []string{entity.PermissionProductsRead}, nil, 0)
+		membership, _ := entity.NewMembership(service.NewID(), user.ID, entity.OrgRoleOwner)
+		product, _ := entity.NewProduct("Product", 10)
+		product.UserID = user.ID
+		for _, record := range []interface{}{refreshToken, apiKey, membership, product} {
+			assert.Nil(t, db.Omit(clause.Associations).Create(record).Error)
+		}
+	}
+
+	deleted, err := userDB.DeleteScheduled(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+	for _, record := range []interface{}{&entity.RefreshToken{}, &entity.APIKey{}, &entity.Membership{}, &entity.Product{}} {
+		var count int64
+		db.Model(record).Where("user_id = ?", due.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		db.Model(record).Where("user_id = ?", active.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	}
+	_, err = userDB.FindByID(due.ID.String())
+	assert.Error(t, err)
+	_, err = userDB.FindByID(pending.ID.String())
+	assert.Nil(t, err)
+	_, err = userDB.FindByID(active.ID.String())
+	assert.Nil(t, err)
+}
+
+func TestDeleteUserWithOrganizations(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.RefreshToken{}, &entity.UserToken{}, &entity.APIKey{}, &entity.OAuthClient{}, &entity.Organization{}, &entity.Membership{}, &entity.Invitation{}, &entity.AuditEvent{}, &entity.Product{})
+	userDB := NewUserDB(db)
+	orgDB := NewOrganizationDB(db)
+	owner, _ := entity.NewUser("Owner", "owner@gmail.com", "123456")
+	member, _ := entity.NewUser("Member", "member@gmail.com", "123456")
+	owner.ScheduleDeletion(-time.Minute)
+	assert.Nil(t, userDB.Create(owner))
+	assert.Nil(t, userDB.Create(member))
+	shared, _ := entity.NewOrganization("Shared")
+	ownership, _ := entity.NewMembership(shared.ID, owner.ID, entity.OrgRoleOwner)
+	assert.Nil(t, orgDB.Create(shared, ownership))
+	membership, _ := entity.NewMembership(shared.ID, member.ID, entity.OrgRoleMember)
+	assert.Nil(t, db.Omit(clause.Associations).Create(membership).Error)
+	personal, _ := entity.NewOrganization("Personal")
+	personalOwnership, _ := entity.NewMembership(personal.ID, member.ID, entity.OrgRoleOwner)
+	assert.Nil(t, orgDB.Create(personal, personalOwnership))
+	sharedProduct, _ := entity.NewProduct("Shared", 10)
+	personalProduct, _ := entity.NewProduct("Personal", 10)
+	for product, org := range map[*entity.Product]*entity.Organization{sharedProduct: shared, personalProduct: personal} {
+		product.UserID = member.ID
+		product.OrganizationID = org.ID
+		assert.Nil(t, db.Create(product).Error)
+	}
+
+	// The owner of an organization with other members is kept.
+	owns, err := userDB.OwnsSharedOrganization(owner.ID.String())
+	assert.Nil(t, err)
+	assert.True(t, owns)
+	assert.ErrorIs(t, userDB.Delete(owner.ID.String()), entity.ErrOwnerCannotBeDeleted)
+	deleted, err := userDB.DeleteScheduled(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deleted)
+	_, err = userDB.FindByID(owner.ID.String())
+	assert.Nil(t, err)
+
+	// The member's own organization goes, but the products of the shared
+	// one stay.
+	assert.Nil(t, userDB.Delete(member.ID.String()))
+	_, err = userDB.FindByID(member.ID.String())
+	assert.Error(t, err)
+	_, err = orgDB.FindByID(personal.ID.String())
+	assert.Error(t, err)
+	var count int64
+	db.Model(&entity.Product{}).Where("id = ?", personalProduct.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	db.Model(&entity.Product{}).Where("id = ?", sharedProduct.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	memberships, err := orgDB.FindMembershipsByOrganizationID(shared.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, memberships, 1)
+
+	// Once alone in the organization, the owner can be deleted with it.
+	owns, err = userDB.OwnsSharedOrganization(owner.ID.String())
+	assert.Nil(t, err)
+	assert.False(t, owns)
+	deleted, err = userDB.DeleteScheduled(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+	db.Model(&entity.Product{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+	_, err = orgDB.FindByID(shared.ID.String())
+	assert.Error(t, err)
+}
+
+func TestFindAllUsers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDB := NewUserDB(db)
+	for _, name := range []string{"Diego", "Maria", "Mario", "Ana_Paula"} {
+		user, _ := entity.NewUser(name, strings.ToLower(name)+"@gmail.com", "123456")
+		if name == "Mario" {
+			user.Disable()
+		}
+		assert.Nil(t, userDB.Create(user))
+	}
+
+	users, total, err := userDB.FindAll(UserFilter{}, 1, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), total)
+	assert.Len(t, users, 3)
+	assert.Equal(t, "ana_paula@gmail.com", users[0].Email)
+
+	users, total, err = userDB.FindAll(UserFilter{Search: "MAR"}, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, users, 2)
+
+	disabled := true
+	users, _, err = userDB.FindAll(UserFilter{Search: "mar", Disabled: &disabled}, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "Mario", users[0].Name)
+
+	// Wildcards in the search match themselves.
+	users, _, err = userDB.FindAll(UserFilter{Search: "_"}, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "Ana_Paula", users[0].Name)
+}
+
+func TestUseTwoFactorCodeOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	userDB := NewUserDB(db)
+	now := time.Now()
+	user, _ := entity.NewUser("Diego", "diego@gmail.com", "123456")
+	assert.Nil(t, user.EnrollTwoFactor())
+	code, _ := totp.Code(user.TOTPSecret, totp.Counter(now.Add(-time.Minute)))
+	recoveryCodes, err := user.EnableTwoFactor(code, now.Add(-time.Minute))
+	assert.Nil(t, err)
+	assert.Nil(t, userDB.Create(user))
+
+	// Two concurrent requests load the user and verify the same code.
+	code, _ = totp.Code(user.TOTPSecret, totp.Counter(now))
+	first, _ := userDB.FindByID(user.ID.String())
+	second, _ := userDB.FindByID(user.ID.String())
+	for i, u := range []*entity.User{first, second} {
+		lastCounter, codes := u.TOTPLastCounter, u.RecoveryCodes
+		assert.Nil(t, u.VerifyTwoFactor(code, now))
+		used, err := userDB.UseTwoFactorCode(u, lastCounter, codes)
+		assert.Nil(t, err)
+		assert.Equal(t, i == 0, used)
+	}
+
+	// And then the same recovery code.
+	first, _ = userDB.FindByID(user.ID.String())
+	second, _ = userDB.FindByID(user.ID.String())
+	for i, u := range []*entity.User{first, second} {
+		lastCounter, codes := u.TOTPLastCounter, u.RecoveryCodes
+		assert.Nil(t, u.VerifyTwoFactor(recoveryCodes[0], now))
+		used, err := userDB.UseTwoFactorCode(u, lastCounter, codes)
+		assert.Nil(t, err)
+		assert.Equal(t, i == 0, used)
+	}
+	found, _ := userDB.FindByID(user.ID.String())
+	assert.Len(t, found.RecoveryCodes, len(recoveryCodes)-1)
+	assert.Equal(t, totp.Counter(now), found.TOTPLastCounter)
+}
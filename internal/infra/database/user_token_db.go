@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type UserTokenDB struct {
+	DB *gorm.DB
+}
+
+func NewUserTokenDB(db *gorm.DB) *UserTokenDB {
+	return &UserTokenDB{DB: db}
+}
+
+// WithContext returns a UserTokenDB whose queries join the trace of ctx.
+func (t *UserTokenDB) WithContext(ctx context.Context) UserTokenDBInterface {
+	return &UserTokenDB{DB: t.DB.WithContext(ctx)}
+}
+
+func (t *UserTokenDB) start(method string) (*UserTokenDB, trace.Span) {
+	db, span := startSpan(t.DB, "UserTokenDB."+method)
+	return &UserTokenDB{DB: db}, span
+}
+
+func (t *UserTokenDB) Create(token *entity.UserToken) error {
+	t, span := t.start("Create")
+	defer span.End()
+	return t.DB.Create(token).Error
+}
+
+func (t *UserTokenDB) FindByHash(purpose, hash string) (*entity.UserToken, error) {
+	t, span := t.start("FindByHash")
+	defer span.End()
+	var token entity.UserToken
+	if err := t.DB.Where("purpose = ? AND token_hash = ?", purpose, hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (t *UserTokenDB) Update(token *entity.UserToken) error {
+	t, span := t.start("Update")
+	defer span.End()
+	return t.DB.Save(token).Error
+}
+
+// Use marks token as used, unless it was used already, and reports whether
+// it did. The check and the change are a single statement, so of concurrent
+// calls for the same token only one uses it.
+func (t *UserTokenDB) Use(token *entity.UserToken) (bool, error) {
+	t, span := t.start("Use")
+	defer span.End()
+	now := time.Now()
+	result := t.DB.Model(&entity.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	token.UsedAt = &now
+	return true, nil
+}
+
+// InvalidateByUserID marks every unused token of the user for purpose as used.
+func (t *UserTokenDB) InvalidateByUserID(userID, purpose string) error {
+	t, span := t.start("InvalidateByUserID")
+	defer span.End()
+	return t.DB.Model(&entity.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestFindUserTokenByHash(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	tokenDB := NewUserTokenDB(db)
+	token, plain, err := entity.NewUserToken(service.NewID(), entity.TokenPurposeEmailVerification, time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, tokenDB.Create(token))
+
+	found, err := tokenDB.FindByHash(entity.TokenPurposeEmailVerification, service.HashToken(plain))
+	assert.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+
+	_, err = tokenDB.FindByHash(entity.TokenPurposePasswordReset, service.HashToken(plain))
+	assert.Error(t, err)
+}
+
+func TestInvalidateUserTokens(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	tokenDB := NewUserTokenDB(db)
+	userID := service.NewID()
+	reset, resetPlain, _ := entity.NewUserToken(userID, entity.TokenPurposePasswordReset, time.Hour)
+	verify, verifyPlain, _ := entity.NewUserToken(userID, entity.TokenPurposeEmailVerification, time.Hour)
+	assert.NoError(t, tokenDB.Create(reset))
+	assert.NoError(t, tokenDB.Create(verify))
+
+	assert.NoError(t, tokenDB.InvalidateByUserID(userID.String(), entity.TokenPurposePasswordReset))
+
+	found, _ := tokenDB.FindByHash(entity.TokenPurposePasswordReset, service.HashToken(resetPlain))
+	assert.False(t, found.IsUsable())
+	found, _ = tokenDB.FindByHash(entity.TokenPurposeEmailVerification, service.HashToken(verifyPlain))
+	assert.True(t, found.IsUsable())
+}
+
+func TestUseUserTokenOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	tokenDB := NewUserTokenDB(db)
+	token, plain, _ := entity.NewUserToken(service.NewID(), entity.TokenPurposeEmailVerification, time.Hour)
+	assert.NoError(t, tokenDB.Create(token))
+	// A concurrent request loaded the token before it was used.
+	stale, err := tokenDB.FindByHash(entity.TokenPurposeEmailVerification, service.HashToken(plain))
+	assert.NoError(t, err)
+
+	used, err := tokenDB.Use(token)
+	assert.NoError(t, err)
+	assert.True(t, used)
+	assert.False(t, token.IsUsable())
+
+	used, err = tokenDB.Use(stale)
+	assert.NoError(t, err)
+	assert.False(t, used)
+}
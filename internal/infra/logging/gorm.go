@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration above which queries are logged.
+const slowQueryThreshold = 200 * time.Millisecond
+
+// NewGormLogger returns a GORM logger writing the failed and the slow
+// queries to logger as warnings. Queries are logged without their values,
+// which may be secrets such as password hashes.
+func NewGormLogger(logger *slog.Logger) gormlogger.Interface {
+	return gormlogger.New(gormWriter{logger}, gormlogger.Config{
+		SlowThreshold:             slowQueryThreshold,
+		LogLevel:                  gormlogger.Warn,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+	})
+}
+
+type gormWriter struct {
+	logger *slog.Logger
+}
+
+func (w gormWriter) Printf(format string, args ...interface{}) {
+	w.logger.Warn("database", "message", fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,166 @@
+// Package logging builds the structured logger of the API. Log lines carry
+// the attributes of the request they are written for, such as its ID and its
+// user, and never the secrets of requests.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute keys, header names and query parameters
+// whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"password":            true,
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"api_key":             true,
+	"token":               true,
+	"secret":              true,
+}
+
+// New returns a logger writing to w at level, which is debug, info, warn or
+// error, in format, which is json or text. Both default when empty.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("unknown LOG_LEVEL %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch format {
+	case "json", "":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown LOG_FORMAT %q", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// IsSensitive reports whether the value of key, an attribute, a header or a
+// query parameter, is a secret. Keys ending with password, _token or
+// _secret are, such as new_password, refresh_token or client_secret.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	return sensitiveKeys[key] ||
+		strings.HasSuffix(key, "password") ||
+		strings.HasSuffix(key, "_token") ||
+		strings.HasSuffix(key, "_secret")
+}
+
+// redact replaces the values of sensitive attributes, and of the strings
+// holding a bearer token.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	if a.Value.Kind() == slog.KindString && strings.HasPrefix(strings.ToLower(a.Value.String()), "bearer ") {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// RedactQuery encodes query with the values of its sensitive parameters
+// replaced.
+func RedactQuery(query url.Values) string {
+	redactedQuery := make(url.Values, len(query))
+	for key, values := range query {
+		if IsSensitive(key) {
+			values = []string{redacted}
+		}
+		redactedQuery[key] = values
+	}
+	return redactedQuery.Encode()
+}
+
+type contextKey struct{}
+
+// requestAttrs are the attributes of a request. They are shared by the
+// contexts derived from the request's, so that the attributes added by the
+// inner handlers, such as its user, are logged by the outer ones as well.
+type requestAttrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// WithRequestID returns a context whose log lines carry the ID of the
+// request. Attributes can then be added to it with AddAttrs.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestAttrs{
+		attrs: []slog.Attr{slog.String("request_id", id)},
+	})
+}
+
+// AddAttrs adds attrs to the log lines of the request of ctx. It does
+// nothing when ctx has no request ID.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	request, ok := ctx.Value(contextKey{}).(*requestAttrs)
+	if !ok {
+		return
+	}
+	request.mu.Lock()
+	defer request.mu.Unlock()
+	request.attrs = append(request.attrs, attrs...)
+}
+
+// SetUserID adds the ID of the authenticated user to the log lines of the
+// request of ctx.
+func SetUserID(ctx context.Context, userID string) {
+	AddAttrs(ctx, slog.String("user_id", userID))
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	request, ok := ctx.Value(contextKey{}).(*requestAttrs)
+	if !ok {
+		return nil
+	}
+	request.mu.Lock()
+	defer request.mu.Unlock()
+	return append([]slog.Attr(nil), request.attrs...)
+}
+
+// contextHandler adds the attributes of the request of the context, and
+// the IDs of its span, to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(attrsFrom(ctx)...)
+	if ctx != nil {
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
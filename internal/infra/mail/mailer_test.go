@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := NewMemoryMailer()
+	msg := Message{To: "diego@gmail.com", Subject: "Hello", Body: "Hi"}
+	assert.NoError(t, mailer.Send(context.Background(), msg))
+	assert.Equal(t, []Message{msg}, mailer.Messages())
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer := NewFileMailer(dir, "no-reply@example.com")
+	msg := Message{To: "diego@gmail.com", Subject: "Hello", Body: "Hi\nthere"}
+	assert.NoError(t, mailer.Send(context.Background(), msg))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	content := string(data)
+	assert.True(t, strings.HasPrefix(content, "From: no-reply@example.com\r\nTo: diego@gmail.com\r\nSubject: Hello\r\n"))
+	assert.True(t, strings.HasSuffix(content, "\r\n\r\nHi\r\nthere"))
+}
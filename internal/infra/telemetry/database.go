@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/diegopontes87/api/pkg/metrics"
+	"gorm.io/gorm"
+)
+
+const startedAtKey = "telemetry:started_at"
+
+// InstrumentDB measures the queries of db through GORM callbacks, and
+// exposes the statistics of its connection pool.
+func (m *Metrics) InstrumentDB(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := errors.Join(
+		callbacks.Create().Before("gorm:create").Register("telemetry:before_create", startQuery),
+		callbacks.Create().After("gorm:create").Register("telemetry:after_create", m.endQuery("create")),
+		callbacks.Query().Before("gorm:query").Register("telemetry:before_query", startQuery),
+		callbacks.Query().After("gorm:query").Register("telemetry:after_query", m.endQuery("query")),
+		callbacks.Update().Before("gorm:update").Register("telemetry:before_update", startQuery),
+		callbacks.Update().After("gorm:update").Register("telemetry:after_update", m.endQuery("update")),
+		callbacks.Delete().Before("gorm:delete").Register("telemetry:before_delete", startQuery),
+		callbacks.Delete().After("gorm:delete").Register("telemetry:after_delete", m.endQuery("delete")),
+		callbacks.Row().Before("gorm:row").Register("telemetry:before_row", startQuery),
+		callbacks.Row().After("gorm:row").Register("telemetry:after_row", m.endQuery("row")),
+		callbacks.Raw().Before("gorm:raw").Register("telemetry:before_raw", startQuery),
+		callbacks.Raw().After("gorm:raw").Register("telemetry:after_raw", m.endQuery("raw")),
+	)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	m.Registry.MustRegister(metrics.CollectorFunc(func() []metrics.Family {
+		return poolFamilies(sqlDB.Stats())
+	}))
+	return nil
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(startedAtKey, time.Now())
+}
+
+// endQuery returns the callback recording the duration of the queries of
+// operation and, unless they found no record, their failures.
+func (m *Metrics) endQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		if startedAt, ok := db.InstanceGet(startedAtKey); ok {
+			m.DBQueryDuration.With(operation, table).Observe(time.Since(startedAt.(time.Time)).Seconds())
+		}
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			m.DBQueryErrors.With(operation, table).Inc()
+		}
+	}
+}
+
+// poolFamilies returns the statistics of a connection pool as families.
+func poolFamilies(stats sql.DBStats) []metrics.Family {
+	return []metrics.Family{
+		{
+			Name: "db_connections", Help: "Number of open connections, by state.", Type: metrics.TypeGauge,
+			Samples: []metrics.Sample{
+				{Labels: []metrics.Label{{Name: "state", Value: "idle"}}, Value: float64(stats.Idle)},
+				{Labels: []metrics.Label{{Name: "state", Value: "in_use"}}, Value: float64(stats.InUse)},
+			},
+		},
+		{
+			Name: "db_connections_max_open", Help: "Maximum number of open connections, 0 for no limit.", Type: metrics.TypeGauge,
+			Samples: []metrics.Sample{{Value: float64(stats.MaxOpenConnections)}},
+		},
+		{
+			Name: "db_connection_waits_total", Help: "Number of waits for a free connection.", Type: metrics.TypeCounter,
+			Samples: []metrics.Sample{{Value: float64(stats.WaitCount)}},
+		},
+		{
+			Name: "db_connection_wait_seconds_total", Help: "Time spent waiting for a free connection.", Type: metrics.TypeCounter,
+			Samples: []metrics.Sample{{Value: stats.WaitDuration.Seconds()}},
+		},
+		{
+			Name: "db_connections_closed_total", Help: "Number of connections closed by the pool, by reason.", Type: metrics.TypeCounter,
+			Samples: []metrics.Sample{
+				{Labels: []metrics.Label{{Name: "reason", Value: "max_idle"}}, Value: float64(stats.MaxIdleClosed)},
+				{Labels: []metrics.Label{{Name: "reason", Value: "max_idle_time"}}, Value: float64(stats.MaxIdleTimeClosed)},
+				{Labels: []metrics.Label{{Name: "reason", Value: "max_lifetime"}}, Value: float64(stats.MaxLifetimeClosed)},
+			},
+		},
+	}
+}
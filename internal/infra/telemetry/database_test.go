@@ -0,0 +1,55 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestInstrumentDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+	m := NewMetrics("test")
+	assert.Nil(t, m.InstrumentDB(db))
+
+	product, _ := entity.NewProduct("Product 1", 10)
+	assert.Nil(t, db.Create(product).Error)
+	var found entity.Product
+	assert.Nil(t, db.First(&found, "id = ?", product.ID).Error)
+	// Finding no record is not a failure.
+	assert.NotNil(t, db.First(&found, "id = ?", "none").Error)
+	assert.NotNil(t, db.Create(product).Error)
+
+	var text strings.Builder
+	metrics.WriteText(&text, m.Registry.Gather())
+	assert.Contains(t, text.String(), `db_query_duration_seconds_count{operation="create",table="products"} 2`)
+	assert.Contains(t, text.String(), `db_query_duration_seconds_count{operation="query",table="products"} 2`)
+	assert.Contains(t, text.String(), `db_query_errors_total{operation="create",table="products"} 1`)
+	assert.NotContains(t, text.String(), `db_query_errors_total{operation="query"`)
+	assert.Contains(t, text.String(), `db_connections{state="idle"}`)
+	assert.Contains(t, text.String(), "db_connections_max_open 0\n")
+}
+
+func TestRecordLogin(t *testing.T) {
+	m := NewMetrics("test")
+	m.RecordLogin(LoginFailure)
+	m.RecordLogin(LoginFailure)
+	m.RecordLogin(LoginSuccess)
+
+	var text strings.Builder
+	metrics.WriteText(&text, m.LoginAttempts.Collect())
+	assert.Contains(t, text.String(), `auth_login_attempts_total{result="failure"} 2`)
+	assert.Contains(t, text.String(), `auth_login_attempts_total{result="success"} 1`)
+	assert.Contains(t, text.String(), `auth_login_attempts_total{result="locked_out"} 0`)
+
+	var none *Metrics
+	none.RecordLogin(LoginSuccess)
+}
@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "telemetry:span"
+
+var tracer = otel.Tracer("github.com/diegopontes87/api/internal/infra/telemetry")
+
+// TraceDB starts a span for every SQL statement of db, as a child of the
+// context the statement runs in. The statements are recorded without the
+// values of their parameters.
+func TraceDB(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("telemetry:start_create_span", startSpan("create")),
+		callbacks.Create().After("gorm:create").Register("telemetry:end_create_span", endSpan),
+		callbacks.Query().Before("gorm:query").Register("telemetry:start_query_span", startSpan("query")),
+		callbacks.Query().After("gorm:query").Register("telemetry:end_query_span", endSpan),
+		callbacks.Update().Before("gorm:update").Register("telemetry:start_update_span", startSpan("update")),
+		callbacks.Update().After("gorm:update").Register("telemetry:end_update_span", endSpan),
+		callbacks.Delete().Before("gorm:delete").Register("telemetry:start_delete_span", startSpan("delete")),
+		callbacks.Delete().After("gorm:delete").Register("telemetry:end_delete_span", endSpan),
+		callbacks.Row().Before("gorm:row").Register("telemetry:start_row_span", startSpan("row")),
+		callbacks.Row().After("gorm:row").Register("telemetry:end_row_span", endSpan),
+		callbacks.Raw().Before("gorm:raw").Register("telemetry:start_raw_span", startSpan("raw")),
+		callbacks.Raw().After("gorm:raw").Register("telemetry:end_raw_span", endSpan),
+	)
+}
+
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		name := "gorm." + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		ctx, span := tracer.Start(db.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemSqlite,
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(db.Statement.Table),
+			))
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// endSpan ends the span of a statement, which failed unless it only found
+// no record.
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
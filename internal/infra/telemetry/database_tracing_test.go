@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTraceDB(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+	assert.Nil(t, TraceDB(db))
+
+	ctx, request := otel.Tracer("test").Start(context.Background(), "GET /users/{id}")
+	_, err = database.NewUserDB(db).WithContext(ctx).FindByID("none")
+	assert.NotNil(t, err)
+	assert.NotNil(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+	request.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 4)
+	query, call, raw := spans[0], spans[1], spans[2]
+
+	// The statement is a child of the repository call, itself a child of
+	// the request, and finding no record is not a failure.
+	assert.Equal(t, "UserDB.FindByID", call.Name())
+	assert.Equal(t, request.SpanContext().SpanID(), call.Parent().SpanID())
+	assert.Equal(t, "gorm.query users", query.Name())
+	assert.Equal(t, call.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Equal(t, codes.Unset, query.Status().Code)
+	assert.Contains(t, query.Attributes(), semconv.DBCollectionName("users"))
+	// Parameters are bound, so their values stay out of the statement.
+	assert.Contains(t, query.Attributes(), semconv.DBQueryText("SELECT * FROM `users` WHERE id = ? ORDER BY `users`.`id` LIMIT 1"))
+
+	assert.Equal(t, "gorm.raw", raw.Name())
+	assert.Equal(t, request.SpanContext().SpanID(), raw.Parent().SpanID())
+	assert.Equal(t, codes.Error, raw.Status().Code)
+}
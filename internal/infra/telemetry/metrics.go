@@ -0,0 +1,109 @@
+// Package telemetry defines what the API reports about itself: the metrics
+// of its requests, of its database and of its sign-ins.
+package telemetry
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/diegopontes87/api/pkg/metrics"
+)
+
+// Results of sign-in attempts.
+const (
+	LoginSuccess = "success"
+	// LoginFailure is an unknown email or a wrong password.
+	LoginFailure = "failure"
+	// LoginLockedOut is an attempt refused because of previous failures.
+	LoginLockedOut = "locked_out"
+	// LoginRefused is a valid password of an account that cannot sign in,
+	// such as a disabled one.
+	LoginRefused = "refused"
+)
+
+// Metrics are the metrics of the API, served by Registry.
+type Metrics struct {
+	Registry *metrics.Registry
+	// HTTPRequestDuration is labelled by the route pattern rather than the
+	// path, so IDs do not make a series each.
+	HTTPRequestDuration  *metrics.HistogramVec
+	HTTPRequestsInFlight *metrics.GaugeVec
+	DBQueryDuration      *metrics.HistogramVec
+	DBQueryErrors        *metrics.CounterVec
+	LoginAttempts        *metrics.CounterVec
+}
+
+// NewMetrics returns the metrics of the API, along with the build info of
+// the given version.
+func NewMetrics(version string) *Metrics {
+	m := &Metrics{
+		Registry: metrics.NewRegistry(),
+		HTTPRequestDuration: metrics.NewHistogramVec("http_request_duration_seconds",
+			"Duration of the HTTP requests, by route pattern, method and status.",
+			metrics.DefBuckets, "route", "method", "status"),
+		HTTPRequestsInFlight: metrics.NewGaugeVec("http_requests_in_flight",
+			"Number of HTTP requests being served."),
+		DBQueryDuration: metrics.NewHistogramVec("db_query_duration_seconds",
+			"Duration of the database queries, by operation and table.",
+			metrics.DefBuckets, "operation", "table"),
+		DBQueryErrors: metrics.NewCounterVec("db_query_errors_total",
+			"Number of failed database queries, by operation and table.",
+			"operation", "table"),
+		LoginAttempts: metrics.NewCounterVec("auth_login_attempts_total",
+			"Number of sign-in attempts with a password, by result.",
+			"result"),
+	}
+	// Every result is exposed from the start, so rates can be computed
+	// before the first failure.
+	for _, result := range []string{LoginSuccess, LoginFailure, LoginLockedOut, LoginRefused} {
+		m.LoginAttempts.With(result)
+	}
+	m.HTTPRequestsInFlight.With()
+	m.Registry.MustRegister(
+		m.HTTPRequestDuration,
+		m.HTTPRequestsInFlight,
+		m.DBQueryDuration,
+		m.DBQueryErrors,
+		m.LoginAttempts,
+		buildInfo(version),
+	)
+	return m
+}
+
+// RecordLogin counts a sign-in attempt with result. It does nothing on nil
+// metrics.
+func (m *Metrics) RecordLogin(result string) {
+	if m == nil {
+		return
+	}
+	m.LoginAttempts.With(result).Inc()
+}
+
+// buildInfo exposes the version of the API, the VCS revision it was built
+// from and the version of Go that built it.
+func buildInfo(version string) metrics.Collector {
+	revision := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+	family := metrics.Family{
+		Name: "app_build_info",
+		Help: "Build of the API. The value is always 1.",
+		Type: metrics.TypeGauge,
+		Samples: []metrics.Sample{{
+			Labels: []metrics.Label{
+				{Name: "version", Value: version},
+				{Name: "revision", Value: revision},
+				{Name: "go_version", Value: runtime.Version()},
+			},
+			Value: 1,
+		}},
+	}
+	return metrics.CollectorFunc(func() []metrics.Family {
+		return []metrics.Family{family}
+	})
+}
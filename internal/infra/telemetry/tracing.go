@@ -0,0 +1,67 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName is the name of the API in its traces.
+const ServiceName = "products-api"
+
+// Exporters of spans.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// NewTracerProvider returns the provider of the spans of the given version,
+// sent to exporter, and installs it along with the W3C trace context
+// propagator. The OTLP exporter sends spans over HTTP to endpoint, or to
+// the endpoint of the OTEL_EXPORTER_OTLP_* variables when it is empty.
+// Spans are dropped with the "none" exporter, but trace IDs are still
+// propagated. The provider must be shut down to flush the last spans.
+func NewTracerProvider(ctx context.Context, exporter, endpoint, version string) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	switch exporter {
+	case ExporterNone, "":
+	case ExporterStdout:
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(spanExporter))
+	case ExporterOTLP:
+		var otlpOptions []otlptracehttp.Option
+		if endpoint != "" {
+			otlpOptions = append(otlpOptions, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		spanExporter, err := otlptracehttp.New(ctx, otlpOptions...)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(spanExporter))
+	default:
+		return nil, fmt.Errorf("unknown TRACE_EXPORTER %q", exporter)
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider, nil
+}
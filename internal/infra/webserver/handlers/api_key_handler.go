@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+)
+
+type APIKeyHandler struct {
+	APIKeyDB database.APIKeyDBInterface
+}
+
+func NewAPIKeyHandler(db database.APIKeyDBInterface) *APIKeyHandler {
+	return &APIKeyHandler{
+		APIKeyDB: db,
+	}
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Create an API key for machine clients to send in the X-API-Key header. Its scopes must be
+// @Description  permissions of the authenticated user. The key is only returned in this response
+// @Tags         api keys
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.CreateAPIKeyInput  true  "api key request"
+// @Success      201   {object}  dto.CreateAPIKeyOutput
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      422   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me/api_keys [post]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateAPIKeyInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if err := input.Validate(); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	userID, _ := currentUser(r)
+	id, err := service.ParseID(userID)
+	if err != nil {
+		problem.Respond(w, r, http.StatusUnauthorized, "invalid_token_subject", "invalid token subject")
+		return
+	}
+	key, plain, err := entity.NewAPIKey(id, input.Name, input.Scopes, input.AllowedIPs, time.Second*time.Duration(input.ExpiresIn))
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	key.OrganizationID = currentOrganization(r)
+	if len(key.Permissions(currentPermissions(r))) != len(key.Scopes) {
+		problem.Error(w, r, http.StatusForbidden, entity.ErrScopeNotGranted)
+		return
+	}
+	if err = h.APIKeyDB.WithContext(r.Context()).Create(key); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.CreateAPIKeyOutput{Key: plain, APIKey: key})
+}
+
+// GetAPIKeys   godoc
+// @Summary      List API keys
+// @Description  List the API keys of the authenticated user, including revoked and expired ones
+// @Tags         api keys
+// @Produce      json
+// @Success      200   {array}   entity.APIKey
+// @Failure      401   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me/api_keys [get]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _ := currentUser(r)
+	keys, err := h.APIKeyDB.WithContext(r.Context()).FindAllByUserID(userID)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Revoke an API key of the authenticated user. It is rejected from then on
+// @Tags         api keys
+// @Produce      json
+// @Param        id  path  string  true  "api key ID" Format(uuid)
+// @Success      204
+// @Failure      401   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me/api_keys/{id} [delete]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID, _ := currentUser(r)
+	key, err := h.APIKeyDB.WithContext(r.Context()).FindByID(id)
+	// Keys of other users are reported as missing, not forbidden, so that
+	// their IDs cannot be probed.
+	if err != nil || key.UserID.String() != userID {
+		problem.Respond(w, r, http.StatusNotFound, "api_key_not_found", "api key not found")
+		return
+	}
+	if !key.IsRevoked() {
+		key.Revoke()
+		if err = h.APIKeyDB.WithContext(r.Context()).Update(key); err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentPermissions returns the permissions of the verified token.
+func currentPermissions(r *http.Request) []string {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	var permissions []string
+	switch granted := claims["permissions"].(type) {
+	case []interface{}:
+		for _, p := range granted {
+			if s, ok := p.(string); ok {
+				permissions = append(permissions, s)
+			}
+		}
+	case []string:
+		permissions = granted
+	}
+	return permissions
+}
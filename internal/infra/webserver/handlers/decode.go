@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var tracer = otel.Tracer("github.com/diegopontes87/api/internal/infra/webserver/handlers")
+
+// maxBodyBytes bounds the size of the JSON bodies the handlers decode.
+const maxBodyBytes = 1 << 20
+
+// decodeError is a body that cannot be decoded, along with the problem
+// describing why.
+type decodeError struct {
+	status int
+	code   string
+	// field is the field of the body the error is about, if any.
+	field  string
+	detail string
+}
+
+func (e *decodeError) Error() string {
+	return e.detail
+}
+
+// decodeJSON decodes the body of r, which must be a single JSON object of at
+// most maxBodyBytes sent as application/json, into dst. Fields dst does not
+// have are refused. It writes a problem and returns false when the body
+// cannot be decoded.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	_, span := tracer.Start(r.Context(), "json.decode")
+	err := readJSON(w, r, dst)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	if err == nil {
+		return true
+	}
+	var decodeErr *decodeError
+	if !errors.As(err, &decodeErr) {
+		// Such as the errors of the fields that decode themselves.
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return false
+	}
+	p := problem.New(r, decodeErr.status, decodeErr.code, decodeErr.detail)
+	if decodeErr.field != "" {
+		p.Errors = []entity.FieldError{{Field: decodeErr.field, Rule: decodeErr.code, Message: decodeErr.detail}}
+	}
+	problem.Write(w, p)
+	return false
+}
+
+// readJSON does the decoding of decodeJSON, returning a *decodeError when
+// the body is refused.
+func readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	if !isJSON(r.Header.Get("Content-Type")) {
+		return &decodeError{http.StatusUnsupportedMediaType, "unsupported_media_type", "",
+			"Content-Type must be application/json"}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	// The body is read as a raw value first, so that its kind is checked
+	// before decoding it: a null would leave dst untouched.
+	var raw json.RawMessage
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&raw); err != nil {
+		return bodyError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return bodyError(err)
+		}
+		return &decodeError{http.StatusBadRequest, "multiple_json_values", "",
+			"body must contain a single JSON object"}
+	}
+	if raw[0] != '{' {
+		return &decodeError{http.StatusBadRequest, "invalid_body", "", "body must be a JSON object"}
+	}
+
+	dec = json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return bodyError(err)
+	}
+	return nil
+}
+
+// bodyError turns an error of encoding/json into a *decodeError. Errors it
+// does not know are returned as is.
+func bodyError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &decodeError{http.StatusRequestEntityTooLarge, "body_too_large", "",
+			fmt.Sprintf("body must not be larger than %d bytes", maxBytesErr.Limit)}
+	case err == io.EOF:
+		return &decodeError{http.StatusBadRequest, "empty_body", "", "body must not be empty"}
+	case errors.As(err, &syntaxErr):
+		return &decodeError{http.StatusBadRequest, "malformed_json", "",
+			fmt.Sprintf("body contains malformed JSON at offset %d", syntaxErr.Offset)}
+	case err == io.ErrUnexpectedEOF:
+		return &decodeError{http.StatusBadRequest, "malformed_json", "", "body contains malformed JSON"}
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return &decodeError{http.StatusBadRequest, "invalid_body", "", "body must be a JSON object"}
+		}
+		detail := typeErr.Field + " has an invalid type"
+		if kind := jsonKind(typeErr.Type.String()); kind != "" {
+			detail = typeErr.Field + " must be a " + kind
+		}
+		return &decodeError{http.StatusBadRequest, "invalid_field_type", typeErr.Field, detail}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no type for this error.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &decodeError{http.StatusBadRequest, "unknown_field", field, "unknown field " + field}
+	}
+	return err
+}
+
+// jsonKind names the JSON kind a Go type is decoded from, or returns an
+// empty string for the types that decode themselves.
+func jsonKind(goType string) string {
+	switch {
+	case goType == "string":
+		return "string"
+	case goType == "bool":
+		return "boolean"
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"),
+		strings.HasPrefix(goType, "float"):
+		return "number"
+	case strings.HasPrefix(goType, "[]"):
+		return "array"
+	case strings.HasPrefix(goType, "map["):
+		return "JSON object"
+	}
+	return ""
+}
+
+// isJSON reports whether contentType is application/json or a +json type.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" ||
+		strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeRequest(contentType, body string) (*httptest.ResponseRecorder, dto.CreateProductInput, bool) {
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	var input dto.CreateProductInput
+	ok := decodeJSON(rec, req, &input)
+	return rec, input, ok
+}
+
+func TestDecodeJSON(t *testing.T) {
+	rec, input, ok := decodeRequest("application/json; charset=utf-8", `{"name": "Product 1", "price": 10}`)
+	assert.True(t, ok)
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "Product 1", input.Name)
+	assert.Equal(t, 10.0, input.Price)
+}
+
+func TestDecodeJSONRefusesBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		field       string
+	}{
+		{"missing content type", "", `{"name": "Product 1"}`, 415, "unsupported_media_type", ""},
+		{"other content type", "text/plain", `{"name": "Product 1"}`, 415, "unsupported_media_type", ""},
+		{"empty", "application/json", ``, 400, "empty_body", ""},
+		{"null", "application/json", `null`, 400, "invalid_body", ""},
+		{"array", "application/json", `[{"name": "Product 1"}]`, 400, "invalid_body", ""},
+		{"malformed", "application/json", `{"name": "Product 1",}`, 400, "malformed_json", ""},
+		{"truncated", "application/json", `{"name": "Product 1"`, 400, "malformed_json", ""},
+		{"trailing object", "application/json", `{"name": "Product 1"}{"name": "Product 2"}`, 400, "multiple_json_values", ""},
+		{"trailing garbage", "application/json", `{"name": "Product 1"} garbage`, 400, "multiple_json_values", ""},
+		{"unknown field", "application/json", `{"name": "Product 1", "owner": "me"}`, 400, "unknown_field", "owner"},
+		{"wrong type", "application/json", `{"name": "Product 1", "price": "10"}`, 400, "invalid_field_type", "price"},
+		{"too large", "application/json", `{"name": "` + strings.Repeat("a", maxBodyBytes) + `"}`, 413, "body_too_large", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _, ok := decodeRequest(tt.contentType, tt.body)
+			assert.False(t, ok)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+			var p entity.Error
+			assert.Nil(t, json.NewDecoder(rec.Body).Decode(&p))
+			assert.Equal(t, tt.code, p.Code)
+			if tt.field == "" {
+				assert.Empty(t, p.Errors)
+			} else {
+				assert.Len(t, p.Errors, 1)
+				assert.Equal(t, tt.field, p.Errors[0].Field)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/diegopontes87/api/internal/infra/auth"
+)
+
+type JWKSHandler struct {
+	Keys *auth.KeySet
+}
+
+func NewJWKSHandler(keys *auth.KeySet) *JWKSHandler {
+	return &JWKSHandler{
+		Keys: keys,
+	}
+}
+
+// GetJWKS       godoc
+// @Summary      JSON Web Key Set
+// @Description  Public keys that verify the access tokens issued by this API, identified by kid
+// @Tags         auth
+// @Produce      json
+// @Success      200
+// @Router       /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.Keys.PublicKeys())
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/go-chi/chi"
+)
+
+// CreateOAuthClient godoc
+// @Summary      Register an OAuth client
+// @Description  Register an application allowed to request tokens at /oauth/token with the given grant types. Its
+// @Description  scopes must be permissions of the authenticated user. The secret is only returned in this response
+// @Tags         oauth
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.CreateOAuthClientInput  true  "client request"
+// @Success      201   {object}  dto.CreateOAuthClientOutput
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me/oauth_clients [post]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateOAuthClientInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	userID, _ := currentUser(r)
+	id, err := service.ParseID(userID)
+	if err != nil {
+		problem.Respond(w, r, http.StatusUnauthorized, "invalid_token_subject", "invalid token subject")
+		return
+	}
+	client, secret, err := entity.NewOAuthClient(id, input.Name, input.Scopes, input.GrantTypes)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	client.OrganizationID = currentOrganization(r)
+	if len(entity.GrantedOnly(client.Scopes, currentPermissions(r))) != len(client.Scopes) {
+		problem.Error(w, r, http.StatusForbidden, entity.ErrScopeNotGranted)
+		return
+	}
+	if err = h.OAuthClientDB.WithContext(r.Context()).Create(client); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.CreateOAuthClientOutput{ClientID: client.ID.String(), ClientSecret: secret, Client: client})
+}
+
+// GetOAuthClients godoc
+// @Summary      List OAuth clients
+// @Description  List the OAuth clients registered by the authenticated user, including revoked ones
+// @Tags         oauth
+// @Produce      json
+// @Success      200   {array}   entity.OAuthClient
+// @Failure      401   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me/oauth_clients [get]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) GetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userID, _ := currentUser(r)
+	clients, err := h.OAuthClientDB.WithContext(r.Context()).FindAllByUserID(userID)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(clients)
+}
+
+// RevokeOAuthClient godoc
+// @Summary      Revoke an OAuth client
+// @Description  Revoke an OAuth client registered by the authenticated user. It can no longer request or refresh tokens
+// @Tags         oauth
+// @Produce      json
+// @Param        id  path  string  true  "client ID" Format(uuid)
+// @Success      204
+// @Failure      401   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me/oauth_clients/{id} [delete]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) RevokeOAuthClient(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID, _ := currentUser(r)
+	client, err := h.OAuthClientDB.WithContext(r.Context()).FindByID(id)
+	if err != nil || client.UserID.String() != userID {
+		problem.Respond(w, r, http.StatusNotFound, "oauth_client_not_found", "oauth client not found")
+		return
+	}
+	if !client.IsRevoked() {
+		client.Revoke()
+		if err = h.OAuthClientDB.WithContext(r.Context()).Update(client); err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
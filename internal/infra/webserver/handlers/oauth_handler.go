@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+// Error codes of RFC 6749, section 5.2.
+const (
+	oauthInvalidRequest       = "invalid_request"
+	oauthInvalidClient        = "invalid_client"
+	oauthInvalidGrant         = "invalid_grant"
+	oauthUnauthorizedClient   = "unauthorized_client"
+	oauthUnsupportedGrantType = "unsupported_grant_type"
+	oauthInvalidScope         = "invalid_scope"
+)
+
+// OAuthHandler serves the OAuth 2.0 token endpoint. It signs users in the
+// same way as UserHandler, whose tokens it issues.
+type OAuthHandler struct {
+	Users         *UserHandler
+	OAuthClientDB database.OAuthClientDBInterface
+}
+
+func NewOAuthHandler(users *UserHandler, clientDB database.OAuthClientDBInterface) *OAuthHandler {
+	return &OAuthHandler{
+		Users:         users,
+		OAuthClientDB: clientDB,
+	}
+}
+
+// Token        godoc
+// @Summary      Request an OAuth 2.0 token
+// @Description  Token endpoint of RFC 6749 supporting the password, client_credentials and refresh_token grants.
+// @Description  Clients authenticate with HTTP Basic or with the client_id and client_secret parameters. The password
+// @Description  and refresh_token grants may also be used without a client. Users with two-factor authentication have
+// @Description  to sign in at /users/generate_token instead
+// @Tags         oauth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        grant_type     formData  string  true   "grant type"  Enums(password, client_credentials, refresh_token)
+// @Param        username       formData  string  false  "email, for the password grant"
+// @Param        password       formData  string  false  "password, for the password grant"
+// @Param        refresh_token  formData  string  false  "refresh token, for the refresh_token grant"
+// @Param        scope          formData  string  false  "space separated permissions"
+// @Param        client_id      formData  string  false  "client ID"
+// @Param        client_secret  formData  string  false  "client secret"
+// @Success      200   {object}  dto.OAuthTokenOutput
+// @Failure      400   {object}  dto.OAuthError
+// @Failure      401   {object}  dto.OAuthError
+// @Failure      429   {object}  dto.OAuthError
+// @Failure      500   {object}  dto.OAuthError
+// @Router       /oauth/token [post]
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/x-www-form-urlencoded" {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "the request must be form encoded")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeOAuthError(w, r, http.StatusRequestEntityTooLarge, oauthInvalidRequest, "the request body is too large")
+			return
+		}
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+	for name, values := range r.PostForm {
+		if len(values) > 1 {
+			writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, name+" is repeated")
+			return
+		}
+	}
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	grantType := r.PostForm.Get("grant_type")
+	switch grantType {
+	case entity.GrantTypePassword, entity.GrantTypeClientCredentials, entity.GrantTypeRefreshToken:
+	case "":
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "grant_type is required")
+		return
+	default:
+		writeOAuthError(w, r, http.StatusBadRequest, oauthUnsupportedGrantType, "")
+		return
+	}
+	if client != nil && !client.AllowsGrantType(grantType) {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthUnauthorizedClient, "the client may not use the "+grantType+" grant")
+		return
+	}
+	switch grantType {
+	case entity.GrantTypePassword:
+		h.passwordGrant(w, r, client)
+	case entity.GrantTypeClientCredentials:
+		h.clientCredentialsGrant(w, r, client)
+	case entity.GrantTypeRefreshToken:
+		h.refreshTokenGrant(w, r, client)
+	}
+}
+
+func (h *OAuthHandler) passwordGrant(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient) {
+	username, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+	if username == "" || password == "" {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "username and password are required")
+		return
+	}
+	var allowed []string
+	if client != nil {
+		allowed = client.Scopes
+	}
+	scopes, ok := requestedScopes(w, r, allowed)
+	if !ok {
+		return
+	}
+	user, wait, err := h.Users.checkCredentials(r, username, password)
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+		writeOAuthError(w, r, http.StatusTooManyRequests, oauthInvalidGrant, "too many failed sign-in attempts, try again later")
+		return
+	}
+	if err != nil {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidGrant, err.Error())
+		return
+	}
+	if user.IsTwoFactorEnabled() {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidGrant, "two-factor authentication is required, sign in at /users/generate_token")
+		return
+	}
+	if err := h.Users.keepAccount(r, user); err != nil {
+		writeOAuthError(w, r, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	grant := tokenGrant{Scopes: scopes, ClientID: clientID(client)}
+	refreshToken, err := h.Users.issueRefreshToken(r, user, service.NewID(), grant)
+	if err != nil {
+		writeOAuthError(w, r, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	h.writeToken(w, r, user, grant, refreshToken)
+}
+
+// clientCredentialsGrant issues tokens acting on behalf of the user who
+// registered the client. No refresh token is issued, as the client can
+// request a new token at any time.
+func (h *OAuthHandler) clientCredentialsGrant(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient) {
+	if client == nil {
+		writeOAuthError(w, r, http.StatusUnauthorized, oauthInvalidClient, "client authentication is required")
+		return
+	}
+	scopes, ok := requestedScopes(w, r, client.Scopes)
+	if !ok {
+		return
+	}
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	user, err := h.Users.UserDB.WithContext(r.Context()).FindByID(client.UserID.String())
+	if err != nil || user.IsDeletionScheduled() || user.CheckAccess(opts.UnverifiedAccess) != nil {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidGrant, "the client's user is no longer active")
+		return
+	}
+	h.writeToken(w, r, user, tokenGrant{Scopes: scopes, ClientID: client.ID.String(), OrganizationID: client.OrganizationID}, "")
+}
+
+// refreshTokenGrant rotates a refresh token the same way /users/refresh
+// does. The tokens of a client can only be exchanged by that client.
+func (h *OAuthHandler) refreshTokenGrant(w http.ResponseWriter, r *http.Request, client *entity.OAuthClient) {
+	plain := r.PostForm.Get("refresh_token")
+	if plain == "" {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "refresh_token is required")
+		return
+	}
+	token, user, err := h.Users.findRefreshToken(r, plain, clientID(client))
+	if err != nil {
+		writeRefreshTokenError(w, r, err)
+		return
+	}
+	// The new access token may ask for less than the refresh token grants,
+	// while the new refresh token keeps granting it all.
+	scopes, ok := requestedScopes(w, r, token.Scopes)
+	if !ok {
+		return
+	}
+	if err := h.Users.rotateRefreshToken(r, token); err != nil {
+		writeRefreshTokenError(w, r, err)
+		return
+	}
+	refreshGrant := tokenGrant{Scopes: token.Scopes, ClientID: token.ClientID, OrganizationID: token.OrganizationID}
+	refreshToken, err := h.Users.issueRefreshToken(r, user, token.FamilyID, refreshGrant)
+	if err != nil {
+		writeOAuthError(w, r, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	h.writeToken(w, r, user, tokenGrant{Scopes: scopes, ClientID: token.ClientID, OrganizationID: token.OrganizationID}, refreshToken)
+}
+
+// writeRefreshTokenError writes the error of a refresh token that cannot be
+// exchanged as a RFC 6749 error response.
+func writeRefreshTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errInvalidRefreshToken) || errors.Is(err, entity.ErrRefreshTokenRevoked) ||
+		errors.Is(err, entity.ErrRefreshTokenExpired) || isAccessRefused(err) {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidGrant, err.Error())
+		return
+	}
+	writeOAuthError(w, r, http.StatusInternalServerError, "server_error", err.Error())
+}
+
+// writeToken issues an access token and writes it along with refreshToken,
+// if any, as a RFC 6749 token response.
+func (h *OAuthHandler) writeToken(w http.ResponseWriter, r *http.Request, user *entity.User, grant tokenGrant, refreshToken string) {
+	accessToken, permissions, err := h.Users.issueAccessToken(r, user, grant)
+	if err != nil {
+		writeOAuthError(w, r, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	output := dto.OAuthTokenOutput{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    r.Context().Value("JwtExpiresIn").(int),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(permissions, " "),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// authenticateClient returns the client authenticating the request with
+// HTTP Basic or with the client_id and client_secret parameters, or nil
+// when there is none. It writes the error response and returns false when
+// the authentication fails.
+func (h *OAuthHandler) authenticateClient(w http.ResponseWriter, r *http.Request) (*entity.OAuthClient, bool) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749, section 2.3.1, form encodes the credentials first.
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		if r.PostForm.Get("client_id") != "" || r.PostForm.Get("client_secret") != "" {
+			writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "the client must authenticate with a single method")
+			return nil, false
+		}
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id == "" && secret == "" {
+		return nil, true
+	}
+	client, err := h.OAuthClientDB.WithContext(r.Context()).FindByID(id)
+	if err != nil || !client.Authenticate(secret) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, r, http.StatusUnauthorized, oauthInvalidClient, "client authentication failed")
+		return nil, false
+	}
+	return client, true
+}
+
+// requestedScopes returns the permissions requested with the scope
+// parameter, which must be among allowed unless allowed is nil. Without a
+// scope parameter, allowed is returned. It writes the error response and
+// returns false when a scope cannot be granted.
+func requestedScopes(w http.ResponseWriter, r *http.Request, allowed []string) ([]string, bool) {
+	scope := strings.Fields(r.PostForm.Get("scope"))
+	if len(scope) == 0 {
+		return allowed, true
+	}
+	for _, s := range scope {
+		if !entity.IsValidPermission(s) || (allowed != nil && len(entity.GrantedOnly([]string{s}, allowed)) == 0) {
+			writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidScope, "scope "+s+" cannot be granted")
+			return nil, false
+		}
+	}
+	return scope, true
+}
+
+func clientID(client *entity.OAuthClient) string {
+	if client == nil {
+		return ""
+	}
+	return client.ID.String()
+}
+
+func writeOAuthError(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	if status >= http.StatusInternalServerError {
+		// Server errors may disclose internals such as database messages.
+		slog.ErrorContext(r.Context(), "issuing oauth token failed", "error", description)
+		description = "an unexpected error occurred"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.OAuthError{Error: code, ErrorDescription: description})
+}
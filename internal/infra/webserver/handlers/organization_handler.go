@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+)
+
+// OrganizationHandler manages the organizations of the authenticated user
+// and their members. It signs users in, and mails them, through UserHandler.
+// Every change made to an organization is recorded in its audit log.
+type OrganizationHandler struct {
+	Users          *UserHandler
+	OrganizationDB database.OrganizationDBInterface
+	AuditLogDB     database.AuditLogDBInterface
+}
+
+func NewOrganizationHandler(users *UserHandler, organizationDB database.OrganizationDBInterface, auditLogDB database.AuditLogDBInterface) *OrganizationHandler {
+	return &OrganizationHandler{
+		Users:          users,
+		OrganizationDB: organizationDB,
+		AuditLogDB:     auditLogDB,
+	}
+}
+
+// CreateOrganization godoc
+// @Summary      Create an organization
+// @Description  Create an organization owned by the authenticated user. Switch to it to manage its products
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.CreateOrganizationInput  true  "organization request"
+// @Success      201   {object}  entity.Membership
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateOrganizationInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	userID, _ := currentUser(r)
+	id, err := service.ParseID(userID)
+	if err != nil {
+		problem.Respond(w, r, http.StatusUnauthorized, "invalid_token_subject", "invalid token subject")
+		return
+	}
+	org, err := entity.NewOrganization(input.Name)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	owner, err := entity.NewMembership(org.ID, id, entity.OrgRoleOwner)
+	if err == nil {
+		err = h.OrganizationDB.WithContext(r.Context()).Create(org, owner)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	h.audit(r, entity.NewAuditEvent(org.ID, id, entity.AuditOrganizationCreated, nil, map[string]string{"name": org.Name}))
+	owner.Organization = org
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(owner)
+}
+
+// GetOrganizations godoc
+// @Summary      List organizations
+// @Description  List the memberships of the authenticated user along with their organization
+// @Tags         organizations
+// @Produce      json
+// @Success      200   {array}   entity.Membership
+// @Failure      401   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID, _ := currentUser(r)
+	memberships, err := h.OrganizationDB.WithContext(r.Context()).FindMembershipsByUserID(userID)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(memberships)
+}
+
+// SwitchOrganization godoc
+// @Summary      Switch the active organization
+// @Description  Issue a user JWT and a refresh token whose active organization is the given one. Products are
+// @Description  only read and written in the active organization. Tokens of OAuth clients cannot switch
+// @Tags         organizations
+// @Produce      json
+// @Param        id  path  string  true  "organization ID" Format(uuid)
+// @Success      200   {object}  dto.GetJWTOutput
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/switch [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	// A fresh user token would grant more than the client's scopes.
+	if _, ok := claims["client_id"]; ok {
+		problem.Respond(w, r, http.StatusForbidden, "client_cannot_switch_organization", "tokens of OAuth clients cannot switch organizations")
+		return
+	}
+	user, ok := h.Users.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	// Organizations of others are reported as missing, so that their IDs
+	// cannot be probed.
+	membership, err := h.OrganizationDB.WithContext(r.Context()).FindMembership(chi.URLParam(r, "id"), user.ID.String())
+	if err != nil {
+		problem.Respond(w, r, http.StatusNotFound, "organization_not_found", "organization not found")
+		return
+	}
+	h.Users.writeTokens(w, r, user, service.NewID(), tokenGrant{OrganizationID: membership.OrganizationID})
+}
@@ -0,0 +1,580 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/diegopontes87/api/internal/infra/mail"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/go-chi/chi"
+)
+
+// CreateInvitation godoc
+// @Summary      Invite a user to an organization
+// @Description  Email an invitation to join the organization with a role. Its token can be accepted with an existing
+// @Description  account of that address or by creating one. Owners invite admins and members, admins only members
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        id       path   string                     true  "organization ID" Format(uuid)
+// @Param        request  body   dto.CreateInvitationInput  true  "invitation request"
+// @Success      201   {object}  entity.Invitation
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/invitations [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	var input dto.CreateInvitationInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	actor, ok := h.findCurrentMembership(w, r)
+	if !ok {
+		return
+	}
+	invitation, err := entity.NewInvitation(actor.OrganizationID, actor.UserID, input.Email, input.Role, opts.InvitationExpiresIn)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if !actor.CanManageMembers() || !actor.CanGrant(invitation.Role) {
+		problem.Error(w, r, http.StatusForbidden, entity.ErrCannotManageMember)
+		return
+	}
+	if user, err := h.Users.UserDB.WithContext(r.Context()).FindByEmail(invitation.Email); err == nil {
+		if _, err := h.OrganizationDB.WithContext(r.Context()).FindMembership(actor.OrganizationID.String(), user.ID.String()); err == nil {
+			problem.Error(w, r, http.StatusConflict, entity.ErrAlreadyAMember)
+			return
+		}
+	}
+	org, err := h.OrganizationDB.WithContext(r.Context()).FindByID(actor.OrganizationID.String())
+	if err == nil {
+		err = h.OrganizationDB.WithContext(r.Context()).CreateInvitation(invitation)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	h.audit(r, entity.NewAuditEvent(actor.OrganizationID, actor.UserID, entity.AuditMemberInvited, nil, map[string]string{
+		"invitation_id": invitation.ID.String(),
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+	}))
+	if err := h.sendInvitation(r, org, invitation); err != nil {
+		slog.ErrorContext(r.Context(), "sending invitation failed", "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// GetInvitations godoc
+// @Summary      List the invitations of an organization
+// @Description  List the invitations of the organization, including accepted, revoked and expired ones
+// @Tags         organizations
+// @Produce      json
+// @Param        id  path  string  true  "organization ID" Format(uuid)
+// @Success      200   {array}   entity.Invitation
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/invitations [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.findManagingMembership(w, r)
+	if !ok {
+		return
+	}
+	invitations, err := h.OrganizationDB.WithContext(r.Context()).FindInvitationsByOrganizationID(actor.OrganizationID.String())
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// RevokeInvitation godoc
+// @Summary      Revoke an invitation
+// @Description  Revoke a pending invitation of the organization. Its token is rejected from then on
+// @Tags         organizations
+// @Produce      json
+// @Param        id             path  string  true  "organization ID" Format(uuid)
+// @Param        invitationID   path  string  true  "invitation ID" Format(uuid)
+// @Success      204
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/invitations/{invitationID} [delete]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.findManagingMembership(w, r)
+	if !ok {
+		return
+	}
+	invitation, err := h.OrganizationDB.WithContext(r.Context()).FindInvitation(chi.URLParam(r, "invitationID"))
+	if err != nil || invitation.OrganizationID != actor.OrganizationID {
+		problem.Respond(w, r, http.StatusNotFound, "invitation_not_found", "invitation not found")
+		return
+	}
+	if invitation.IsPending(time.Now()) {
+		invitation.Revoke()
+		err = h.OrganizationDB.WithContext(r.Context()).UpdateInvitation(invitation)
+		if errors.Is(err, entity.ErrInvitationNotPending) {
+			problem.Error(w, r, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		h.audit(r, entity.NewAuditEvent(actor.OrganizationID, actor.UserID, entity.AuditInvitationRevoked, nil, map[string]string{
+			"invitation_id": invitation.ID.String(),
+			"email":         invitation.Email,
+		}))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation godoc
+// @Summary      Accept an invitation
+// @Description  Join an organization with an invitation token sent to the authenticated user's email address
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.InvitationTokenInput  true  "invitation token"
+// @Success      200   {object}  entity.Membership
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/invitations/accept [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var input dto.InvitationTokenInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	invitation, ok := h.findInvitation(w, r, input.Token)
+	if !ok {
+		return
+	}
+	user, ok := h.Users.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	membership, ok := h.acceptInvitation(w, r, invitation, user, false)
+	if !ok {
+		return
+	}
+	// Receiving the invitation proves the user owns the address.
+	if !user.IsEmailVerified() {
+		user.VerifyEmail()
+		if err := h.Users.UserDB.WithContext(r.Context()).Update(user); err != nil {
+			slog.ErrorContext(r.Context(), "verifying email of invited user failed", "error", err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(membership)
+}
+
+// RegisterWithInvitation godoc
+// @Summary      Sign up with an invitation
+// @Description  Create an account for the email address an invitation was sent to, join its organization and
+// @Description  get a user JWT and a refresh token whose active organization is that one
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.RegisterWithInvitationInput  true  "invitation token and account"
+// @Success      200   {object}  dto.GetJWTOutput
+// @Failure      400   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      422   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/invitations/register [post]
+func (h *OrganizationHandler) RegisterWithInvitation(w http.ResponseWriter, r *http.Request) {
+	var input dto.RegisterWithInvitationInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	invitation, ok := h.findInvitation(w, r, input.Token)
+	if !ok {
+		return
+	}
+	if err := input.Validate(h.Users.PasswordPolicy); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	user, err := entity.NewUser(input.Name, invitation.Email, input.Password)
+	if err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	user.VerifyEmail()
+	membership, ok := h.acceptInvitation(w, r, invitation, user, true)
+	if !ok {
+		return
+	}
+	h.Users.writeTokens(w, r, user, service.NewID(), tokenGrant{OrganizationID: membership.OrganizationID})
+}
+
+// GetMembers   godoc
+// @Summary      List the members of an organization
+// @Description  List the members of an organization the authenticated user is a member of, along with their user
+// @Tags         organizations
+// @Produce      json
+// @Param        id  path  string  true  "organization ID" Format(uuid)
+// @Success      200   {array}   entity.Membership
+// @Failure      401   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/members [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.findCurrentMembership(w, r)
+	if !ok {
+		return
+	}
+	members, err := h.OrganizationDB.WithContext(r.Context()).FindMembershipsByOrganizationID(actor.OrganizationID.String())
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
+// UpdateMember godoc
+// @Summary      Change the role of a member
+// @Description  Change the role of a member to admin or member. The owner manages admins and members, admins only
+// @Description  members. The owner role is given by transferring ownership
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        id       path   string                 true  "organization ID" Format(uuid)
+// @Param        userID   path   string                 true  "user ID of the member" Format(uuid)
+// @Param        request  body   dto.UpdateMemberInput  true  "role"
+// @Success      200   {object}  entity.Membership
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/members/{userID} [put]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	var input dto.UpdateMemberInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if input.Role == entity.OrgRoleOwner || !entity.IsValidOrgRole(input.Role) {
+		err := entity.ErrInvalidOrgRole
+		if input.Role == entity.OrgRoleOwner {
+			err = entity.ErrOwnerRole
+		}
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	actor, target, ok := h.findTargetMembership(w, r)
+	if !ok {
+		return
+	}
+	if !actor.CanManage(target) || !actor.CanGrant(input.Role) {
+		problem.Error(w, r, http.StatusForbidden, entity.ErrCannotManageMember)
+		return
+	}
+	previous := target.Role
+	target.Role = input.Role
+	if err := h.OrganizationDB.WithContext(r.Context()).UpdateMembership(target); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if previous != target.Role {
+		h.audit(r, entity.NewAuditEvent(actor.OrganizationID, actor.UserID, entity.AuditMemberRoleChanged, &target.UserID, map[string]string{
+			"from": previous,
+			"to":   target.Role,
+		}))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(target)
+}
+
+// RemoveMember godoc
+// @Summary      Remove a member
+// @Description  Remove a member from the organization, or leave it when the member is the authenticated user.
+// @Description  Removed members immediately lose access to the organization's products. The owner cannot leave
+// @Tags         organizations
+// @Produce      json
+// @Param        id      path  string  true  "organization ID" Format(uuid)
+// @Param        userID  path  string  true  "user ID of the member" Format(uuid)
+// @Success      204
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/members/{userID} [delete]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	actor, target, ok := h.findTargetMembership(w, r)
+	if !ok {
+		return
+	}
+	action := entity.AuditMemberRemoved
+	if target.ID == actor.ID {
+		action = entity.AuditMemberLeft
+		if actor.IsOwner() {
+			problem.Error(w, r, http.StatusConflict, entity.ErrOwnerCannotLeave)
+			return
+		}
+	} else if !actor.CanManage(target) {
+		problem.Error(w, r, http.StatusForbidden, entity.ErrCannotManageMember)
+		return
+	}
+	if err := h.OrganizationDB.WithContext(r.Context()).DeleteMembership(target); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	h.audit(r, entity.NewAuditEvent(actor.OrganizationID, actor.UserID, action, &target.UserID, map[string]string{"role": target.Role}))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TransferOwnership godoc
+// @Summary      Transfer the ownership of an organization
+// @Description  Make another member the owner of the organization. The current owner becomes an admin
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Param        id       path   string                      true  "organization ID" Format(uuid)
+// @Param        request  body   dto.TransferOwnershipInput  true  "new owner"
+// @Success      200   {array}   entity.Membership
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/transfer_ownership [post]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	var input dto.TransferOwnershipInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	actor, ok := h.findCurrentMembership(w, r)
+	if !ok {
+		return
+	}
+	if !actor.IsOwner() {
+		problem.Respond(w, r, http.StatusForbidden, "not_the_owner", "only the owner can transfer the ownership")
+		return
+	}
+	if input.UserID == actor.UserID.String() {
+		problem.Respond(w, r, http.StatusBadRequest, "already_the_owner", "the user already is the owner")
+		return
+	}
+	target, err := h.OrganizationDB.WithContext(r.Context()).FindMembership(actor.OrganizationID.String(), input.UserID)
+	if err != nil {
+		problem.Error(w, r, http.StatusNotFound, entity.ErrNotAMember)
+		return
+	}
+	if err = h.OrganizationDB.WithContext(r.Context()).TransferOwnership(actor, target); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	h.audit(r, entity.NewAuditEvent(actor.OrganizationID, actor.UserID, entity.AuditOwnershipTransferred, &target.UserID, nil))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode([]*entity.Membership{actor, target})
+}
+
+// GetAuditLog  godoc
+// @Summary      List the audit log of an organization
+// @Description  List the changes made to the organization and its members, newest first
+// @Tags         organizations
+// @Produce      json
+// @Param        id     path   string  true   "organization ID" Format(uuid)
+// @Param        page   query  string  false  "page number"
+// @Param        limit  query  string  false  "limit"
+// @Success      200   {array}   entity.AuditEvent
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /orgs/{id}/audit_log [get]
+// @Security ApiKeyAuth
+func (h *OrganizationHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.findManagingMembership(w, r)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	events, err := h.AuditLogDB.WithContext(r.Context()).FindAllByOrganizationID(actor.OrganizationID.String(), page, limit)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
+
+// findCurrentMembership loads the authenticated user's membership in the
+// organization of the URL. It writes a 404 and returns false when the user
+// is not a member, so that organizations of others cannot be probed.
+func (h *OrganizationHandler) findCurrentMembership(w http.ResponseWriter, r *http.Request) (*entity.Membership, bool) {
+	userID, _ := currentUser(r)
+	membership, err := h.OrganizationDB.WithContext(r.Context()).FindMembership(chi.URLParam(r, "id"), userID)
+	if err != nil {
+		problem.Respond(w, r, http.StatusNotFound, "organization_not_found", "organization not found")
+		return nil, false
+	}
+	return membership, true
+}
+
+// findManagingMembership is findCurrentMembership for the routes reserved
+// to the owner and admins. It writes a 403 to other members.
+func (h *OrganizationHandler) findManagingMembership(w http.ResponseWriter, r *http.Request) (*entity.Membership, bool) {
+	membership, ok := h.findCurrentMembership(w, r)
+	if ok && !membership.CanManageMembers() {
+		problem.Error(w, r, http.StatusForbidden, entity.ErrCannotManageMember)
+		return nil, false
+	}
+	return membership, ok
+}
+
+// findTargetMembership loads the authenticated user's membership and the
+// membership of the user of the URL, in the organization of the URL.
+func (h *OrganizationHandler) findTargetMembership(w http.ResponseWriter, r *http.Request) (*entity.Membership, *entity.Membership, bool) {
+	actor, ok := h.findCurrentMembership(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	target, err := h.OrganizationDB.WithContext(r.Context()).FindMembership(actor.OrganizationID.String(), chi.URLParam(r, "userID"))
+	if err != nil {
+		problem.Error(w, r, http.StatusNotFound, entity.ErrNotAMember)
+		return nil, nil, false
+	}
+	return actor, target, true
+}
+
+// findInvitation verifies an invitation token and loads its invitation. It
+// writes a 400 and returns false unless the invitation is pending.
+func (h *OrganizationHandler) findInvitation(w http.ResponseWriter, r *http.Request, token string) (*entity.Invitation, bool) {
+	keys := r.Context().Value("jwt").(*auth.KeySet)
+	jwtOptions := r.Context().Value("JwtOptions").(auth.TokenOptions)
+	id, err := auth.ParseInvitationToken(keys, jwtOptions, token, time.Now())
+	var invitation *entity.Invitation
+	if err == nil {
+		invitation, err = h.OrganizationDB.WithContext(r.Context()).FindInvitation(id)
+	}
+	if err != nil || !invitation.IsPending(time.Now()) {
+		problem.Error(w, r, http.StatusBadRequest, entity.ErrInvalidInvitation)
+		return nil, false
+	}
+	return invitation, true
+}
+
+// acceptInvitation makes user a member of the invitation's organization,
+// creating the user as well when register is set. It writes the error
+// response and returns false when that fails.
+func (h *OrganizationHandler) acceptInvitation(w http.ResponseWriter, r *http.Request, invitation *entity.Invitation, user *entity.User, register bool) (*entity.Membership, bool) {
+	membership, err := invitation.Accept(user, time.Now())
+	if err == nil && register {
+		err = h.OrganizationDB.WithContext(r.Context()).RegisterWithInvitation(user, invitation, membership)
+	} else if err == nil {
+		err = h.OrganizationDB.WithContext(r.Context()).AcceptInvitation(invitation, membership)
+	}
+	if errors.Is(err, entity.ErrEmailAlreadyExists) {
+		problem.Respond(w, r, http.StatusConflict, "email_already_registered", "email is already registered, sign in and accept the invitation instead")
+		return nil, false
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, entity.ErrInvalidInvitation):
+			status = http.StatusBadRequest
+		case errors.Is(err, entity.ErrInvitationEmailMismatch):
+			status = http.StatusForbidden
+		case errors.Is(err, entity.ErrAlreadyAMember), errors.Is(err, entity.ErrInvitationNotPending):
+			status = http.StatusConflict
+		}
+		problem.Error(w, r, status, err)
+		return nil, false
+	}
+	h.audit(r, entity.NewAuditEvent(invitation.OrganizationID, user.ID, entity.AuditInvitationAccepted, &user.ID, map[string]string{
+		"invitation_id": invitation.ID.String(),
+		"invited_by":    invitation.InvitedBy.String(),
+		"role":          membership.Role,
+	}))
+	return membership, true
+}
+
+func (h *OrganizationHandler) sendInvitation(r *http.Request, org *entity.Organization, invitation *entity.Invitation) error {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	keys := r.Context().Value("jwt").(*auth.KeySet)
+	jwtOptions := r.Context().Value("JwtOptions").(auth.TokenOptions)
+	token, err := auth.NewInvitationToken(keys, jwtOptions, invitation, time.Now())
+	if err != nil {
+		return err
+	}
+	body := "You were invited to join %[1]s as %[2]s.\n\n" +
+		"If you have an account, sign in and send this token to POST %[3]s/orgs/invitations/accept.\n" +
+		"Otherwise, send it along with your name and a password to POST %[3]s/orgs/invitations/register:\n\n%[4]s\n\n" +
+		"It expires in %[5]s."
+	return h.Users.Mailer.Send(r.Context(), mail.Message{
+		To:      invitation.Email,
+		Subject: "Join " + org.Name,
+		Body:    fmt.Sprintf(body, org.Name, invitation.Role, opts.BaseURL, token, opts.InvitationExpiresIn),
+	})
+}
+
+// audit records event. A change that was made is not failed because its
+// event could not be recorded, so failures are only logged.
+func (h *OrganizationHandler) audit(r *http.Request, event *entity.AuditEvent) {
+	if err := h.AuditLogDB.WithContext(r.Context()).Create(event); err != nil {
+		slog.ErrorContext(r.Context(), "recording audit event failed", "action", event.Action, "error", err)
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/diegopontes87/api/internal/dto"
 	"github.com/diegopontes87/api/internal/entity"
 	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
 	"github.com/diegopontes87/api/pkg/service"
 	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
 )
 
 type ProductHandler struct {
@@ -24,37 +26,48 @@ func NewProductHandler(db database.ProductDBInterface) *ProductHandler {
 
 // CreateProduct godoc
 // @Summary      Create a product
-// @Description  Create a product
+// @Description  Create a product in the active organization
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        request  body   dto.CreateProductInput  true  "product request"
 // @Success      201
 // @Failure      400     {object}  entity.Error
+// @Failure      403     {object}  entity.Error
+// @Failure      413     {object}  entity.Error
+// @Failure      415     {object}  entity.Error
+// @Failure      422     {object}  entity.Error
+// @Failure      429     {object}  entity.Error
 // @Failure      500     {object}  entity.Error
 // @Router       /products [post]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
+// @Security OAuth2Password[products:write]
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
-	var product *dto.CreateProductInput
-	err := json.NewDecoder(r.Body).Decode(&product)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	var product dto.CreateProductInput
+	if !decodeJSON(w, r, &product) {
+		return
+	}
+	if err := product.Validate(); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
 
 	p, err := entity.NewProduct(product.Name, product.Price)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	userID, _ := currentUser(r)
+	p.UserID, err = service.ParseID(userID)
+	if err != nil {
+		problem.Respond(w, r, http.StatusUnauthorized, "invalid_token_subject", "invalid token subject")
 		return
 	}
 
-	err = h.ProductDB.Create(p)
+	err = h.products(r).Create(p)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		problem.Error(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -69,20 +82,22 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 // @Param        id  path        string     true "product ID" Format(uuid)
 // @Success      200   			 {object}   entity.Product
 // @Failure      400     	     {object}  entity.Error
-// @Failure      404
+// @Failure      403     	     {object}  entity.Error
+// @Failure      404     {object}  entity.Error
+// @Failure      429     {object}  entity.Error
 // @Router       /products/{id}	 [get]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
+// @Security OAuth2Password[products:read]
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: "ID cant be nil"}
-		json.NewEncoder(w).Encode(err)
+		problem.Respond(w, r, http.StatusBadRequest, "id_required", "ID cant be nil")
 		return
 	}
-	product, err := h.ProductDB.FindByID(id)
+	product, err := h.products(r).FindByID(id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		problem.Respond(w, r, http.StatusNotFound, "product_not_found", "product not found")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -92,51 +107,61 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 
 // UpdateProduct godoc
 // @Summary      Update a product
-// @Description  Update a product
+// @Description  Update a product. Only the product owner or an admin can update it
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        id       path   string		             true  "product ID" Format(uuid)
 // @Param        request  body   dto.CreateProductInput  true  "product request"
 // @Success      200
-// @Failure      404
+// @Failure      404     {object}  entity.Error
 // @Failure      400     {object}  entity.Error
+// @Failure      403     {object}  entity.Error
+// @Failure      413     {object}  entity.Error
+// @Failure      415     {object}  entity.Error
+// @Failure      422     {object}  entity.Error
+// @Failure      429     {object}  entity.Error
 // @Failure      500     {object}  entity.Error
-// @Router       /products [put]
+// @Router       /products/{id} [put]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
+// @Security OAuth2Password[products:write]
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: "ID cant be nil"}
-		json.NewEncoder(w).Encode(err)
+		problem.Respond(w, r, http.StatusBadRequest, "id_required", "ID cant be nil")
 		return
 	}
-	var product entity.Product
-	err := json.NewDecoder(r.Body).Decode(&product)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+	var input dto.CreateProductInput
+	if !decodeJSON(w, r, &input) {
 		return
 	}
-	product.ID, err = service.ParseID(id)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+	if err := input.Validate(); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if _, err := service.ParseID(id); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
 		return
 	}
-	_, err = h.ProductDB.FindByID(id)
+	product, err := h.products(r).FindByID(id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		problem.Respond(w, r, http.StatusNotFound, "product_not_found", "product not found")
+		return
+	}
+	if !canModify(r, product) {
+		problem.Respond(w, r, http.StatusForbidden, "not_product_owner", "only the product owner or an admin can update this product")
+		return
+	}
+	product.Name = input.Name
+	product.Price = input.Price
+	if err = product.Validate(); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
-	err = h.ProductDB.Update(&product)
+	err = h.products(r).Update(product)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		problem.Error(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -145,34 +170,39 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 
 // DeleteProduct godoc
 // @Summary      Delete a product
-// @Description  Delete a product
+// @Description  Delete a product. Only the product owner or an admin can delete it
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        id       path     string		    true  "product ID" Format(uuid)
 // @Success      200
-// @Failure      404
+// @Failure      404     {object}  entity.Error
 // @Failure      400     {object}  entity.Error
+// @Failure      403     {object}  entity.Error
+// @Failure      429     {object}  entity.Error
 // @Failure      500     {object}  entity.Error
 // @Router       /products/{id}    [delete]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
+// @Security OAuth2Password[products:write]
 func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: "ID cant be nil"}
-		json.NewEncoder(w).Encode(err)
+		problem.Respond(w, r, http.StatusBadRequest, "id_required", "ID cant be nil")
+		return
 	}
-	_, err := h.ProductDB.FindByID(id)
+	product, err := h.products(r).FindByID(id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		problem.Respond(w, r, http.StatusNotFound, "product_not_found", "product not found")
+		return
+	}
+	if !canModify(r, product) {
+		problem.Respond(w, r, http.StatusForbidden, "not_product_owner", "only the product owner or an admin can delete this product")
 		return
 	}
-	err = h.ProductDB.Delete(id)
+	err = h.products(r).Delete(id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		problem.Error(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -180,20 +210,26 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 
 // GetProducts 	 godoc
 // @Summary      List products
-// @Description  get all products
+// @Description  get all products of the active organization
 // @Tags         products
 // @Accept       json
 // @Produce      json
 // @Param        request page 	 query	 	string false "page number"
 // @Param        request limit   query	 	string false "limit"
+// @Param        mine    query   bool   false "only products created by the authenticated user"
 // @Success      200   			 {array}    entity.Product
+// @Failure      403   			 {object}   entity.Error
+// @Failure      429   			 {object}   entity.Error
 // @Failure      500   			 {object}   entity.Error
 // @Router       /products 		 [get]
 // @Security ApiKeyAuth
+// @Security APIKeyHeader
+// @Security OAuth2Password[products:read]
 func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 	page := r.URL.Query().Get("page")
 	limit := r.URL.Query().Get("limit")
 	sort := r.URL.Query().Get("sort")
+	mine := r.URL.Query().Get("mine") == "true"
 
 	pageInt, err := strconv.Atoi(page)
 	if err != nil {
@@ -205,11 +241,15 @@ func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		limitInt = 0
 	}
 
-	products, err := h.ProductDB.FindAll(pageInt, limitInt, sort)
+	var products []entity.Product
+	if mine {
+		userID, _ := currentUser(r)
+		products, err = h.products(r).FindAllByUserID(userID, pageInt, limitInt, sort)
+	} else {
+		products, err = h.products(r).FindAll(pageInt, limitInt, sort)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		problem.Error(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -217,3 +257,30 @@ func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(&products)
 }
+
+// products returns the ProductDB of the request's active organization.
+func (h *ProductHandler) products(r *http.Request) database.ProductDBInterface {
+	return h.ProductDB.WithContext(r.Context()).ForTenant(currentOrganization(r))
+}
+
+// currentUser returns the subject and role claims of the request's JWT.
+func currentUser(r *http.Request) (string, string) {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	userID, _ := claims["sub"].(string)
+	role, _ := claims["role"].(string)
+	return userID, role
+}
+
+// currentOrganization returns the active organization of the request's JWT,
+// or a zero ID when it has none.
+func currentOrganization(r *http.Request) service.ID {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	org, _ := claims["org"].(string)
+	id, _ := service.ParseID(org)
+	return id
+}
+
+func canModify(r *http.Request, product *entity.Product) bool {
+	userID, role := currentUser(r)
+	return role == entity.RoleAdmin || product.IsOwnedBy(userID)
+}
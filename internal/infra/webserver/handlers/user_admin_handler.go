@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/go-chi/chi"
+)
+
+// GetUsers     godoc
+// @Summary      List users
+// @Description  List the users whose email or name contains q, ordered by email. The total number of matching
+// @Description  users is returned in the X-Total-Count header. Requires the users:manage permission
+// @Tags         users
+// @Produce      json
+// @Param        q       query  string  false  "part of the email or the name"
+// @Param        status  query  string  false  "active or disabled"
+// @Param        page    query  string  false  "page number, 1 by default"
+// @Param        limit   query  string  false  "users per page, 50 by default and 100 at most"
+// @Success      200   {array}   entity.User
+// @Header       200   {integer}  X-Total-Count  "number of matching users"
+// @Failure      400   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users [get]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	filter := database.UserFilter{Search: r.URL.Query().Get("q")}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case "active", "disabled":
+		disabled := status == "disabled"
+		filter.Disabled = &disabled
+	default:
+		problem.Respond(w, r, http.StatusBadRequest, "invalid_status_filter", "status must be active or disabled")
+		return
+	}
+	page, limit := userPage(r)
+	users, total, err := h.UserDB.WithContext(r.Context()).FindAll(filter, page, limit)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(users)
+}
+
+const (
+	defaultUsersPerPage = 50
+	maxUsersPerPage     = 100
+)
+
+// userPage returns the page of users requested, the first one and
+// defaultUsersPerPage users when unset, and never more than maxUsersPerPage
+// users.
+func userPage(r *http.Request) (page, limit int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = defaultUsersPerPage
+	}
+	return page, min(limit, maxUsersPerPage)
+}
+
+// GetUser      godoc
+// @Summary      Get a user
+// @Description  Get a user, how long its sign-ins are locked out for and its organizations.
+// @Description  Requires the users:manage permission
+// @Tags         users
+// @Produce      json
+// @Param        id    path      string  true  "user ID" Format(uuid)
+// @Success      200   {object}  dto.UserDetailsOutput
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/{id} [get]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return
+	}
+	memberships, err := h.OrganizationDB.WithContext(r.Context()).FindMembershipsByUserID(user.ID.String())
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	details := dto.UserDetailsOutput{
+		User: user,
+		// Without a client IP, only the lockout of the account is returned.
+		LoginLockedFor: retryAfterSeconds(h.LoginThrottle.RetryAfter(user.Email, "")),
+		Memberships:    memberships,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(details)
+}
+
+// DisableUser  godoc
+// @Summary      Disable a user
+// @Description  Disable a user, who can no longer sign in, and revoke every token issued to it. Its API keys and
+// @Description  OAuth clients stop working until it is enabled again. Requires the users:manage permission
+// @Tags         users
+// @Produce      json
+// @Param        id    path      string  true  "user ID" Format(uuid)
+// @Success      200   {object}  entity.User
+// @Failure      400   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/{id}/disable [post]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return
+	}
+	if userID, _ := currentUser(r); userID == user.ID.String() {
+		problem.Respond(w, r, http.StatusBadRequest, "cannot_disable_self", "you cannot disable your own account")
+		return
+	}
+	user.Disable()
+	err := h.UserDB.WithContext(r.Context()).Update(user)
+	if err == nil {
+		h.Revocations.DisableUser(user.ID)
+		err = h.revokeSessions(r, user)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// EnableUser   godoc
+// @Summary      Enable a user
+// @Description  Enable a disabled user, who can sign in again. Requires the users:manage permission
+// @Tags         users
+// @Produce      json
+// @Param        id    path      string  true  "user ID" Format(uuid)
+// @Success      200   {object}  entity.User
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/{id}/enable [post]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return
+	}
+	user.Enable()
+	if err := h.UserDB.WithContext(r.Context()).Update(user); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	h.Revocations.EnableUser(user.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// ForcePasswordReset godoc
+// @Summary      Force a password reset
+// @Description  Refuse the current password of a user, revoke every token, API key and OAuth client issued to it and
+// @Description  email it a password reset token. The user signs in again once it chose a new password.
+// @Description  Requires the users:manage permission
+// @Tags         users
+// @Produce      json
+// @Param        id    path      string  true  "user ID" Format(uuid)
+// @Success      200   {object}  entity.User
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/{id}/force_password_reset [post]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return
+	}
+	user.RequirePasswordReset()
+	err := h.UserDB.WithContext(r.Context()).Update(user)
+	if err == nil {
+		err = h.revokeAllTokens(r, user)
+	}
+	if err == nil {
+		// The credentials may have been used to register clients.
+		err = h.OAuthClientDB.WithContext(r.Context()).RevokeByUserID(user.ID.String())
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	body := "An administrator asked you to choose a new password for your account.\n\n" +
+		"Send this token to POST %[1]s/users/password_reset/confirm along with your new password:\n\n%[2]s\n\n" +
+		"It expires in %[3]s. You can request a new one at POST %[1]s/users/password_reset/request."
+	err = h.sendUserToken(r, user, entity.TokenPurposePasswordReset, opts.PasswordResetExpiresIn, "Reset your password", body)
+	if err != nil {
+		// The user can still request another token.
+		slog.ErrorContext(r.Context(), "sending forced password reset failed", "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// UnlockUser   godoc
+// @Summary      Unlock a user
+// @Description  Clear the failed sign-in attempts of a user, so it can sign in again right away.
+// @Description  The lockouts of client IPs are kept. Requires the users:manage permission
+// @Tags         users
+// @Produce      json
+// @Param        id    path      string  true  "user ID" Format(uuid)
+// @Success      204
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Router       /users/{id}/unlock [post]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findUser(w, r)
+	if !ok {
+		return
+	}
+	// The failures of the IPs the account was attacked from are kept.
+	h.LoginThrottle.Unlock(user.Email)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findUser loads the user of the URL. It writes a 404 and returns false when
+// there is none.
+func (h *UserHandler) findUser(w http.ResponseWriter, r *http.Request) (*entity.User, bool) {
+	user, err := h.UserDB.WithContext(r.Context()).FindByID(chi.URLParam(r, "id"))
+	if err != nil {
+		problem.Respond(w, r, http.StatusNotFound, "user_not_found", "user not found")
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/mail"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/service"
+)
+
+// AccountOptions configures the email verification, password reset,
+// two-factor authentication and organization invitation flows.
+type AccountOptions struct {
+	BaseURL                string
+	VerificationExpiresIn  time.Duration
+	PasswordResetExpiresIn time.Duration
+	// UnverifiedAccess is one of the entity.UnverifiedAccess values.
+	UnverifiedAccess string
+	// TwoFactorIssuer names the service in authenticator apps.
+	TwoFactorIssuer             string
+	TwoFactorChallengeExpiresIn time.Duration
+	InvitationExpiresIn         time.Duration
+}
+
+// RequestEmailVerification godoc
+// @Summary      Request an email verification
+// @Description  Send a new email verification token to the address, if it belongs to an unverified user.
+// @Description  The response is the same whether or not it does
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.EmailInput  true  "email address"
+// @Success      202
+// @Failure      400   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Router       /users/verify_email/request [post]
+func (h *UserHandler) RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var input dto.EmailInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	email, err := entity.NormalizeEmail(input.Email)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if user, err := h.UserDB.WithContext(r.Context()).FindByEmail(email); err == nil && !user.IsEmailVerified() {
+		if err := h.sendEmailVerification(r, user); err != nil {
+			slog.ErrorContext(r.Context(), "sending email verification failed", "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmEmailVerification godoc
+// @Summary      Verify an email address
+// @Description  Mark the email address of the user the token was sent to as verified
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.ConfirmEmailVerificationInput  true  "verification token"
+// @Success      204
+// @Failure      400   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/verify_email/confirm [post]
+func (h *UserHandler) ConfirmEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var input dto.ConfirmEmailVerificationInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	token, user, ok := h.findUserToken(w, r, entity.TokenPurposeEmailVerification, input.Token)
+	if !ok {
+		return
+	}
+	if !token.IsSentTo(user.Email) {
+		problem.Error(w, r, http.StatusBadRequest, entity.ErrInvalidUserToken)
+		return
+	}
+	if !h.useUserToken(w, r, token) {
+		return
+	}
+	user.VerifyEmail()
+	if err := h.UserDB.WithContext(r.Context()).Update(user); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordReset godoc
+// @Summary      Request a password reset
+// @Description  Send a password reset token to the address, if it belongs to a user.
+// @Description  The response is the same whether or not it does
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.EmailInput  true  "email address"
+// @Success      202
+// @Failure      400   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Router       /users/password_reset/request [post]
+func (h *UserHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	var input dto.EmailInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	email, err := entity.NormalizeEmail(input.Email)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if user, err := h.UserDB.WithContext(r.Context()).FindByEmail(email); err == nil {
+		body := "We received a request to reset the password of your account.\n\n" +
+			"To choose a new password, send this token to POST %[1]s/users/password_reset/confirm:\n\n%[2]s\n\n" +
+			"It expires in %[3]s. If you did not ask for it, you can ignore this message."
+		err := h.sendUserToken(r, user, entity.TokenPurposePasswordReset, opts.PasswordResetExpiresIn, "Reset your password", body)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "sending password reset failed", "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmPasswordReset godoc
+// @Summary      Reset a password
+// @Description  Set a new password for the user the token was sent to and revoke every token and API key issued before
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.ConfirmPasswordResetInput  true  "reset token and new password"
+// @Success      204
+// @Failure      400   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      422   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/password_reset/confirm [post]
+func (h *UserHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var input dto.ConfirmPasswordResetInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if err := input.Validate(h.PasswordPolicy); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	token, user, ok := h.findUserToken(w, r, entity.TokenPurposePasswordReset, input.Token)
+	if !ok {
+		return
+	}
+	if err := user.SetPassword(input.NewPassword); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// Receiving the token proves the user owns the address it was sent to.
+	if !user.IsEmailVerified() && token.IsSentTo(user.Email) {
+		user.VerifyEmail()
+	}
+	if !h.useUserToken(w, r, token) {
+		return
+	}
+	err := h.UserDB.WithContext(r.Context()).Update(user)
+	if err == nil {
+		err = h.revokeAllTokens(r, user)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) sendEmailVerification(r *http.Request, user *entity.User) error {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	body := "Hi %[5]s,\n\n" +
+		"To verify your email address, send this token to POST %[1]s/users/verify_email/confirm:\n\n%[2]s\n\n" +
+		"It expires in %[3]s."
+	return h.sendUserToken(r, user, entity.TokenPurposeEmailVerification, opts.VerificationExpiresIn, "Verify your email address", body)
+}
+
+// sendUserToken replaces the user's pending tokens for purpose with a new one
+// and emails it. body is a format string given the base URL, the token, its
+// lifetime, the user's email and name.
+func (h *UserHandler) sendUserToken(r *http.Request, user *entity.User, purpose string, expiresIn time.Duration, subject, body string) error {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	if err := h.UserTokenDB.WithContext(r.Context()).InvalidateByUserID(user.ID.String(), purpose); err != nil {
+		return err
+	}
+	token, plain, err := entity.NewUserToken(user.ID, purpose, expiresIn)
+	if err != nil {
+		return err
+	}
+	token.Email = user.Email
+	if err := h.UserTokenDB.WithContext(r.Context()).Create(token); err != nil {
+		return err
+	}
+	return h.Mailer.Send(r.Context(), mail.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf(body, opts.BaseURL, plain, expiresIn, user.Email, user.Name),
+	})
+}
+
+// findUserToken loads a usable token for purpose and the user it was sent to.
+// It writes a 400 and returns false when there is none.
+func (h *UserHandler) findUserToken(w http.ResponseWriter, r *http.Request, purpose, plain string) (*entity.UserToken, *entity.User, bool) {
+	token, err := h.UserTokenDB.WithContext(r.Context()).FindByHash(purpose, service.HashToken(plain))
+	var user *entity.User
+	if err == nil && token.IsUsable() {
+		user, err = h.UserDB.WithContext(r.Context()).FindByID(token.UserID.String())
+	}
+	if err != nil || !token.IsUsable() {
+		problem.Error(w, r, http.StatusBadRequest, entity.ErrInvalidUserToken)
+		return nil, nil, false
+	}
+	return token, user, true
+}
+
+// useUserToken marks a token found by findUserToken as used. It writes a 400
+// and returns false when a concurrent request used it first.
+func (h *UserHandler) useUserToken(w http.ResponseWriter, r *http.Request, token *entity.UserToken) bool {
+	used, err := h.UserTokenDB.WithContext(r.Context()).Use(token)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return false
+	}
+	if !used {
+		problem.Error(w, r, http.StatusBadRequest, entity.ErrInvalidUserToken)
+		return false
+	}
+	return true
+}
@@ -2,29 +2,60 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/diegopontes87/api/internal/dto"
 	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
 	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/internal/infra/mail"
+	"github.com/diegopontes87/api/internal/infra/telemetry"
+	"github.com/diegopontes87/api/internal/infra/webserver/middlewares"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
 )
 
 type UserHandler struct {
-	UserDB database.UserDBInterface
+	UserDB         database.UserDBInterface
+	RefreshTokenDB database.RefreshTokenDBInterface
+	Revocations    auth.TokenRevoker
+	PasswordPolicy *entity.PasswordPolicy
+	UserTokenDB    database.UserTokenDBInterface
+	Mailer         mail.Mailer
+	LoginThrottle  auth.LoginThrottler
+	OrganizationDB database.OrganizationDBInterface
+	APIKeyDB       database.APIKeyDBInterface
+	OAuthClientDB  database.OAuthClientDBInterface
+	Metrics        *telemetry.Metrics
 }
 
-func NewUserHandler(db database.UserDBInterface) *UserHandler {
+func NewUserHandler(db database.UserDBInterface, refreshTokenDB database.RefreshTokenDBInterface, revocations auth.TokenRevoker, passwordPolicy *entity.PasswordPolicy, userTokenDB database.UserTokenDBInterface, mailer mail.Mailer, loginThrottle auth.LoginThrottler, organizationDB database.OrganizationDBInterface, apiKeyDB database.APIKeyDBInterface, oauthClientDB database.OAuthClientDBInterface, metrics *telemetry.Metrics) *UserHandler {
 	return &UserHandler{
-		UserDB: db,
+		UserDB:         db,
+		RefreshTokenDB: refreshTokenDB,
+		Revocations:    revocations,
+		PasswordPolicy: passwordPolicy,
+		UserTokenDB:    userTokenDB,
+		Mailer:         mailer,
+		LoginThrottle:  loginThrottle,
+		OrganizationDB: organizationDB,
+		APIKeyDB:       apiKeyDB,
+		OAuthClientDB:  oauthClientDB,
+		Metrics:        metrics,
 	}
 }
 
 // CreateUser    godoc
 // @Summary      Create a user
-// @Description  Create a new user in the application with the provided data
+// @Description  Create a new user in the application with the provided data and send an email verification token
 // @Tags         users
 // @Accept       json
 // @Produce      json
@@ -32,78 +63,726 @@ func NewUserHandler(db database.UserDBInterface) *UserHandler {
 // @Success      201
 // @Failure      500   {object}  entity.Error
 // @Failure      400   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      422   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
 // @Router       /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user dto.CreateUserInput
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+	if !decodeJSON(w, r, &user) {
+		return
+	}
+
+	if err := user.Validate(h.PasswordPolicy); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
 
 	u, err := entity.NewUser(user.Name, user.Email, user.Password)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	err = h.UserDB.Create(u)
+	err = h.UserDB.WithContext(r.Context()).Create(u)
+	if errors.Is(err, entity.ErrEmailAlreadyExists) {
+		problem.Error(w, r, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		problem.Error(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	if err := h.sendEmailVerification(r, u); err != nil {
+		slog.ErrorContext(r.Context(), "sending email verification failed", "error", err)
+	}
 	w.WriteHeader(http.StatusCreated)
 }
 
 // GetJWT    	 godoc
 // @Summary      Get a user JWT
-// @Description  Get a user JWT
+// @Description  Get a user JWT and a refresh token. Signing in cancels a scheduled account deletion.
+// @Description  Users with two-factor authentication get a challenge token instead, to send to /users/2fa/verify
+// @Description  along with a code. Repeated failures lock the account and the client IP out for a growing amount of time.
+// @Description  Disabled users and users required to reset their password are refused
 // @Tags         users
 // @Accept       json
 // @Produce      json
 // @Param        request  body   dto.GetJWTInput  true  "user credentials"
 // @Success      200   {object}  dto.GetJWTOutput
-// @Failure      404   {object}  entity.Error
+// @Success      202   {object}  dto.TwoFactorChallengeOutput
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
 // @Failure      500   {object}  entity.Error
 // @Router       /users/generate_token [post]
 func (h *UserHandler) GetJWT(w http.ResponseWriter, r *http.Request) {
 	var userJWT dto.GetJWTInput
-	jwt := r.Context().Value("jwt").(*jwtauth.JWTAuth)
-	jwtExpiresIn := r.Context().Value("JwtExpiresIn").(int)
+	if !decodeJSON(w, r, &userJWT) {
+		return
+	}
+	user, wait, err := h.checkCredentials(r, userJWT.Email, userJWT.Password)
+	if wait > 0 {
+		writeTooManyAttempts(w, r, wait)
+		return
+	}
+	if err != nil {
+		status := http.StatusUnauthorized
+		if isAccessRefused(err) {
+			status = http.StatusForbidden
+		}
+		problem.Error(w, r, status, err)
+		return
+	}
+	if user.IsTwoFactorEnabled() {
+		h.writeTwoFactorChallenge(w, r, user)
+		return
+	}
+	h.signIn(w, r, user)
+}
+
+var errInvalidCredentials = errors.New("invalid email or password")
+
+// checkCredentials returns the user signing in with email and password, or
+// how long the client has to wait when it failed too many times already.
+func (h *UserHandler) checkCredentials(r *http.Request, email, password string) (*entity.User, time.Duration, error) {
+	if normalized, err := entity.NormalizeEmail(email); err == nil {
+		email = normalized
+	}
+	ip := middlewares.ClientIP(r)
+	// The attempt counts as failed until the password is checked.
+	if wait := h.LoginThrottle.Attempt(email, ip); wait > 0 {
+		h.Metrics.RecordLogin(telemetry.LoginLockedOut)
+		return nil, wait, nil
+	}
+	// Unknown emails and wrong passwords fail the same way and take the same
+	// time, so the response does not tell whether an account exists.
+	user, err := h.UserDB.WithContext(r.Context()).FindByEmail(email)
+	var valid bool
+	if err != nil {
+		valid = entity.ValidateUnknownUserPassword(password)
+	} else {
+		valid = user.ValidatePassword(password)
+	}
+	if !valid {
+		h.Metrics.RecordLogin(telemetry.LoginFailure)
+		return nil, 0, errInvalidCredentials
+	}
+	if user.PasswordRehashed() {
+		// The old hash still works, so failing to replace it does not fail
+		// the sign-in.
+		if err := h.UserDB.WithContext(r.Context()).Update(user); err != nil {
+			slog.ErrorContext(r.Context(), "saving rehashed password failed", "error", err)
+		}
+	}
+	h.LoginThrottle.Succeed(email, ip)
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	if err := user.CheckAccess(opts.UnverifiedAccess); err != nil {
+		h.Metrics.RecordLogin(telemetry.LoginRefused)
+		return nil, 0, err
+	}
+	h.Metrics.RecordLogin(telemetry.LoginSuccess)
+	return user, 0, nil
+}
 
-	err := json.NewDecoder(r.Body).Decode(&userJWT)
+// signIn issues the tokens of a user who proved their identity.
+func (h *UserHandler) signIn(w http.ResponseWriter, r *http.Request, user *entity.User) {
+	if err := h.keepAccount(r, user); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	h.writeTokens(w, r, user, service.NewID(), tokenGrant{})
+}
+
+// keepAccount cancels the scheduled deletion of a user signing in during the
+// grace period.
+func (h *UserHandler) keepAccount(r *http.Request, user *entity.User) error {
+	if !user.IsDeletionScheduled() {
+		return nil
+	}
+	user.CancelDeletion()
+	return h.UserDB.WithContext(r.Context()).Update(user)
+}
+
+// retryAfterSeconds rounds wait up to the seconds of a Retry-After header.
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+func writeTooManyAttempts(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+	problem.Respond(w, r, http.StatusTooManyRequests, "too_many_attempts", "too many failed sign-in attempts, try again later")
+}
+
+// RefreshToken godoc
+// @Summary      Refresh a user JWT
+// @Description  Exchange a refresh token for a new access token and a new refresh token.
+// @Description  Reusing a refresh token that was already exchanged revokes every token of its family.
+// @Description  Users who may no longer sign in, such as disabled ones, are refused with a 403
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.RefreshTokenInput  true  "refresh token"
+// @Success      200   {object}  dto.GetJWTOutput
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/refresh [post]
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var input dto.RefreshTokenInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	// Tokens of OAuth clients are exchanged at /oauth/token, where the
+	// client has to authenticate.
+	token, user, err := h.findRefreshToken(r, input.RefreshToken, "")
+	if err == nil {
+		err = h.rotateRefreshToken(r, token)
+	}
+	switch {
+	case err == nil:
+		h.writeTokens(w, r, user, token.FamilyID, tokenGrant{Scopes: token.Scopes, ClientID: token.ClientID, OrganizationID: token.OrganizationID})
+	case errors.Is(err, errInvalidRefreshToken):
+		problem.Respond(w, r, http.StatusUnauthorized, "invalid_refresh_token", err.Error())
+	case errors.Is(err, entity.ErrRefreshTokenRevoked) || errors.Is(err, entity.ErrRefreshTokenExpired):
+		problem.Error(w, r, http.StatusUnauthorized, err)
+	case isAccessRefused(err):
+		problem.Error(w, r, http.StatusForbidden, err)
+	default:
+		problem.Error(w, r, http.StatusInternalServerError, err)
+	}
+}
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// findRefreshToken returns the refresh token plain issued to clientID, empty
+// for the tokens issued to users directly, along with its user. It fails with
+// errInvalidRefreshToken for unknown tokens, with the error of
+// entity.User.CheckAccess when the user may no longer sign in, and with
+// entity.ErrRefreshTokenRevoked for the tokens that were already rotated or
+// revoked: someone else may hold a copy of those, so their whole family is
+// revoked.
+func (h *UserHandler) findRefreshToken(r *http.Request, plain, clientID string) (*entity.RefreshToken, *entity.User, error) {
+	token, err := h.RefreshTokenDB.WithContext(r.Context()).FindByHash(service.HashToken(plain))
+	if err != nil || token.ClientID != clientID {
+		return nil, nil, errInvalidRefreshToken
+	}
+	if token.IsRevoked() {
+		return nil, nil, h.refreshTokenReused(r, token)
+	}
+	if token.IsExpired() {
+		return nil, nil, entity.ErrRefreshTokenExpired
+	}
+	user, err := h.UserDB.WithContext(r.Context()).FindByID(token.UserID.String())
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		return nil, nil, errInvalidRefreshToken
 	}
-	user, err := h.UserDB.FindByEmail(userJWT.Email)
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	if err := user.CheckAccess(opts.UnverifiedAccess); err != nil {
+		return nil, nil, err
+	}
+	return token, user, nil
+}
+
+// rotateRefreshToken revokes a refresh token found by findRefreshToken, which
+// is exchanged for new tokens. Only one of the requests exchanging the same
+// token concurrently succeeds; the others are taken for a reuse.
+func (h *UserHandler) rotateRefreshToken(r *http.Request, token *entity.RefreshToken) error {
+	revoked, err := h.RefreshTokenDB.WithContext(r.Context()).Revoke(token)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		err := entity.Error{Message: err.Error()}
-		json.NewEncoder(w).Encode(err)
+		return err
+	}
+	if !revoked {
+		return h.refreshTokenReused(r, token)
+	}
+	return nil
+}
+
+// refreshTokenReused revokes the family of a refresh token that was reused
+// and returns entity.ErrRefreshTokenRevoked, or the error revoking it.
+func (h *UserHandler) refreshTokenReused(r *http.Request, token *entity.RefreshToken) error {
+	if err := h.RefreshTokenDB.WithContext(r.Context()).RevokeFamily(token.FamilyID.String()); err != nil {
+		return err
+	}
+	return entity.ErrRefreshTokenRevoked
+}
+
+// isAccessRefused reports whether err is one of entity.User.CheckAccess.
+func isAccessRefused(err error) bool {
+	return errors.Is(err, entity.ErrUserDisabled) || errors.Is(err, entity.ErrPasswordResetRequired) || errors.Is(err, entity.ErrEmailNotVerified)
+}
+
+// Logout       godoc
+// @Summary      Logout
+// @Description  Revoke the refresh token family the given refresh token belongs to
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.RefreshTokenInput  true  "refresh token"
+// @Success      204
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/logout [post]
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var input dto.RefreshTokenInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	token, err := h.RefreshTokenDB.WithContext(r.Context()).FindByHash(service.HashToken(input.RefreshToken))
+	if err != nil {
+		problem.Respond(w, r, http.StatusUnauthorized, "invalid_refresh_token", "invalid refresh token")
 		return
 	}
-	if !user.ValidatePassword(userJWT.Password) {
-		fmt.Printf("Password is not valid: %v", userJWT.Password)
-		w.WriteHeader(http.StatusUnauthorized)
+	if err := h.RefreshTokenDB.WithContext(r.Context()).RevokeFamily(token.FamilyID.String()); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	_, tokenString, _ := jwt.Encode(map[string]interface{}{
-		"sub": user.ID.String(),
-		"exp": time.Now().Add(time.Second * time.Duration(jwtExpiresIn)).Unix(),
-	})
+// GetMe        godoc
+// @Summary      Get the current user
+// @Description  Get the account of the authenticated user
+// @Tags         users
+// @Produce      json
+// @Success      200   {object}  entity.User
+// @Failure      401   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Router       /users/me [get]
+// @Security ApiKeyAuth
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
 
-	accessToken := dto.GetJWTOutput{AccessToken: tokenString}
+// UpdateMe     godoc
+// @Summary      Update the current user
+// @Description  Update the name or email of the authenticated user. Omitted fields are left unchanged.
+// @Description  A new email has to be verified again, with the token sent to it
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.UpdateUserInput  true  "profile changes"
+// @Success      200   {object}  entity.User
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      422   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me [patch]
+// @Security ApiKeyAuth
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var input dto.UpdateUserInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if err := input.Validate(); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	user, ok := h.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	if input.Name != nil {
+		user.Name = strings.TrimSpace(*input.Name)
+	}
+	previousEmail := user.Email
+	var err error
+	if input.Email != nil {
+		err = user.SetEmail(*input.Email)
+	}
+	if err == nil {
+		err = user.Validate()
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = h.UserDB.WithContext(r.Context()).Update(user)
+	if errors.Is(err, entity.ErrEmailAlreadyExists) {
+		problem.Error(w, r, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if user.Email != previousEmail {
+		h.emailChanged(r, user)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// emailChanged invalidates the tokens emailed to the previous address of
+// user and sends a verification token to the new one. The email is saved
+// already, so failures are only logged.
+func (h *UserHandler) emailChanged(r *http.Request, user *entity.User) {
+	for _, purpose := range []string{entity.TokenPurposeEmailVerification, entity.TokenPurposePasswordReset} {
+		if err := h.UserTokenDB.WithContext(r.Context()).InvalidateByUserID(user.ID.String(), purpose); err != nil {
+			slog.ErrorContext(r.Context(), "invalidating emailed tokens failed", "purpose", purpose, "error", err)
+		}
+	}
+	if err := h.sendEmailVerification(r, user); err != nil {
+		slog.ErrorContext(r.Context(), "sending email verification failed", "error", err)
+	}
+}
+
+// ChangePassword godoc
+// @Summary      Change the current user password
+// @Description  Change the password of the authenticated user and revoke every token and API key issued before.
+// @Description  Wrong current passwords are throttled like failed sign-ins
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.ChangePasswordInput  true  "current and new password"
+// @Success      204
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      422   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me/password [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var input dto.ChangePasswordInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	if err := input.Validate(h.PasswordPolicy); err != nil {
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	user, ok := h.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	// The current password is throttled like the one of a sign-in, so a
+	// stolen token does not allow guessing it.
+	ip := middlewares.ClientIP(r)
+	if wait := h.LoginThrottle.Attempt(user.Email, ip); wait > 0 {
+		writeTooManyAttempts(w, r, wait)
+		return
+	}
+	if !user.ValidatePassword(input.CurrentPassword) {
+		problem.Respond(w, r, http.StatusForbidden, "invalid_current_password", "current password is invalid")
+		return
+	}
+	h.LoginThrottle.Succeed(user.Email, ip)
+	err := user.SetPassword(input.NewPassword)
+	if err == nil {
+		err = h.UserDB.WithContext(r.Context()).Update(user)
+	}
+	if err == nil {
+		err = h.revokeAllTokens(r, user)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMe     godoc
+// @Summary      Delete the current user
+// @Description  Schedule the deletion of the authenticated user's account and revoke its tokens.
+// @Description  The account is removed after a grace period, unless the user signs in again before it ends.
+// @Description  The owner of an organization with other members has to transfer the ownership first
+// @Tags         users
+// @Produce      json
+// @Success      202   {object}  entity.User
+// @Failure      401   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/me [delete]
+// @Security ApiKeyAuth
+func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	gracePeriod := r.Context().Value("AccountDeletionGracePeriod").(int)
+	user, ok := h.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	owner, err := h.UserDB.WithContext(r.Context()).OwnsSharedOrganization(user.ID.String())
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if owner {
+		problem.Error(w, r, http.StatusConflict, entity.ErrOwnerCannotBeDeleted)
+		return
+	}
+	user.ScheduleDeletion(time.Second * time.Duration(gracePeriod))
+	err = h.UserDB.WithContext(r.Context()).Update(user)
+	if err == nil {
+		err = h.revokeSessions(r, user)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(user)
+}
+
+// findCurrentUser loads the user the request's JWT was issued to. It writes
+// a 401 and returns false when that user no longer exists.
+func (h *UserHandler) findCurrentUser(w http.ResponseWriter, r *http.Request) (*entity.User, bool) {
+	userID, _ := currentUser(r)
+	user, err := h.UserDB.WithContext(r.Context()).FindByID(userID)
+	if err != nil {
+		problem.Respond(w, r, http.StatusUnauthorized, "user_not_found", "user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// RevokeToken  godoc
+// @Summary      Revoke the current access token
+// @Description  Revoke the access token used to authenticate this request
+// @Tags         users
+// @Produce      json
+// @Success      204
+// @Failure      401   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/revoke_token [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	token, _, _ := jwtauth.FromContext(r.Context())
+	userID, err := service.ParseID(token.Subject())
+	if err != nil || token.JwtID() == "" {
+		problem.Respond(w, r, http.StatusUnauthorized, "token_not_revocable", "token cannot be revoked")
+		return
+	}
+	if err := h.Revocations.RevokeToken(token.JwtID(), userID, token.Expiration()); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeUserTokens godoc
+// @Summary      Revoke every token of a user
+// @Description  Revoke every access token, refresh token and API key issued to a user. Requires the users:manage permission
+// @Tags         users
+// @Produce      json
+// @Param        id    path      string  true  "user ID" Format(uuid)
+// @Success      204
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/{id}/revoke_tokens [post]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) RevokeUserTokens(w http.ResponseWriter, r *http.Request) {
+	user, err := h.UserDB.WithContext(r.Context()).FindByID(chi.URLParam(r, "id"))
+	if err != nil {
+		problem.Respond(w, r, http.StatusNotFound, "user_not_found", "user not found")
+		return
+	}
+	if err := h.revokeAllTokens(r, user); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllTokens revokes the sessions and the API keys of a user, whose
+// credentials may be compromised.
+func (h *UserHandler) revokeAllTokens(r *http.Request, user *entity.User) error {
+	if err := h.revokeSessions(r, user); err != nil {
+		return err
+	}
+	return h.APIKeyDB.WithContext(r.Context()).RevokeByUserID(user.ID.String())
+}
+
+// revokeSessions revokes the access and refresh tokens of a user. Its API
+// keys are left alone, for the disabled accounts and the accounts scheduled
+// for deletion, whose keys are refused until the account is restored.
+func (h *UserHandler) revokeSessions(r *http.Request, user *entity.User) error {
+	if err := h.Revocations.RevokeUser(user.ID); err != nil {
+		return err
+	}
+	return h.RefreshTokenDB.WithContext(r.Context()).RevokeByUserID(user.ID.String())
+}
+
+// tokenGrant restricts the tokens issued to a user.
+type tokenGrant struct {
+	// Scopes restricts the permissions of the tokens. Nil grants all of the
+	// user's permissions.
+	Scopes []string
+	// ClientID is the OAuth client the tokens are issued to, if any.
+	ClientID string
+	// OrganizationID is the requested active organization. Zero, or an
+	// organization the user is no longer a member of, falls back to the
+	// user's first organization.
+	OrganizationID service.ID
+}
+
+// writeTokens issues an access token for the user and a refresh token in the
+// given family, and writes both to the response.
+func (h *UserHandler) writeTokens(w http.ResponseWriter, r *http.Request, user *entity.User, familyID service.ID, grant tokenGrant) {
+	tokenString, _, err := h.issueAccessToken(r, user, grant)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	refreshTokenString, err := h.issueRefreshToken(r, user, familyID, grant)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	accessToken := dto.GetJWTOutput{AccessToken: tokenString, RefreshToken: refreshTokenString}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(accessToken)
 }
+
+// issueAccessToken signs an access token for user and returns it along with
+// the permissions it carries.
+func (h *UserHandler) issueAccessToken(r *http.Request, user *entity.User, grant tokenGrant) (string, []string, error) {
+	jwt := r.Context().Value("jwt").(*auth.KeySet)
+	jwtExpiresIn := r.Context().Value("JwtExpiresIn").(int)
+	jwtOptions := r.Context().Value("JwtOptions").(auth.TokenOptions)
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+
+	permissions := user.AccessPermissions(opts.UnverifiedAccess)
+	if grant.Scopes != nil {
+		permissions = entity.GrantedOnly(grant.Scopes, permissions)
+	}
+	claims := jwtOptions.NewClaims(user.ID.String(), time.Now(), time.Second*time.Duration(jwtExpiresIn))
+	claims["role"] = user.Role
+	claims["permissions"] = permissions
+	claims["email_verified"] = user.IsEmailVerified()
+	if grant.ClientID != "" {
+		claims["client_id"] = grant.ClientID
+	}
+	org, err := h.activeOrganization(r, user, grant.OrganizationID)
+	if err != nil {
+		return "", nil, err
+	}
+	claims["org"] = org.String()
+	_, tokenString, err := jwt.Encode(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, permissions, nil
+}
+
+// issueRefreshToken creates a refresh token in familyID, which keeps the
+// restrictions of grant when it is exchanged.
+func (h *UserHandler) issueRefreshToken(r *http.Request, user *entity.User, familyID service.ID, grant tokenGrant) (string, error) {
+	refreshTokenExpiresIn := r.Context().Value("RefreshTokenExpiresIn").(int)
+	refreshToken, refreshTokenString, err := entity.NewRefreshToken(user.ID, familyID, time.Second*time.Duration(refreshTokenExpiresIn))
+	if err != nil {
+		return "", err
+	}
+	refreshToken.Scopes = grant.Scopes
+	refreshToken.ClientID = grant.ClientID
+	refreshToken.OrganizationID = grant.OrganizationID
+	if err := h.RefreshTokenDB.WithContext(r.Context()).Create(refreshToken); err != nil {
+		return "", err
+	}
+	return refreshTokenString, nil
+}
+
+// activeOrganization returns the organization the tokens of user act in:
+// requested when the user is a member of it, or else the first organization
+// the user joined. Users who are not a member of any get a personal one.
+func (h *UserHandler) activeOrganization(r *http.Request, user *entity.User, requested service.ID) (service.ID, error) {
+	if requested != (service.ID{}) {
+		if _, err := h.OrganizationDB.WithContext(r.Context()).FindMembership(requested.String(), user.ID.String()); err == nil {
+			return requested, nil
+		}
+	}
+	memberships, err := h.OrganizationDB.WithContext(r.Context()).FindMembershipsByUserID(user.ID.String())
+	if err != nil {
+		return service.ID{}, err
+	}
+	if len(memberships) > 0 {
+		return memberships[0].OrganizationID, nil
+	}
+	org, err := entity.NewOrganization(entity.PersonalOrganizationName(user))
+	if err != nil {
+		return service.ID{}, err
+	}
+	owner, err := entity.NewMembership(org.ID, user.ID, entity.OrgRoleOwner)
+	if err == nil {
+		err = h.OrganizationDB.WithContext(r.Context()).Create(org, owner)
+	}
+	return org.ID, err
+}
+
+// UpdateUserRole godoc
+// @Summary      Assign a role to a user
+// @Description  Assign a role and extra permissions to a user. Requires the users:manage permission
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path   string                   true  "user ID" Format(uuid)
+// @Param        request  body   dto.UpdateUserRoleInput  true  "role request"
+// @Success      200   {object}  entity.User
+// @Failure      400   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      404   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/{id}/role [put]
+// @Security ApiKeyAuth
+// @Security OAuth2Password[users:manage]
+func (h *UserHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var input dto.UpdateUserRoleInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	user, err := h.UserDB.WithContext(r.Context()).FindByID(id)
+	if err != nil {
+		problem.Respond(w, r, http.StatusNotFound, "user_not_found", "user not found")
+		return
+	}
+	if err = user.SetRole(input.Role, input.Permissions); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err = h.UserDB.WithContext(r.Context()).Update(user); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/diegopontes87/api/internal/dto"
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/webserver/middlewares"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/totp"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const qrCodeSize = 256
+
+// EnrollTwoFactor godoc
+// @Summary      Enroll in two-factor authentication
+// @Description  Generate a new TOTP secret for the authenticated user, returned along with its otpauth:// URI
+// @Description  and a QR code of it. Two-factor authentication is enforced once enabled with a code of the secret
+// @Tags         users
+// @Produce      json
+// @Success      200   {object}  dto.TwoFactorEnrollmentOutput
+// @Failure      401   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/2fa/enroll [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	user, ok := h.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	if err := user.EnrollTwoFactor(); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, entity.ErrTwoFactorEnabled) {
+			status = http.StatusConflict
+		}
+		problem.Error(w, r, status, err)
+		return
+	}
+	uri := totp.URI(opts.TwoFactorIssuer, user.Email, user.TOTPSecret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err == nil {
+		err = h.UserDB.WithContext(r.Context()).Update(user)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.TwoFactorEnrollmentOutput{Secret: user.TOTPSecret, OTPAuthURI: uri, QRCode: png})
+}
+
+// EnableTwoFactor godoc
+// @Summary      Enable two-factor authentication
+// @Description  Enable two-factor authentication with a code of the enrolled secret. The response holds
+// @Description  single-use recovery codes, which are not shown again
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.TwoFactorCodeInput  true  "TOTP code"
+// @Success      200   {object}  dto.RecoveryCodesOutput
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/2fa/enable [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var input dto.TwoFactorCodeInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	user, ok := h.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	codes, err := user.EnableTwoFactor(input.Code, time.Now())
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, entity.ErrTwoFactorNotEnrolled):
+			status = http.StatusBadRequest
+		case errors.Is(err, entity.ErrTwoFactorEnabled):
+			status = http.StatusConflict
+		case errors.Is(err, entity.ErrInvalidTwoFactorCode):
+			status = http.StatusForbidden
+		}
+		problem.Error(w, r, status, err)
+		return
+	}
+	if err = h.UserDB.WithContext(r.Context()).Update(user); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.RecoveryCodesOutput{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary      Disable two-factor authentication
+// @Description  Disable two-factor authentication with a TOTP code or a recovery code
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.TwoFactorCodeInput  true  "TOTP or recovery code"
+// @Success      204
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      409   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/2fa/disable [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var input dto.TwoFactorCodeInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	user, ok := h.findCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.IsTwoFactorEnabled() {
+		problem.Error(w, r, http.StatusConflict, entity.ErrTwoFactorNotEnabled)
+		return
+	}
+	if !h.verifyTwoFactorCode(w, r, user, input.Code, http.StatusForbidden) {
+		return
+	}
+	user.DisableTwoFactor()
+	if err := h.UserDB.WithContext(r.Context()).Update(user); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyTwoFactor godoc
+// @Summary      Complete a two-factor sign-in
+// @Description  Exchange the challenge token returned by /users/generate_token and a TOTP code or a recovery code
+// @Description  for a user JWT and a refresh token
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request  body   dto.VerifyTwoFactorInput  true  "challenge token and code"
+// @Success      200   {object}  dto.GetJWTOutput
+// @Failure      400   {object}  entity.Error
+// @Failure      401   {object}  entity.Error
+// @Failure      403   {object}  entity.Error
+// @Failure      413   {object}  entity.Error
+// @Failure      415   {object}  entity.Error
+// @Failure      429   {object}  entity.Error
+// @Failure      500   {object}  entity.Error
+// @Router       /users/2fa/verify [post]
+func (h *UserHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var input dto.VerifyTwoFactorInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	token, user, ok := h.findUserToken(w, r, entity.TokenPurposeTwoFactorChallenge, input.ChallengeToken)
+	if !ok {
+		return
+	}
+	// The account may have been disabled since the challenge was issued.
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	if err := user.CheckAccess(opts.UnverifiedAccess); err != nil {
+		problem.Error(w, r, http.StatusForbidden, err)
+		return
+	}
+	if !h.verifyTwoFactorCode(w, r, user, input.Code, http.StatusUnauthorized) {
+		return
+	}
+	if !h.useUserToken(w, r, token) {
+		return
+	}
+	h.signIn(w, r, user)
+}
+
+// writeTwoFactorChallenge answers a sign-in of a user with two-factor
+// authentication with a challenge token to complete it.
+func (h *UserHandler) writeTwoFactorChallenge(w http.ResponseWriter, r *http.Request, user *entity.User) {
+	opts := r.Context().Value("AccountOptions").(AccountOptions)
+	token, plain, err := entity.NewUserToken(user.ID, entity.TokenPurposeTwoFactorChallenge, opts.TwoFactorChallengeExpiresIn)
+	if err == nil {
+		err = h.UserTokenDB.WithContext(r.Context()).Create(token)
+	}
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	challenge := dto.TwoFactorChallengeOutput{
+		ChallengeToken: plain,
+		ExpiresIn:      int(opts.TwoFactorChallengeExpiresIn.Seconds()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(challenge)
+}
+
+// verifyTwoFactorCode checks a code of the user and saves the code as used.
+// Failures count against the user's sign-in attempts. It writes the error
+// response, with failureStatus for a wrong code, and returns false when the
+// code is not accepted.
+func (h *UserHandler) verifyTwoFactorCode(w http.ResponseWriter, r *http.Request, user *entity.User, code string, failureStatus int) bool {
+	ip := middlewares.ClientIP(r)
+	if wait := h.LoginThrottle.Attempt(user.Email, ip); wait > 0 {
+		writeTooManyAttempts(w, r, wait)
+		return false
+	}
+	lastCounter, recoveryCodes := user.TOTPLastCounter, user.RecoveryCodes
+	if err := user.VerifyTwoFactor(code, time.Now()); err != nil {
+		problem.Error(w, r, failureStatus, err)
+		return false
+	}
+	used, err := h.UserDB.WithContext(r.Context()).UseTwoFactorCode(user, lastCounter, recoveryCodes)
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, err)
+		return false
+	}
+	if !used {
+		// A concurrent request used the same code first.
+		problem.Error(w, r, failureStatus, entity.ErrInvalidTwoFactorCode)
+		return false
+	}
+	h.LoginThrottle.Succeed(user.Email, ip)
+	return true
+}
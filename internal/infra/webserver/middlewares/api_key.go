@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKey authenticates the requests carrying an X-API-Key header instead of
+// an access token. It stores claims standing for the key in the context, the
+// same way Verifier does for tokens: the key's user as subject, the key's
+// scopes that the user still holds as permissions, and the key's organization
+// as active organization. It must be mounted after Verifier and Revocation and
+// before Authenticator, which rejects invalid keys with a 401.
+func APIKey(authenticator *auth.APIKeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plain := r.Header.Get(APIKeyHeader)
+			if plain == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key, user, err := authenticator.Authenticate(r.Context(), plain, ClientIP(r), time.Now())
+			var token jwt.Token
+			if err == nil {
+				token = jwt.New()
+				token.Set(jwt.SubjectKey, user.ID.String())
+				token.Set("role", user.Role)
+				token.Set("permissions", authenticator.Permissions(key, user))
+				token.Set("api_key_id", key.ID.String())
+				token.Set("org", key.OrganizationID.String())
+			}
+			ctx := jwtauth.NewContext(r.Context(), token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
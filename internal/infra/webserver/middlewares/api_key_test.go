@@ -0,0 +1,131 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAPIKey(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.APIKey{})
+	userDB, keyDB := database.NewUserDB(db), database.NewAPIKeyDB(db)
+	user, _ := entity.NewUser("Diego", "diego@gmail.com", "123456")
+	user.SetRole(entity.RoleViewer, nil)
+	assert.NoError(t, userDB.Create(user))
+
+	newKey := func(allowedIPs []string, expiresIn time.Duration) string {
+		key, plain, err := entity.NewAPIKey(user.ID, "ci", []string{entity.PermissionProductsRead, entity.PermissionProductsWrite}, allowedIPs, expiresIn)
+		assert.NoError(t, err)
+		assert.NoError(t, keyDB.Create(key))
+		return plain
+	}
+
+	keys := auth.NewHMACKeySet([]byte("secret"))
+	handler := Verifier(keys, auth.TokenOptions{})(APIKey(auth.NewAPIKeyAuthenticator(keyDB, userDB, entity.UnverifiedAccessFull))(Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, _ := jwtauth.FromContext(r.Context())
+		assert.Equal(t, user.ID.String(), claims["sub"])
+		// The viewer role does not hold products:write anymore.
+		assert.Equal(t, []string{entity.PermissionProductsRead}, claims["permissions"])
+		w.WriteHeader(http.StatusOK)
+	}))))
+
+	serve := func(key string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.RemoteAddr = "10.0.0.1:4321"
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body entity.Error
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Detail
+	}
+
+	code, _ := serve(newKey(nil, 0))
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = serve(newKey([]string{"10.0.0.0/24"}, 0))
+	assert.Equal(t, http.StatusOK, code)
+	code, message := serve(newKey([]string{"192.168.0.1"}, 0))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, auth.ErrAPIKeyIPNotAllowed.Error(), message)
+	code, message = serve(newKey(nil, time.Nanosecond))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, auth.ErrAPIKeyExpired.Error(), message)
+	code, message = serve(newKey(nil, 0) + "x")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, entity.ErrInvalidAPIKey.Error(), message)
+
+	plain := newKey(nil, 0)
+	prefix, _ := entity.ParseAPIKeyPrefix(plain)
+	key, _ := keyDB.FindByPrefix(prefix)
+	key.Revoke()
+	keyDB.Update(key)
+	code, _ = serve(plain)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestAPIKeyAccountStatus(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{}, &entity.APIKey{})
+	userDB, keyDB := database.NewUserDB(db), database.NewAPIKeyDB(db)
+	user, _ := entity.NewUser("Diego", "diego@gmail.com", "123456")
+	user.SetRole(entity.RoleEditor, nil)
+	assert.NoError(t, userDB.Create(user))
+	key, plain, err := entity.NewAPIKey(user.ID, "ci", []string{entity.PermissionProductsRead, entity.PermissionProductsWrite}, nil, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, keyDB.Create(key))
+
+	serve := func(unverifiedAccess string) (int, string, []string) {
+		var permissions []string
+		keys := auth.NewHMACKeySet([]byte("secret"))
+		handler := Verifier(keys, auth.TokenOptions{})(APIKey(auth.NewAPIKeyAuthenticator(keyDB, userDB, unverifiedAccess))(Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, _ := jwtauth.FromContext(r.Context())
+			permissions = claims["permissions"].([]string)
+			w.WriteHeader(http.StatusOK)
+		}))))
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.Header.Set(APIKeyHeader, plain)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body entity.Error
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Detail, permissions
+	}
+
+	// Keys of users who did not verify their email are restricted as their
+	// tokens are.
+	code, _, permissions := serve(entity.UnverifiedAccessReadOnly)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{entity.PermissionProductsRead}, permissions)
+	code, message, _ := serve(entity.UnverifiedAccessBlocked)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, entity.ErrEmailNotVerified.Error(), message)
+
+	user.VerifyEmail()
+	userDB.Update(user)
+	code, _, permissions = serve(entity.UnverifiedAccessBlocked)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, permissions, 2)
+
+	user.RequirePasswordReset()
+	userDB.Update(user)
+	code, message, _ = serve(entity.UnverifiedAccessFull)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, entity.ErrPasswordResetRequired.Error(), message)
+}
@@ -0,0 +1,212 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions are the cross-origin requests browsers may make to the API.
+type CORSOptions struct {
+	// AllowedOrigins are origins such as "https://app.example.com",
+	// patterns of their subdomains such as "https://*.example.com", or "*"
+	// for every origin. No origin disables CORS.
+	AllowedOrigins []string
+	AllowedMethods []string
+	// AllowedHeaders are the request headers browsers may send, or "*" for
+	// every header.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and authorization headers.
+	// It cannot be used with every origin.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight responses.
+	MaxAge time.Duration
+}
+
+var ErrCORSWildcardCredentials = errors.New("cors: credentials cannot be allowed for every origin")
+
+// originPattern matches an origin, or its subdomains when wildcard.
+type originPattern struct {
+	// prefix is the scheme of a wildcard pattern, and the origin otherwise.
+	prefix string
+	// suffix is the domain and the port of a wildcard pattern.
+	suffix   string
+	wildcard bool
+}
+
+func parseOriginPattern(s string) (originPattern, error) {
+	s = strings.ToLower(strings.TrimSuffix(s, "/"))
+	scheme, host, ok := strings.Cut(s, "://")
+	if !ok || scheme == "" || host == "" || strings.Contains(host, "/") {
+		return originPattern{}, fmt.Errorf("cors: invalid origin %q", s)
+	}
+	if !strings.Contains(host, "*") {
+		return originPattern{prefix: s}, nil
+	}
+	if !strings.HasPrefix(host, "*.") || strings.Count(host, "*") > 1 || len(host) == len("*.") {
+		return originPattern{}, fmt.Errorf("cors: invalid origin %q: only a leading *. is supported", s)
+	}
+	return originPattern{prefix: scheme + "://", suffix: host[1:], wildcard: true}, nil
+}
+
+// matches reports whether origin, in lower case, is the origin of the
+// pattern, or one of its subdomains at any depth.
+func (p originPattern) matches(origin string) bool {
+	if !p.wildcard {
+		return origin == p.prefix
+	}
+	if len(origin) <= len(p.prefix)+len(p.suffix) || !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+	for _, label := range strings.Split(origin[len(p.prefix):len(origin)-len(p.suffix)], ".") {
+		if label == "" || strings.Trim(label, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// cors is the CORSOptions of the CORS middleware, ready to match requests.
+type cors struct {
+	allowAll       bool
+	origins        []originPattern
+	methods        map[string]bool
+	allowedMethods string
+	anyHeader      bool
+	headers        map[string]bool
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// CORS answers the preflight requests of the allowed origins and adds the
+// CORS headers to their other requests. Preflights end here, without going
+// through the routes, so it must be used on the root router; requests from
+// other origins go on without CORS headers, for browsers to block.
+func CORS(opts CORSOptions) (func(http.Handler) http.Handler, error) {
+	c := &cors{
+		methods:        map[string]bool{},
+		headers:        map[string]bool{},
+		allowedMethods: strings.Join(opts.AllowedMethods, ", "),
+		exposedHeaders: strings.Join(opts.ExposedHeaders, ", "),
+		credentials:    opts.AllowCredentials,
+	}
+	for _, origin := range opts.AllowedOrigins {
+		if origin == "*" {
+			c.allowAll = true
+			continue
+		}
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+		c.origins = append(c.origins, pattern)
+	}
+	if c.allowAll && c.credentials {
+		return nil, ErrCORSWildcardCredentials
+	}
+	for _, method := range opts.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range opts.AllowedHeaders {
+		if header == "*" {
+			c.anyHeader = true
+		}
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+	return func(next http.Handler) http.Handler {
+		if !c.allowAll && len(c.origins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+			c.actual(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// allowOrigin sets the origin of r as allowed, when it is, and reports
+// whether it is.
+func (c *cors) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	if c.allowAll {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return true
+	}
+	for _, pattern := range c.origins {
+		if pattern.matches(strings.ToLower(origin)) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if c.credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	if !c.allowAll {
+		w.Header().Add("Vary", "Origin")
+	}
+	if c.allowOrigin(w, r) && c.exposedHeaders != "" {
+		w.Header().Set("Access-Control-Expose-Headers", c.exposedHeaders)
+	}
+}
+
+// preflight allows the method and the headers a request of r's origin is
+// about to use, when they all are allowed.
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	if !c.allowAll {
+		w.Header().Add("Vary", "Origin")
+	}
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	defer w.WriteHeader(http.StatusNoContent)
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	var requested []string
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			requested = append(requested, header)
+		}
+	}
+	// Without CORS headers, browsers do not send the request.
+	if !c.methods[strings.ToUpper(method)] || !c.allowsHeaders(requested) || !c.allowOrigin(w, r) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Methods", c.allowedMethods)
+	if len(requested) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if c.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", c.maxAge)
+	}
+}
+
+func (c *cors) allowsHeaders(headers []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, header := range headers {
+		if !c.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
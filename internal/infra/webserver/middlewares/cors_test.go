@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCORSRouter(t *testing.T, opts CORSOptions) http.Handler {
+	cors, err := CORS(opts)
+	assert.Nil(t, err)
+	r := chi.NewRouter()
+	r.Use(cors)
+	r.Post("/products", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := newCORSRouter(t, CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/products", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The router has no OPTIONS route, and answers preflights anyway.
+	rec := preflight("https://app.example.com", "POST", "authorization, content-type")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "authorization, content-type", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rec.Header().Values("Vary"))
+
+	for _, origin := range []string{"https://admin.example.org", "https://eu.admin.example.org"} {
+		rec = preflight(origin, "GET", "")
+		assert.Equal(t, origin, rec.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+
+	// Refused preflights carry no CORS headers, so browsers do not send the
+	// request.
+	for _, tt := range []struct{ origin, method, headers string }{
+		{"https://evil.com", "POST", ""},
+		{"http://app.example.com", "POST", ""},
+		{"https://app.example.com.evil.com", "POST", ""},
+		{"https://example.org", "POST", ""},
+		{"https://evil.com/.example.org", "POST", ""},
+		{"https://a..example.org", "POST", ""},
+		{"https://app.example.com", "DELETE", ""},
+		{"https://app.example.com", "POST", "X-Custom"},
+	} {
+		rec = preflight(tt.origin, tt.method, tt.headers)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), tt)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"), tt)
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	r := newCORSRouter(t, CORSOptions{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"POST"},
+		ExposedHeaders: []string{"Location", "X-Request-ID"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set("Origin", "https://anywhere.com")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Location, X-Request-ID", rec.Header().Get("Access-Control-Expose-Headers"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Empty(t, rec.Header().Get("Vary"))
+
+	// Other OPTIONS requests are routed.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/products", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestCORSOptionsErrors(t *testing.T) {
+	_, err := CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	assert.ErrorIs(t, err, ErrCORSWildcardCredentials)
+	for _, origin := range []string{"app.example.com", "https://", "https://app.example.com/path", "https://app.*.com", "https://*"} {
+		_, err = CORS(CORSOptions{AllowedOrigins: []string{origin}})
+		assert.NotNil(t, err, origin)
+	}
+}
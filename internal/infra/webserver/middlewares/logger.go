@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diegopontes87/api/internal/infra/logging"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Logger logs every request once it is served, along with its status, size
+// and duration. Server errors are logged as errors. At the debug level, the
+// headers of the request are logged when it starts, without their secrets.
+func Logger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := r.Context()
+			if logger.Enabled(ctx, slog.LevelDebug) {
+				logger.DebugContext(ctx, "request started",
+					"method", r.Method,
+					"path", r.URL.Path,
+					headerGroup(r.Header),
+				)
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			}
+			if r.URL.RawQuery != "" {
+				attrs = append(attrs, slog.String("query", logging.RedactQuery(r.URL.Query())))
+			}
+			attrs = append(attrs,
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+			logger.LogAttrs(ctx, level, "request completed", attrs...)
+		})
+	}
+}
+
+// headerGroup returns the headers as a group, whose sensitive values are
+// redacted by the logger.
+func headerGroup(header http.Header) slog.Attr {
+	attrs := make([]interface{}, 0, len(header))
+	for name, values := range header {
+		attrs = append(attrs, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+	}
+	return slog.Group("headers", attrs...)
+}
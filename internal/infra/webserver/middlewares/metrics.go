@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/diegopontes87/api/internal/infra/telemetry"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute labels the requests that match no route, so unknown paths
+// do not make a series each.
+const unmatchedRoute = "unmatched"
+
+// otherMethod labels the requests whose method is not a standard one, so
+// clients cannot make a series per made-up method.
+const otherMethod = "OTHER"
+
+// methodLabel returns the label of a request method.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return otherMethod
+}
+
+// Metrics measures the duration of the requests by the pattern of the route
+// they matched, their method and their status. Non-standard methods are
+// labelled OTHER. It must be used on the root
+// router, whose routing context is filled in once the request is served.
+func Metrics(m *telemetry.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight := m.HTTPRequestsInFlight.With()
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			m.HTTPRequestDuration.With(route, methodLabel(r.Method), strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+		})
+	}
+}
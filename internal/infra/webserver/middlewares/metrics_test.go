@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/infra/telemetry"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	m := telemetry.NewMetrics("test")
+	r := chi.NewRouter()
+	r.Use(Metrics(m))
+	r.Route("/products", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+	r.Get("/metrics", m.Registry.ServeHTTP)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for _, path := range []string{"/products/1", "/products/2", "/unknown/1"} {
+		res, err := http.Get(server.URL + path)
+		assert.Nil(t, err)
+		res.Body.Close()
+	}
+	req, _ := http.NewRequest("BREW", server.URL+"/products/1", nil)
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	res.Body.Close()
+	res, err = http.Get(server.URL + "/metrics")
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	// Both products share the series of their route pattern.
+	assert.Contains(t, string(body), `http_request_duration_seconds_count{route="/products/{id}",method="GET",status="404"} 2`)
+	assert.Contains(t, string(body), `http_request_duration_seconds_count{route="unmatched",method="GET",status="404"} 1`)
+	assert.NotContains(t, string(body), `/products/1`)
+	assert.Contains(t, string(body), `http_request_duration_seconds_count{route="unmatched",method="OTHER",status="405"} 1`)
+	assert.NotContains(t, string(body), `BREW`)
+	// The scrape itself is in flight.
+	assert.Contains(t, string(body), "http_requests_in_flight 1\n")
+	assert.Contains(t, string(body), `app_build_info{version="test",`)
+	assert.Contains(t, string(body), `auth_login_attempts_total{result="failure"} 0`)
+}
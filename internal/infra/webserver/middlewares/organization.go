@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth"
+)
+
+// RequireOrganization only lets requests through when the verified JWT
+// carries an active organization in its "org" claim and its subject is
+// still a member of it, so that tokens of removed members stop working
+// before they expire. It must be mounted after Verifier and Authenticator.
+func RequireOrganization(organizations database.OrganizationDBInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, _ := jwtauth.FromContext(r.Context())
+			org, _ := claims["org"].(string)
+			sub, _ := claims["sub"].(string)
+			if org == "" {
+				problem.Respond(w, r, http.StatusForbidden, "no_active_organization", "no active organization")
+				return
+			}
+			if _, err := organizations.WithContext(r.Context()).FindMembership(org, sub); err != nil {
+				problem.Error(w, r, http.StatusForbidden, entity.ErrNotAMember)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
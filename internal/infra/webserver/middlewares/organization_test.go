@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/database"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRequireOrganization(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Organization{}, &entity.Membership{})
+	orgDB := database.NewOrganizationDB(db)
+	member, stranger := service.NewID(), service.NewID()
+	org, _ := entity.NewOrganization("Acme")
+	owner, _ := entity.NewMembership(org.ID, member, entity.OrgRoleOwner)
+	assert.NoError(t, orgDB.Create(org, owner))
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := RequireOrganization(orgDB)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(claims map[string]interface{}) int {
+		token, _, err := tokenAuth.Encode(claims)
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req = req.WithContext(jwtauth.NewContext(req.Context(), token, nil))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(map[string]interface{}{"sub": member.String(), "org": org.ID.String()}))
+	assert.Equal(t, http.StatusForbidden, serve(map[string]interface{}{"sub": stranger.String(), "org": org.ID.String()}))
+	assert.Equal(t, http.StatusForbidden, serve(map[string]interface{}{"sub": member.String(), "org": service.NewID().String()}))
+	assert.Equal(t, http.StatusForbidden, serve(map[string]interface{}{"sub": member.String()}))
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth"
+)
+
+// RequirePermission only lets requests through when the verified JWT carries
+// the given permission in its "permissions" claim. It must be mounted after
+// Verifier and Authenticator.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, _ := jwtauth.FromContext(r.Context())
+			if !hasPermission(claims, permission) {
+				problem.Respond(w, r, http.StatusForbidden, "missing_permission", "missing permission "+permission)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hasPermission(claims map[string]interface{}, permission string) bool {
+	switch granted := claims["permissions"].(type) {
+	case []interface{}:
+		for _, p := range granted {
+			if p == permission {
+				return true
+			}
+		}
+	case []string:
+		for _, p := range granted {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
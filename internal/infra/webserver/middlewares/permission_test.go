@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	handler := RequirePermission("products:write")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(permissions []string) int {
+		token, _, err := tokenAuth.Encode(map[string]interface{}{"sub": "user", "permissions": permissions})
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		req = req.WithContext(jwtauth.NewContext(req.Context(), token, nil))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve([]string{"products:read", "products:write"}))
+	assert.Equal(t, http.StatusForbidden, serve([]string{"products:read"}))
+	assert.Equal(t, http.StatusForbidden, serve(nil))
+}
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/diegopontes87/api/pkg/ratelimit"
+	"github.com/go-chi/jwtauth"
+)
+
+// RateLimit limits the requests every client makes to the routes it is used
+// on to limit, with buckets of store named after group so that groups with
+// limits of their own do not share them. Responses tell clients their limit
+// in RateLimit-* headers, and refused requests how long to wait in a
+// Retry-After header. Clients are told apart by the API key or the subject
+// they authenticated with, when it is used after Authenticator, or else by
+// their IP. A zero limit lets every request through.
+func RateLimit(store ratelimit.Store, group string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.IsZero() {
+			return next
+		}
+		policy := fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := store.Take(r.Context(), group+":"+clientKey(r), limit, time.Now())
+			if err != nil {
+				// The API stays up when the store is down.
+				slog.ErrorContext(r.Context(), "rate limiting failed", "group", group, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("RateLimit-Policy", policy)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				problem.Respond(w, r, http.StatusTooManyRequests, "rate_limited", "too many requests, try again later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the client of r by its API key, by the subject of
+// its verified token, or else by its IP.
+func clientKey(r *http.Request) string {
+	token, claims, err := jwtauth.FromContext(r.Context())
+	if err == nil && token != nil {
+		if id, _ := claims["api_key_id"].(string); id != "" {
+			return "api_key:" + id
+		}
+		if token.Subject() != "" {
+			return "user:" + token.Subject()
+		}
+	}
+	return "ip:" + ClientIP(r)
+}
+
+// ceilSeconds rounds d up to whole seconds, as headers count them.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
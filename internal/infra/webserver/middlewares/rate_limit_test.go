@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/ratelimit"
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 2, Period: time.Minute}
+	handler := RateLimit(store, "api", limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(remoteAddr string, claims map[string]interface{}) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		req.RemoteAddr = remoteAddr
+		if claims != nil {
+			token := jwt.New()
+			for key, value := range claims {
+				token.Set(key, value)
+			}
+			req = req.WithContext(jwtauth.NewContext(req.Context(), token, nil))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("10.0.0.1:1234", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+	rec = serve("10.0.0.1:1234", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = serve("10.0.0.1:1234", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	var p entity.Error
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&p))
+	assert.Equal(t, "rate_limited", p.Code)
+
+	// Authenticated clients have buckets of their own, even from the same
+	// IP, and API keys have one apart from their user.
+	user := map[string]interface{}{"sub": "user-1"}
+	apiKey := map[string]interface{}{"sub": "user-1", "api_key_id": "key-1"}
+	assert.Equal(t, http.StatusOK, serve("10.0.0.1:1234", user).Code)
+	assert.Equal(t, http.StatusOK, serve("10.0.0.2:1234", user).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve("10.0.0.3:1234", user).Code)
+	assert.Equal(t, http.StatusOK, serve("10.0.0.1:1234", apiKey).Code)
+	assert.Equal(t, http.StatusOK, serve("10.0.0.4:1234", nil).Code)
+
+	// Groups do not share buckets.
+	auth := RateLimit(store, "auth", limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users/generate_token", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	auth.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRateLimitOff(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := RateLimit(ratelimit.NewMemoryStore(), "api", ratelimit.Limit{})(next)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const forwardedForHeader = "X-Forwarded-For"
+
+// RealIP replaces the remote address of the requests forwarded by one of
+// trustedProxies with the address of their client: the last address of
+// their X-Forwarded-For header that is not a trusted proxy. The header of
+// the other requests is ignored, since clients can write anything in it.
+// It must be used before anything reading the remote address.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(trustedProxies) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if client, ok := forwardedClient(r, trustedProxies); ok {
+				r.RemoteAddr = client.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the address of the client of r, without its port. It is
+// the one RealIP found when r comes from a trusted proxy.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedClient returns the client of r, when r comes from a trusted
+// proxy. Addresses are read from the right, since each proxy appends the
+// address it received the request from, until one is not trusted.
+func forwardedClient(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	peer, err := netip.ParseAddr(ClientIP(r))
+	peer = peer.Unmap()
+	if err != nil || !isTrusted(peer, trustedProxies) {
+		return netip.Addr{}, false
+	}
+	var forwarded []string
+	for _, value := range r.Header.Values(forwardedForHeader) {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+	client := peer
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !isTrusted(client, trustedProxies) {
+			break
+		}
+	}
+	return client, client != peer
+}
+
+func isTrusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
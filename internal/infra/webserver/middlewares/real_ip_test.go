@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+	var remote string
+	handler := RealIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = ClientIP(r)
+	}))
+	serve := func(remoteAddr string, forwardedFor ...string) string {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.RemoteAddr = remoteAddr
+		for _, value := range forwardedFor {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return remote
+	}
+
+	// The header of clients connecting directly is ignored.
+	assert.Equal(t, "203.0.113.7", serve("203.0.113.7:1234", "198.51.100.1"))
+	assert.Equal(t, "10.0.0.1", serve("10.0.0.1:1234"))
+	assert.Equal(t, "198.51.100.1", serve("10.0.0.1:1234", "198.51.100.1"))
+	// Addresses a client wrote before its own are ignored as well.
+	assert.Equal(t, "198.51.100.1", serve("10.0.0.1:1234", "192.0.2.66, 198.51.100.1, 10.0.0.2"))
+	assert.Equal(t, "198.51.100.1", serve("10.0.0.1:1234", "192.0.2.66", "198.51.100.1, 10.0.0.2"))
+	assert.Equal(t, "10.0.0.3", serve("10.0.0.1:1234", "10.0.0.3, 10.0.0.2"))
+	assert.Equal(t, "10.0.0.2", serve("10.0.0.1:1234", "garbage, 10.0.0.2"))
+	assert.Equal(t, "2001:db8::1", serve("[fd00::1]:1234", "2001:db8::1"))
+	assert.Equal(t, "198.51.100.1", serve("[::ffff:10.0.0.1]:1234", "198.51.100.1"))
+}
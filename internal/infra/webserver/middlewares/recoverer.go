@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+)
+
+// Recoverer works like middleware.Recoverer, logging the panics of the next
+// handlers along with their stack, but answers with a problem instead of an
+// empty 500.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// The response is aborted on purpose, which is not logged.
+				panic(rvr)
+			}
+			slog.ErrorContext(r.Context(), "panic recovered",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(rvr),
+				"stack", string(debug.Stack()),
+			)
+			if r.Header.Get("Connection") != "Upgrade" {
+				problem.Internal(w, r)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
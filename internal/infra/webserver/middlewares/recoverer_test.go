@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverer(t *testing.T) {
+	handler := middleware.RequestID(Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var product *entity.Product
+		w.Write([]byte(product.Name))
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var body entity.Error
+	json.NewDecoder(rec.Body).Decode(&body)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "internal_server_error", body.Code)
+	assert.NotEmpty(t, body.RequestID)
+	// The cause of the panic is not disclosed.
+	assert.NotContains(t, body.Detail, "nil pointer")
+}
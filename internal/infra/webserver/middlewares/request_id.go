@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/diegopontes87/api/internal/infra/logging"
+	"github.com/diegopontes87/api/pkg/service"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// maxRequestIDLength bounds the IDs propagated from clients.
+	maxRequestIDLength = 128
+)
+
+// RequestID propagates the X-Request-ID of the request, or generates one
+// when it is missing or invalid. The ID is echoed in the response, attached
+// to the log lines of the request and read by middleware.GetReqID.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = service.NewID().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+		ctx = logging.WithRequestID(ctx, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isValidRequestID reports whether id is short and made of letters, digits
+// and the punctuation of common ID formats only, so it cannot forge log
+// lines or headers.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
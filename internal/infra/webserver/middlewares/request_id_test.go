@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = middleware.GetReqID(r.Context())
+	}))
+	serve := func(id string) string {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+		return seen
+	}
+
+	assert.Equal(t, "b6f1c1e2-trace:42", serve("b6f1c1e2-trace:42"))
+
+	generated := serve("")
+	assert.Len(t, generated, 36)
+	assert.NotEqual(t, generated, serve(""))
+
+	for _, id := range []string{"id\nforged log line", "id with spaces", strings.Repeat("a", maxRequestIDLength+1)} {
+		replaced := serve(id)
+		assert.NotEqual(t, id, replaced)
+		assert.Len(t, replaced, 36)
+	}
+}
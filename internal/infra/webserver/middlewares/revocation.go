@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/go-chi/jwtauth"
+)
+
+var ErrTokenRevoked = errors.New("token is revoked")
+
+// Revocation marks verified tokens found in the revocation store, and the
+// tokens of disabled users, as invalid. It must be mounted after Verifier and
+// before Authenticator, which then rejects them with a 401.
+func Revocation(store auth.TokenRevoker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err == nil && token != nil {
+				switch {
+				case store.IsDisabled(token.Subject()):
+					err = entity.ErrUserDisabled
+				case store.IsRevoked(token.JwtID(), token.Subject(), token.IssuedAt()):
+					err = ErrTokenRevoked
+				}
+				if err != nil {
+					ctx := jwtauth.NewContext(r.Context(), token, err)
+					r = r.WithContext(ctx)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SecurityHeadersOptions are the security headers of responses. Zero fields
+// set nothing, so SecurityHeaders used on a route overrides only the headers
+// it sets of the SecurityHeaders of the root router.
+type SecurityHeadersOptions struct {
+	// HSTSMaxAge is how long browsers only reach the host over HTTPS, sent
+	// as Strict-Transport-Security.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	// FrameOptions is X-Frame-Options, such as "DENY" or "SAMEORIGIN".
+	FrameOptions          string
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+}
+
+// SecurityHeaders adds the headers of opts to responses, along with
+// X-Content-Type-Options: nosniff, so browsers do not guess their content
+// type.
+func SecurityHeaders(opts SecurityHeadersOptions) func(http.Handler) http.Handler {
+	headers := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         opts.FrameOptions,
+		"Content-Security-Policy": opts.ContentSecurityPolicy,
+		"Referrer-Policy":         opts.ReferrerPolicy,
+	}
+	if opts.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(int(opts.HSTSMaxAge.Seconds()))
+		if opts.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		headers["Strict-Transport-Security"] = hsts
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				if value != "" {
+					w.Header().Set(name, value)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
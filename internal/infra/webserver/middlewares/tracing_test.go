@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var handlerSpan trace.SpanContext
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(Tracing)
+	r.Route("/products", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			handlerSpan = trace.SpanContextFromContext(r.Context())
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	// The span continues the trace of the caller and is named after the
+	// route, which the handler runs in.
+	assert.Equal(t, "GET /products/{id}", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.True(t, spans[0].Parent().IsRemote())
+	assert.Equal(t, spans[0].SpanContext(), handlerSpan)
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPRoute("/products/{id}"))
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPResponseStatusCode(500))
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+
+	assert.Equal(t, "GET", spans[1].Name())
+	assert.False(t, spans[1].Parent().IsValid())
+	assert.Contains(t, spans[1].Attributes(), semconv.HTTPResponseStatusCode(404))
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/diegopontes87/api/internal/infra/logging"
+	"github.com/diegopontes87/api/internal/infra/webserver/problem"
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Verifier works like jwtauth.Verifier but verifies tokens against a key set,
+// so tokens signed by any of its keys are accepted, and validates their
+// registered claims with opts. The result is stored in the context the same
+// way, for Authenticator and jwtauth.FromContext.
+func Verifier(keys *auth.KeySet, opts auth.TokenOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := tracer.Start(r.Context(), "jwt.verify")
+			token, err := verifyRequest(keys, opts, r)
+			if err != nil && !errors.Is(err, jwtauth.ErrNoTokenFound) {
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+			ctx := jwtauth.NewContext(r.Context(), token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func verifyRequest(keys *auth.KeySet, opts auth.TokenOptions, r *http.Request) (jwt.Token, error) {
+	tokenString := jwtauth.TokenFromHeader(r)
+	if tokenString == "" {
+		tokenString = jwtauth.TokenFromCookie(r)
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+	token, err := keys.Decode(tokenString)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	if err := opts.Validate(token, time.Now()); err != nil {
+		return token, err
+	}
+	return token, nil
+}
+
+// Authenticator rejects the requests whose token was not verified by
+// Verifier with a 401 that explains why.
+func Authenticator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err == nil && token == nil {
+			err = jwtauth.ErrNoTokenFound
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"invalid_token\", error_description=%q", err.Error()))
+			if errors.Is(err, ErrTokenRevoked) {
+				problem.Respond(w, r, http.StatusUnauthorized, "token_revoked", err.Error())
+				return
+			}
+			problem.Error(w, r, http.StatusUnauthorized, err)
+			return
+		}
+		logging.SetUserID(r.Context(), token.Subject())
+		next.ServeHTTP(w, r)
+	})
+}
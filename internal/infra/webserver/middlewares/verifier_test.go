@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifier(t *testing.T) {
+	keys := auth.NewHMACKeySet([]byte("secret"))
+	opts := auth.TokenOptions{Issuer: "https://api.example.com", Audience: "products"}
+	handler := Verifier(keys, opts)(Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, _ := jwtauth.FromContext(r.Context())
+		assert.Equal(t, "user", claims["sub"])
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(token string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var body entity.Error
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Detail
+	}
+
+	now := time.Now()
+	_, valid, _ := keys.Encode(opts.NewClaims("user", now, time.Minute))
+	_, expired, _ := keys.Encode(opts.NewClaims("user", now.Add(-time.Hour), time.Minute))
+	_, forged, _ := auth.NewHMACKeySet([]byte("other")).Encode(opts.NewClaims("user", now, time.Minute))
+	otherService := auth.TokenOptions{Issuer: "https://api.example.com", Audience: "billing"}
+	_, otherAudience, _ := keys.Encode(otherService.NewClaims("user", now, time.Minute))
+
+	code, _ := serve(valid)
+	assert.Equal(t, http.StatusOK, code)
+	code, message := serve(expired)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, auth.ErrTokenExpired.Error(), message)
+	code, message = serve(forged)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, auth.ErrInvalidToken.Error(), message)
+	code, message = serve(otherAudience)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, auth.ErrInvalidAudience.Error(), message)
+	code, message = serve("")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, jwtauth.ErrNoTokenFound.Error(), message)
+
+	// Failures are problem details with a stable code.
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var body entity.Error
+	json.NewDecoder(rec.Body).Decode(&body)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "token_expired", body.Code)
+	assert.Equal(t, "urn:problem-type:token_expired", body.Type)
+	assert.Equal(t, "Unauthorized", body.Title)
+	assert.Equal(t, http.StatusUnauthorized, body.Status)
+	assert.Equal(t, "/products", body.Instance)
+}
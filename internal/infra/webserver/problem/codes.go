@@ -0,0 +1,91 @@
+package problem
+
+import (
+	"errors"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/internal/infra/auth"
+	"github.com/go-chi/jwtauth"
+)
+
+type knownError struct {
+	err  error
+	code string
+	// field is the field of the request the error is about, if any.
+	field string
+}
+
+// knownErrors gives the errors returned to clients a stable code. Codes
+// must not change once published, since clients match them.
+var knownErrors = []knownError{
+	{entity.ErrIDIsRequired, "id_required", "id"},
+	{entity.ErrInvalidId, "invalid_id", "id"},
+	{entity.ErrNameIsRequired, "name_required", "name"},
+	{entity.ErrPriceIsRequired, "price_required", "price"},
+	{entity.ErrInvalidPrice, "invalid_price", "price"},
+
+	{entity.ErrEmailIsRequired, "email_required", "email"},
+	{entity.ErrInvalidEmail, "invalid_email", "email"},
+	{entity.ErrPasswordIsRequired, "password_required", "password"},
+	{entity.ErrEmailAlreadyExists, "email_already_registered", "email"},
+	{entity.ErrUserDisabled, "user_disabled", ""},
+	{entity.ErrEmailNotVerified, "email_not_verified", ""},
+	{entity.ErrPasswordResetRequired, "password_reset_required", ""},
+	{entity.ErrPasswordTooShort, "password_too_short", "password"},
+	{entity.ErrPasswordTooLong, "password_too_long", "password"},
+	{entity.ErrPasswordBreached, "password_breached", "password"},
+	{entity.ErrInvalidRole, "invalid_role", "role"},
+	{entity.ErrInvalidPermission, "invalid_permission", "permissions"},
+	{entity.ErrInvalidUserToken, "invalid_user_token", "token"},
+
+	{entity.ErrTwoFactorNotEnrolled, "two_factor_not_enrolled", ""},
+	{entity.ErrTwoFactorEnabled, "two_factor_already_enabled", ""},
+	{entity.ErrTwoFactorNotEnabled, "two_factor_not_enabled", ""},
+	{entity.ErrInvalidTwoFactorCode, "invalid_two_factor_code", "code"},
+
+	{entity.ErrRefreshTokenExpired, "refresh_token_expired", "refresh_token"},
+	{entity.ErrRefreshTokenRevoked, "refresh_token_revoked", "refresh_token"},
+
+	{entity.ErrAPIKeyNameIsRequired, "api_key_name_required", "name"},
+	{entity.ErrScopeIsRequired, "scope_required", "scopes"},
+	{entity.ErrScopeNotGranted, "scope_not_granted", "scopes"},
+	{entity.ErrInvalidAllowedIP, "invalid_allowed_ip", "allowed_ips"},
+	{entity.ErrInvalidAPIKey, "invalid_api_key", ""},
+	{auth.ErrAPIKeyExpired, "api_key_expired", ""},
+	{auth.ErrAPIKeyIPNotAllowed, "api_key_ip_not_allowed", ""},
+
+	{entity.ErrOAuthClientNameIsRequired, "oauth_client_name_required", "name"},
+	{entity.ErrInvalidGrantType, "invalid_grant_type", "grant_types"},
+
+	{entity.ErrOrganizationNameIsRequired, "organization_name_required", "name"},
+	{entity.ErrInvalidOrgRole, "invalid_organization_role", "role"},
+	{entity.ErrOwnerRole, "owner_role_not_assignable", "role"},
+	{entity.ErrNotAMember, "not_a_member", ""},
+	{entity.ErrAlreadyAMember, "already_a_member", ""},
+	{entity.ErrOwnerCannotLeave, "owner_cannot_leave", ""},
+	{entity.ErrOwnerCannotBeDeleted, "owner_cannot_be_deleted", ""},
+	{entity.ErrCannotManageMember, "cannot_manage_member", ""},
+	{entity.ErrInvalidInvitation, "invalid_invitation", "token"},
+	{entity.ErrInvitationNotPending, "invitation_not_pending", ""},
+	{entity.ErrInvitationEmailMismatch, "invitation_email_mismatch", ""},
+
+	{jwtauth.ErrNoTokenFound, "missing_token", ""},
+	{auth.ErrInvalidToken, "invalid_token", ""},
+	{auth.ErrTokenExpired, "token_expired", ""},
+	{auth.ErrTokenNoExpiration, "invalid_token", ""},
+	{auth.ErrTokenNotYetValid, "token_not_yet_valid", ""},
+	{auth.ErrTokenIssuedInFuture, "invalid_token", ""},
+	{auth.ErrInvalidIssuer, "invalid_token_issuer", ""},
+	{auth.ErrInvalidAudience, "invalid_token_audience", ""},
+	{auth.ErrInvalidTokenType, "invalid_token_type", ""},
+}
+
+// lookup returns the code and the field of a known error, or empty strings.
+func lookup(err error) (code, field string) {
+	for _, known := range knownErrors {
+		if errors.Is(err, known.err) {
+			return known.code, known.field
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,129 @@
+// Package problem writes the error responses of the API as problem details
+// of RFC 7807.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/validation"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ContentType = "application/problem+json"
+	typePrefix  = "urn:problem-type:"
+	// internalDetail replaces the detail of server errors, which may
+	// disclose internals such as database messages.
+	internalDetail = "an unexpected error occurred"
+)
+
+// New returns the problem of the request r failing with status. An empty
+// code is replaced by the generic code of status.
+func New(r *http.Request, status int, code, detail string) *entity.Error {
+	if code == "" {
+		code = StatusCode(status)
+	}
+	var traceID string
+	if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+		traceID = span.TraceID().String()
+	}
+	return &entity.Error{
+		Type:      typePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+		TraceID:   traceID,
+	}
+}
+
+// Write writes p as the response.
+func Write(w http.ResponseWriter, p *entity.Error) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Respond writes a problem with status, code and detail.
+func Respond(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, New(r, status, code, detail))
+}
+
+// Error writes err as a problem with status. Registered errors carry their
+// code, and the field of the request they are about, while others carry the
+// generic code of status. validation.Errors list every invalid field. The
+// detail of server errors is logged instead of being shown to the client.
+func Error(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "status", status, "error", err)
+		Respond(w, r, status, "", internalDetail)
+		return
+	}
+	var violations validation.Errors
+	if errors.As(err, &violations) {
+		Write(w, Invalid(r, status, violations))
+		return
+	}
+	code, field := lookup(err)
+	p := New(r, status, code, err.Error())
+	if field != "" {
+		p.Errors = []entity.FieldError{{Field: field, Message: err.Error()}}
+	}
+	Write(w, p)
+}
+
+// Invalid returns the problem of a request whose fields break the rules of
+// violations, each listed in Errors.
+func Invalid(r *http.Request, status int, violations validation.Errors) *entity.Error {
+	p := New(r, status, "validation_failed", "the request has invalid fields")
+	for _, violation := range violations {
+		p.Errors = append(p.Errors, entity.FieldError{
+			Field:   violation.Field,
+			Rule:    violation.Rule,
+			Message: violation.Message(),
+		})
+	}
+	return p
+}
+
+// InvalidField writes err as a 400 about field. It is used where the field
+// an error is registered with is named differently in the request.
+func InvalidField(w http.ResponseWriter, r *http.Request, field string, err error) {
+	code, _ := lookup(err)
+	p := New(r, http.StatusBadRequest, code, err.Error())
+	p.Errors = []entity.FieldError{{Field: field, Message: err.Error()}}
+	Write(w, p)
+}
+
+// Internal writes a 500 whose cause was already logged.
+func Internal(w http.ResponseWriter, r *http.Request) {
+	Respond(w, r, http.StatusInternalServerError, "", internalDetail)
+}
+
+// NotFound answers the requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Respond(w, r, http.StatusNotFound, "", "no route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed answers the requests whose path matches a route that
+// does not accept their method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Respond(w, r, http.StatusMethodNotAllowed, "", r.Method+" is not allowed on "+r.URL.Path)
+}
+
+// StatusCode returns the generic code of status, such as "not_found".
+func StatusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(strings.ToLower(text))
+}
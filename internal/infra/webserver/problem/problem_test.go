@@ -0,0 +1,98 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diegopontes87/api/internal/entity"
+	"github.com/diegopontes87/api/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) entity.Error {
+	var p entity.Error
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&p))
+	return p
+}
+
+func TestErrorKnown(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	rec := httptest.NewRecorder()
+	Error(rec, req, http.StatusConflict, fmt.Errorf("creating user: %w", entity.ErrEmailAlreadyExists))
+
+	p := decode(t, rec)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "email_already_registered", p.Code)
+	assert.Equal(t, "urn:problem-type:email_already_registered", p.Type)
+	assert.Equal(t, "Conflict", p.Title)
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, "/users", p.Instance)
+	assert.Equal(t, []entity.FieldError{{Field: "email", Message: p.Detail}}, p.Errors)
+}
+
+func TestErrorUnknown(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	rec := httptest.NewRecorder()
+	Error(rec, req, http.StatusBadRequest, errors.New("bad input"))
+
+	p := decode(t, rec)
+	assert.Equal(t, "bad_request", p.Code)
+	assert.Equal(t, "bad input", p.Detail)
+	assert.Empty(t, p.Errors)
+}
+
+func TestErrorHidesServerErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	rec := httptest.NewRecorder()
+	Error(rec, req, http.StatusInternalServerError, errors.New("no such table: products"))
+
+	p := decode(t, rec)
+	assert.Equal(t, "internal_server_error", p.Code)
+	assert.Equal(t, internalDetail, p.Detail)
+}
+
+func TestStatusCode(t *testing.T) {
+	assert.Equal(t, "not_found", StatusCode(http.StatusNotFound))
+	assert.Equal(t, "method_not_allowed", StatusCode(http.StatusMethodNotAllowed))
+	assert.Equal(t, "im_a_teapot", StatusCode(http.StatusTeapot))
+	assert.Equal(t, "error", StatusCode(599))
+}
+
+func TestErrorValidation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	rec := httptest.NewRecorder()
+	err := validation.Errors{
+		{Field: "name", Rule: validation.RuleRequired, Err: entity.ErrNameIsRequired},
+		{Field: "price", Rule: validation.RuleMax, Err: entity.ErrPriceTooHigh},
+	}
+	Error(rec, req, http.StatusUnprocessableEntity, err)
+
+	p := decode(t, rec)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "validation_failed", p.Code)
+	assert.Equal(t, []entity.FieldError{
+		{Field: "name", Rule: "required", Message: "name is required"},
+		{Field: "price", Rule: "max", Message: entity.ErrPriceTooHigh.Error()},
+	}, p.Errors)
+}
+
+func TestNewCarriesTraceID(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil).WithContext(ctx)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", New(req, http.StatusNotFound, "", "").TraceID)
+
+	req = httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	assert.Empty(t, New(req, http.StatusNotFound, "", "").TraceID)
+}
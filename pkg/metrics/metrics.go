@@ -0,0 +1,387 @@
+// Package metrics implements the counters, gauges and histograms of an
+// application, and serves them in the text exposition format of Prometheus.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Types of metric families.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefBuckets are the upper bounds of the buckets of request durations, in
+// seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Label is the name and the value of a label of a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value of a family. Suffix is appended to the name of the
+// family, such as "_bucket" for the buckets of histograms.
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is every sample of a metric.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector returns the families of its metrics when they are scraped.
+type Collector interface {
+	Collect() []Family
+}
+
+// CollectorFunc turns a function into a Collector, for metrics read from
+// another source when they are scraped.
+type CollectorFunc func() []Family
+
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+// Registry is the set of collectors served together.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// MustRegister adds collectors to the registry.
+func (r *Registry) MustRegister(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Gather returns the families of every collector, sorted by name.
+func (r *Registry) Gather() []Family {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	var families []Family
+	for _, collector := range collectors {
+		families = append(families, collector.Collect()...)
+	}
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
+
+// ServeHTTP writes every family in the text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	WriteText(w, r.Gather())
+}
+
+// WriteText writes families in the text exposition format.
+func WriteText(w io.Writer, families []Family) error {
+	var b strings.Builder
+	for _, family := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			b.WriteString(family.Name)
+			b.WriteString(sample.Suffix)
+			writeLabels(&b, sample.Labels)
+			b.WriteByte(' ')
+			b.WriteString(formatValue(sample.Value))
+			b.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeLabels(b *strings.Builder, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(b, "%s=\"%s\"", label.Name, escapeLabelValue(label.Value))
+	}
+	b.WriteByte('}')
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// vec holds the children of a metric, one per combination of label values.
+type vec[T any] struct {
+	name       string
+	help       string
+	labelNames []string
+	newChild   func() *T
+
+	mu       sync.Mutex
+	children map[string]*T
+	labels   map[string][]Label
+	keys     []string
+}
+
+func newVec[T any](name, help string, labelNames []string, newChild func() *T) vec[T] {
+	return vec[T]{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		newChild:   newChild,
+		children:   map[string]*T{},
+		labels:     map[string][]Label{},
+	}
+}
+
+// with returns the child of the label values, creating it on first use. It
+// panics when the number of values does not match the label names.
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	child, ok := v.children[key]
+	if !ok {
+		child = v.newChild()
+		labels := make([]Label, len(values))
+		for i, value := range values {
+			labels[i] = Label{Name: v.labelNames[i], Value: value}
+		}
+		v.children[key] = child
+		v.labels[key] = labels
+		v.keys = append(v.keys, key)
+		sort.Strings(v.keys)
+	}
+	return child
+}
+
+// each calls f with the children in the order of their label values.
+func (v *vec[T]) each(f func(labels []Label, child *T)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, key := range v.keys {
+		f(v.labels[key], v.children[key])
+	}
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds delta, which must not be negative, to the counter.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	vec[Counter]
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, labelNames, func() *Counter { return &Counter{} })}
+}
+
+// With returns the counter of the label values, in the order of the names.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.with(values)
+}
+
+func (c *CounterVec) Collect() []Family {
+	family := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	c.each(func(labels []Label, counter *Counter) {
+		family.Samples = append(family.Samples, Sample{Labels: labels, Value: counter.get()})
+	})
+	return []Family{family}
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) get() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct {
+	vec[Gauge]
+}
+
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, labelNames, func() *Gauge { return &Gauge{} })}
+}
+
+// With returns the gauge of the label values, in the order of the names.
+func (g *GaugeVec) With(values ...string) *Gauge {
+	return g.with(values)
+}
+
+func (g *GaugeVec) Collect() []Family {
+	family := Family{Name: g.name, Help: g.help, Type: TypeGauge}
+	g.each(func(labels []Label, gauge *Gauge) {
+		family.Samples = append(family.Samples, Sample{Labels: labels, Value: gauge.get()})
+	})
+	return []Family{family}
+}
+
+// Histogram counts observations in buckets of increasing upper bounds.
+type Histogram struct {
+	upperBounds []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe adds value to the bucket of the smallest upper bound not below it.
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.upperBounds, value)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	vec[Histogram]
+	upperBounds []float64
+}
+
+// NewHistogramVec returns a histogram of the buckets of the given upper
+// bounds, which are sorted. The +Inf bucket is implicit.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	upperBounds := append([]float64(nil), buckets...)
+	sort.Float64s(upperBounds)
+	return &HistogramVec{
+		vec: newVec(name, help, labelNames, func() *Histogram {
+			return &Histogram{upperBounds: upperBounds, counts: make([]uint64, len(upperBounds))}
+		}),
+		upperBounds: upperBounds,
+	}
+}
+
+// With returns the histogram of the label values, in the order of the names.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values)
+}
+
+func (h *HistogramVec) Collect() []Family {
+	family := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	h.each(func(labels []Label, histogram *Histogram) {
+		histogram.mu.Lock()
+		defer histogram.mu.Unlock()
+		var cumulative uint64
+		for i, upperBound := range h.upperBounds {
+			cumulative += histogram.counts[i]
+			family.Samples = append(family.Samples, Sample{
+				Suffix: "_bucket",
+				Labels: withLabel(labels, "le", formatValue(upperBound)),
+				Value:  float64(cumulative),
+			})
+		}
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_bucket", Labels: withLabel(labels, "le", "+Inf"), Value: float64(histogram.count)},
+			Sample{Suffix: "_sum", Labels: labels, Value: histogram.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(histogram.count)},
+		)
+	})
+	return []Family{family}
+}
+
+func withLabel(labels []Label, name, value string) []Label {
+	return append(append(make([]Label, 0, len(labels)+1), labels...), Label{Name: name, Value: value})
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryServesTextFormat(t *testing.T) {
+	requests := NewCounterVec("http_requests_total", "Number of HTTP requests.", "method", "route")
+	requests.With("GET", "/products").Inc()
+	requests.With("GET", "/products").Add(2)
+	requests.With("POST", `/say "hi"`).Inc()
+
+	inUse := NewGaugeVec("db_connections", "Number of connections\nby state.", "state")
+	inUse.With("idle").Set(3)
+	inUse.With("in_use").Inc()
+	inUse.With("in_use").Dec()
+
+	durations := NewHistogramVec("http_request_duration_seconds", "Duration of HTTP requests.", []float64{0.5, 0.1}, "route")
+	durations.With("/products").Observe(0.05)
+	durations.With("/products").Observe(0.1)
+	durations.With("/products").Observe(2)
+
+	buildInfo := CollectorFunc(func() []Family {
+		return []Family{{Name: "app_build_info", Help: "Build of the application.", Type: TypeGauge,
+			Samples: []Sample{{Labels: []Label{{Name: "version", Value: "1.0.0"}}, Value: 1}}}}
+	})
+
+	registry := NewRegistry()
+	registry.MustRegister(requests, inUse, durations, buildInfo)
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, ContentType, res.Header.Get("Content-Type"))
+	assert.Equal(t, `# HELP app_build_info Build of the application.
+# TYPE app_build_info gauge
+app_build_info{version="1.0.0"} 1
+# HELP db_connections Number of connections\nby state.
+# TYPE db_connections gauge
+db_connections{state="idle"} 3
+db_connections{state="in_use"} 0
+# HELP http_request_duration_seconds Duration of HTTP requests.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/products",le="0.1"} 2
+http_request_duration_seconds_bucket{route="/products",le="0.5"} 2
+http_request_duration_seconds_bucket{route="/products",le="+Inf"} 3
+http_request_duration_seconds_sum{route="/products"} 2.15
+http_request_duration_seconds_count{route="/products"} 3
+# HELP http_requests_total Number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/products"} 3
+http_requests_total{method="POST",route="/say \"hi\""} 1
+`, string(body))
+}
+
+func TestVecPanicsOnWrongLabelCount(t *testing.T) {
+	requests := NewCounterVec("http_requests_total", "Number of HTTP requests.", "method")
+	assert.Panics(t, func() { requests.With("GET", "/products") })
+	assert.Panics(t, func() { requests.With("GET").Add(-1) })
+}
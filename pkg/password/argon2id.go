@@ -0,0 +1,110 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var b64 = base64.RawStdEncoding
+
+// Upper bounds of the argon2id parameters, above which hashing a password
+// would take unreasonable memory or time.
+const (
+	MaxArgon2idMemory      = 4 * 1024 * 1024
+	MaxArgon2idIterations  = 64
+	MaxArgon2idParallelism = 64
+)
+
+// Argon2id hashes passwords with argon2id into PHC strings such as
+// "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>".
+type Argon2id struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2id returns the minimum parameters OWASP recommends.
+func DefaultArgon2id() Argon2id {
+	return Argon2id{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+// Validate checks the cost parameters are within bounds: at least 8 KiB of
+// memory per lane, as argon2 requires, at least an iteration and a lane, and
+// no more than the Max values.
+func (a Argon2id) Validate() error {
+	if a.Iterations == 0 || a.Iterations > MaxArgon2idIterations {
+		return fmt.Errorf("argon2id iterations must be between 1 and %d", MaxArgon2idIterations)
+	}
+	if a.Parallelism == 0 || a.Parallelism > MaxArgon2idParallelism {
+		return fmt.Errorf("argon2id parallelism must be between 1 and %d", MaxArgon2idParallelism)
+	}
+	if a.Memory < 8*uint32(a.Parallelism) || a.Memory > MaxArgon2idMemory {
+		return fmt.Errorf("argon2id memory must be between %d and %d KiB", 8*uint32(a.Parallelism), MaxArgon2idMemory)
+	}
+	return nil
+}
+
+func (a Argon2id) Identifies(id string) bool {
+	return id == "argon2id"
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Verify(encoded, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a Argon2id) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	return err != nil || params != a
+}
+
+// decodeArgon2id returns the parameters, the salt and the key of a hash. Hashes
+// whose parameters are out of bounds are invalid, as verifying them would
+// panic or exhaust the server.
+func decodeArgon2id(encoded string) (Argon2id, []byte, []byte, error) {
+	var params Argon2id
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil || params.Validate() != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,72 @@
+// Package password hashes passwords into self-describing strings, so that
+// the algorithm and parameters a hash was made with are read from the hash
+// itself. Argon2id hashes use the PHC string format and bcrypt hashes their
+// usual modular crypt format.
+package password
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnknownScheme = errors.New("unknown password hash scheme")
+	ErrInvalidHash   = errors.New("invalid password hash")
+)
+
+// Scheme hashes passwords with one algorithm and a set of parameters.
+type Scheme interface {
+	// Identifies reports whether id, the identifier between the first two
+	// "$" of a hash, names the scheme's algorithm.
+	Identifies(id string) bool
+	Hash(password string) (string, error)
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether encoded was made with other parameters
+	// than the scheme's.
+	NeedsRehash(encoded string) bool
+}
+
+// Hasher hashes passwords with its preferred scheme and verifies hashes made
+// by any of its schemes.
+type Hasher struct {
+	preferred Scheme
+	schemes   []Scheme
+}
+
+// NewHasher returns a Hasher hashing with preferred. Hashes of the other
+// schemes can still be verified, and need to be rehashed.
+func NewHasher(preferred Scheme, others ...Scheme) *Hasher {
+	return &Hasher{preferred: preferred, schemes: append([]Scheme{preferred}, others...)}
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify checks password against encoded. When it matches, rehash reports
+// whether encoded was made by another scheme or with outdated parameters
+// and should be replaced by a new hash.
+func (h *Hasher) Verify(encoded, password string) (ok, rehash bool, err error) {
+	scheme, err := h.scheme(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	ok, err = scheme.Verify(encoded, password)
+	if err != nil || !ok {
+		return false, false, err
+	}
+	return true, scheme != h.preferred || scheme.NeedsRehash(encoded), nil
+}
+
+func (h *Hasher) scheme(encoded string) (Scheme, error) {
+	parts := strings.SplitN(encoded, "$", 3)
+	if len(parts) < 3 || parts[0] != "" {
+		return nil, ErrInvalidHash
+	}
+	for _, s := range h.schemes {
+		if s.Identifies(parts[1]) {
+			return s, nil
+		}
+	}
+	return nil, ErrUnknownScheme
+}
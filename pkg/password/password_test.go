@@ -0,0 +1,107 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	hash, err := testArgon2id.Hash("my-s3cret-pass")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+
+	ok, err := testArgon2id.Verify(hash, "my-s3cret-pass")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = testArgon2id.Verify(hash, "other")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.False(t, testArgon2id.NeedsRehash(hash))
+	stronger := testArgon2id
+	stronger.Iterations = 2
+	assert.True(t, stronger.NeedsRehash(hash))
+
+	_, err = testArgon2id.Verify("$argon2id$v=19$m=64$salt$key", "my-s3cret-pass")
+	assert.Equal(t, ErrInvalidHash, err)
+}
+
+func TestArgon2idRejectsOutOfBoundsParameters(t *testing.T) {
+	for _, params := range []string{
+		"m=64,t=0,p=1",
+		"m=64,t=1,p=0",
+		"m=0,t=1,p=1",
+		"m=64,t=1,p=9",
+		"m=64,t=65,p=1",
+		"m=64,t=1,p=65",
+		"m=4194305,t=1,p=1",
+		"m=64,t=4294967296,p=1",
+		"m=64,t=1,p=256",
+	} {
+		hash := "$argon2id$v=19$" + params + "$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+		ok, err := testArgon2id.Verify(hash, "password")
+		assert.Equal(t, ErrInvalidHash, err, params)
+		assert.False(t, ok, params)
+		assert.True(t, testArgon2id.NeedsRehash(hash), params)
+	}
+}
+
+func TestArgon2idValidate(t *testing.T) {
+	assert.NoError(t, DefaultArgon2id().Validate())
+	assert.NoError(t, testArgon2id.Validate())
+	assert.Error(t, Argon2id{Memory: 64, Iterations: 0, Parallelism: 1}.Validate())
+	assert.Error(t, Argon2id{Memory: 64, Iterations: 1, Parallelism: 0}.Validate())
+	assert.Error(t, Argon2id{Memory: MaxArgon2idMemory + 1, Iterations: 1, Parallelism: 1}.Validate())
+}
+
+// A hash made by the reference implementation.
+func TestArgon2idReferenceHash(t *testing.T) {
+	hash := "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	ok, err := Argon2id{}.Verify(hash, "password")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestHasher(t *testing.T) {
+	hasher := NewHasher(testArgon2id, Bcrypt{Cost: bcrypt.MinCost})
+	hash, err := hasher.Hash("my-s3cret-pass")
+	assert.NoError(t, err)
+	ok, rehash, err := hasher.Verify(hash, "my-s3cret-pass")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	// Hashes of other schemes are verified and have to be rehashed.
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("my-s3cret-pass"), bcrypt.MinCost)
+	ok, rehash, err = hasher.Verify(string(legacy), "my-s3cret-pass")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+	ok, rehash, err = hasher.Verify(string(legacy), "other")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+
+	// So are hashes of the preferred scheme made with other parameters.
+	weaker, _ := Argon2id{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}.Hash("my-s3cret-pass")
+	ok, rehash, _ = hasher.Verify(weaker, "my-s3cret-pass")
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	_, _, err = hasher.Verify("$scrypt$ln=16,r=8,p=1$salt$key", "my-s3cret-pass")
+	assert.Equal(t, ErrUnknownScheme, err)
+	_, _, err = hasher.Verify("plain", "my-s3cret-pass")
+	assert.Equal(t, ErrInvalidHash, err)
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	hash, err := Bcrypt{Cost: bcrypt.MinCost}.Hash("my-s3cret-pass")
+	assert.NoError(t, err)
+	assert.False(t, Bcrypt{Cost: bcrypt.MinCost}.NeedsRehash(hash))
+	assert.True(t, Bcrypt{Cost: bcrypt.MinCost + 1}.NeedsRehash(hash))
+}
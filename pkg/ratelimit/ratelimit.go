@@ -0,0 +1,148 @@
+// Package ratelimit limits the rate of events per key with token buckets:
+// every key has a bucket of Limit.Requests tokens, refilled at the rate of
+// Limit.Requests per Limit.Period, and every event takes a token.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is the number of requests allowed per period, which can all be made
+// at once when the bucket is full. A zero Limit allows every request.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses a limit written as requests/period, such as "100/1m".
+// An empty string or "off" is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" {
+		return Limit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want requests/period", s)
+	}
+	var limit Limit
+	var err error
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+	return limit, nil
+}
+
+func (l Limit) IsZero() bool {
+	return l.Requests == 0
+}
+
+// interval is the time to refill one token.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Result is the state of a bucket after taking a token from it.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// RetryAfter is how long until a token is available, when the request
+	// was not allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the buckets of the keys. Take must be atomic per key, so a
+// store shared by several instances of the API enforces limits across them.
+type Store interface {
+	// Take takes a token at now from the bucket of key, refilled per limit.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is a token bucket stored as the time it is full again, which is
+// in the past when it is full.
+type bucket struct {
+	fullAt time.Time
+}
+
+// take takes a token from b at now. The tokens of b are the time left until
+// it is full, counted in intervals.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	interval := limit.interval()
+	fullAt := b.fullAt
+	if fullAt.Before(now) {
+		fullAt = now
+	}
+	// Taking a token pushes the time the bucket is full by an interval,
+	// which is only allowed when it stays within the capacity.
+	next := fullAt.Add(interval)
+	capacity := interval * time.Duration(limit.Requests)
+	if over := next.Sub(now) - capacity; over > 0 {
+		return Result{RetryAfter: over, Reset: fullAt.Sub(now)}
+	}
+	b.fullAt = next
+	return Result{
+		Allowed:   true,
+		Remaining: int(math.Floor(float64(capacity-next.Sub(now)) / float64(interval))),
+		Reset:     next.Sub(now),
+	}
+}
+
+// MemoryStore keeps the buckets in memory, so its limits are per instance
+// of the API. Full buckets are forgotten by Cleanup.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{}
+		s.buckets[key] = b
+	}
+	return b.take(limit, now), nil
+}
+
+// Cleanup forgets the buckets that are full at now, which are the same as
+// new ones.
+func (s *MemoryStore) Cleanup(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// StartCleanup runs Cleanup every interval until ctx is done.
+func (s *MemoryStore) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Cleanup(now)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("100/1m")
+	assert.Nil(t, err)
+	assert.Equal(t, Limit{Requests: 100, Period: time.Minute}, limit)
+
+	for _, s := range []string{"", "off"} {
+		limit, err = ParseLimit(s)
+		assert.Nil(t, err)
+		assert.True(t, limit.IsZero())
+	}
+	for _, s := range []string{"100", "0/1m", "-1/1m", "a/1m", "100/0s", "100/minute"} {
+		_, err = ParseLimit(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	now := time.Now()
+	take := func(key string) Result {
+		result, err := store.Take(context.Background(), key, limit, now)
+		assert.Nil(t, err)
+		return result
+	}
+
+	// A full bucket allows a burst of every request.
+	assert.Equal(t, Result{Allowed: true, Remaining: 2, Reset: time.Second}, take("a"))
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second}, take("a"))
+	assert.Equal(t, Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second}, take("a"))
+	assert.Equal(t, Result{RetryAfter: time.Second, Reset: 3 * time.Second}, take("a"))
+	// Keys have buckets of their own.
+	assert.Equal(t, Result{Allowed: true, Remaining: 2, Reset: time.Second}, take("b"))
+
+	// A token is refilled every second.
+	now = now.Add(1500 * time.Millisecond)
+	assert.Equal(t, Result{Allowed: true, Remaining: 0, Reset: 2500 * time.Millisecond}, take("a"))
+	assert.Equal(t, Result{RetryAfter: 500 * time.Millisecond, Reset: 2500 * time.Millisecond}, take("a"))
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, Result{Allowed: true, Remaining: 2, Reset: time.Second}, take("a"))
+}
+
+func TestMemoryStoreCleanup(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	now := time.Now()
+	store.Take(context.Background(), "a", limit, now)
+	store.Take(context.Background(), "b", limit, now.Add(time.Second))
+
+	store.Cleanup(now.Add(time.Second))
+	assert.Len(t, store.buckets, 1)
+	assert.Contains(t, store.buckets, "b")
+}
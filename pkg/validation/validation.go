@@ -0,0 +1,125 @@
+// Package validation collects every violation of the rules of a value, so
+// that a client learns about all of its mistakes at once.
+package validation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules of the violations, which clients can match.
+const (
+	RuleRequired  = "required"
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleMin       = "min"
+	RuleMax       = "max"
+	RuleFormat    = "format"
+)
+
+// Violation is a rule a field breaks.
+type Violation struct {
+	// Field is the path of the field, such as "name" or "address.city".
+	Field string
+	Rule  string
+	// Err describes the violation. It is matched by errors.Is on the
+	// Errors holding the violation.
+	Err error
+}
+
+// Message describes the violation.
+func (v Violation) Message() string {
+	return v.Err.Error()
+}
+
+// Errors is every violation of the rules of a value.
+type Errors []Violation
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, violation := range e {
+		messages[i] = violation.Field + ": " + violation.Message()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, violation := range e {
+		errs[i] = violation.Err
+	}
+	return errs
+}
+
+// Validator collects the violations of a value. Only the first violation of
+// each field is kept, so a missing field is not reported as too short too.
+type Validator struct {
+	path   string
+	errors *Errors
+}
+
+func New() *Validator {
+	return &Validator{errors: &Errors{}}
+}
+
+// At returns a validator of the fields nested in field, which reports its
+// violations to v.
+func (v *Validator) At(field string) *Validator {
+	return &Validator{path: v.field(field) + ".", errors: v.errors}
+}
+
+// Check records that field breaks rule, as described by err, unless ok. It
+// returns ok.
+func (v *Validator) Check(ok bool, field, rule string, err error) bool {
+	if !ok && !v.Failed(field) {
+		*v.errors = append(*v.errors, Violation{Field: v.field(field), Rule: rule, Err: err})
+	}
+	return ok
+}
+
+// Failed reports whether a violation of field was recorded.
+func (v *Validator) Failed(field string) bool {
+	field = v.field(field)
+	for _, violation := range *v.errors {
+		if violation.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the recorded violations as Errors, or nil when there is none.
+func (v *Validator) Err() error {
+	if len(*v.errors) == 0 {
+		return nil
+	}
+	return *v.errors
+}
+
+func (v *Validator) field(field string) string {
+	return v.path + field
+}
+
+// NotBlank reports whether s has other characters than spaces.
+func NotBlank(s string) bool {
+	return strings.TrimSpace(s) != ""
+}
+
+// MinLength reports whether s has at least n characters.
+func MinLength(s string, n int) bool {
+	return utf8.RuneCountInString(s) >= n
+}
+
+// MaxLength reports whether s has at most n characters.
+func MaxLength(s string, n int) bool {
+	return utf8.RuneCountInString(s) <= n
+}
+
+// Printable reports whether s is valid UTF-8 without control characters.
+func Printable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	return strings.IndexFunc(s, unicode.IsControl) == -1
+}
@@ -0,0 +1,51 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errNameRequired = errors.New("name is required")
+	errNameTooLong  = errors.New("name is too long")
+	errCityRequired = errors.New("city is required")
+)
+
+func TestValidator(t *testing.T) {
+	v := New()
+	assert.Nil(t, v.Err())
+
+	assert.False(t, v.Check(NotBlank(" "), "name", RuleRequired, errNameRequired))
+	// Only the first violation of a field is kept.
+	assert.False(t, v.Check(MinLength(" ", 3), "name", RuleMinLength, errNameTooLong))
+	assert.True(t, v.Check(true, "email", RuleFormat, errors.New("invalid email")))
+	v.At("address").Check(false, "city", RuleRequired, errCityRequired)
+
+	err := v.Err()
+	assert.Equal(t, Errors{
+		{Field: "name", Rule: RuleRequired, Err: errNameRequired},
+		{Field: "address.city", Rule: RuleRequired, Err: errCityRequired},
+	}, err)
+	assert.Equal(t, "name: name is required; address.city: city is required", err.Error())
+	assert.ErrorIs(t, err, errNameRequired)
+	assert.ErrorIs(t, err, errCityRequired)
+	assert.NotErrorIs(t, err, errNameTooLong)
+	assert.True(t, v.Failed("name"))
+	assert.False(t, v.Failed("email"))
+}
+
+func TestRules(t *testing.T) {
+	assert.True(t, NotBlank(" a "))
+	assert.False(t, NotBlank(" \t"))
+
+	assert.True(t, MinLength("çã", 2))
+	assert.False(t, MinLength("ç", 2))
+	assert.True(t, MaxLength("çãç", 3))
+	assert.False(t, MaxLength("çãçã", 3))
+
+	assert.True(t, Printable("Product 1 ç"))
+	assert.False(t, Printable("Product\n1"))
+	assert.False(t, Printable("Product \xff"))
+}